@@ -1,42 +1,101 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
 	"net/url"
 	"os"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/uslanozan/Go-Smith/models"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 // Tüm agent'ları tutan ve yöneten merkezi registry
 type AgentRegistry struct {
 	mu     sync.RWMutex
 	agents map[string]models.AgentDefinition
+	// Agent kaydedilirken bir kez derlenen argüman şemaları
+	schemas map[string]*gojsonschema.Schema
+	// API ile kaydedilip config dosyasına yazılmamış agent'lar, config reload'da silinmezler
+	runtimeOnly map[string]bool
+	// HealthChecker'ın yazdığı son health durumları (agent adı -> replika URL'i -> durum)
+	health map[string]map[string]AgentHealth
+	// Sağlıksız agent'ların tool listesinden çıkarılması (hide) ya da işaretlenmesi (mark)
+	unhealthyToolsMode string
 }
 
+var (
+	ErrAgentExists   = errors.New("agent already exists")
+	ErrAgentNotFound = errors.New("agent not found")
+)
+
+// Görev eşlemelerini tutan registry, kayıtlar arkadaki TaskStore'da saklanır
 type TaskRegistry struct {
-	mu    sync.RWMutex
-	tasks map[string]TaskInfo
+	// Durum güncellemelerindeki oku-değiştir-yaz adımlarını ve silmeleri sıraya koyar
+	mu        sync.Mutex
+	store     TaskStore
+	retention TaskRetention
+	// Kayıt sırasında yapılan son kapasite kontrolü; mu ile korunur
+	lastCapacityCheck time.Time
+
+	// Kapasite eviction'ı için görevlerin son sorgulanma zamanları
+	accessMu   sync.Mutex
+	lastAccess map[string]time.Time
 }
 
+var (
+	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskFinished = errors.New("task already finished")
+)
+
 // TaskInfo, bir görevin hangi agent'a ait olduğunu ve durum sorgulama adresini saklar.
+// TaskID Orchestrator'ın dağıttığı global kimliktir, AgentTaskID ise agent'ın kendi verdiği kimliktir.
 type TaskInfo struct {
-	AgentName          string
-	AgentStatusBaseURL string
-	AgentStopBaseURL   string
+	TaskID      string `json:"task_id"`
+	AgentName   string `json:"agent_name"`
+	AgentTaskID string `json:"agent_task_id"`
+	// Görevi kabul eden replikanın endpoint'i, durum ve durdurma çağrıları bu replikaya sabitlenir
+	Replica            string    `json:"replica"`
+	AgentStatusBaseURL string    `json:"agent_status_base_url"`
+	AgentStopBaseURL   string    `json:"agent_stop_base_url"`
+	CreatedAt          time.Time `json:"created_at"`
+	// Senkron cevabı zarflanarak kaydedilen görevler; agent'ta sorgulanabilecek bir karşılıkları yoktur
+	Sync bool `json:"sync,omitempty"`
+	// Görevi başlatan istemcinin kimliği (X-Caller-ID başlığı), verilmediyse boştur
+	Caller string `json:"caller,omitempty"`
+	// Orchestrator'ın bildiği son durum; agent'ın callback'lerinden ya da durum sorgularından güncellenir
+	LastStatus *models.TaskStatusResponse `json:"last_status,omitempty"`
+	LastUpdate *time.Time                 `json:"last_update,omitempty"`
 }
 
-// NewTaskRegistry, yeni, boş bir görev defteri oluşturur.
-func NewTaskRegistry() *TaskRegistry {
-	return &TaskRegistry{
-		tasks: make(map[string]TaskInfo),
+// NewTaskRegistry, verilen depoyu kullanan bir görev defteri oluşturur.
+func NewTaskRegistry(store TaskStore, retention TaskRetention) *TaskRegistry {
+	r := &TaskRegistry{
+		store:      store,
+		retention:  retention,
+		lastAccess: make(map[string]time.Time),
 	}
+	taskMetrics.Set("live", expvar.Func(func() any { return r.store.Len() }))
+	return r
+}
+
+// NewTaskID, agent'lardan bağımsız, global olarak tekil bir Orchestrator görev kimliği üretir.
+func NewTaskID() string {
+	return uuid.NewString()
 }
 
-func (r *TaskRegistry) RegisterTask(taskID string, agent models.AgentDefinition) error {
-	base, err := url.Parse(agent.Endpoint)
+// RegisterTask, Orchestrator görev kimliğini agent'ın yerel görev kimliğiyle eşleyerek deftere yazar.
+// Durum ve durdurma adresleri görevi kabul eden replikanın adresinden türetilir.
+func (r *TaskRegistry) RegisterTask(taskID string, start models.TaskStartResponse, agent models.AgentDefinition, replica, caller string) error {
+	base, err := url.Parse(replica)
 	if err != nil {
 		return err
 	}
@@ -45,29 +104,145 @@ func (r *TaskRegistry) RegisterTask(taskID string, agent models.AgentDefinition)
 
 	stopURL := base.ResolveReference(&url.URL{Path: agent.StopEndpointPath})
 
+	// Agent başlangıç durumu bildirmediyse görev beklemede kabul edilir
+	status := agentReportedStatus(start.Status)
+	if !status.Valid() {
+		status = models.StatusPending
+	}
+
+	now := time.Now()
 	info := TaskInfo{
+		TaskID:             taskID,
 		AgentName:          agent.Name,
+		AgentTaskID:        start.TaskID,
+		Replica:            replica,
 		AgentStatusBaseURL: statusURL.String(),
 		AgentStopBaseURL:   stopURL.String(),
+		CreatedAt:          r.createdAt(taskID, now),
+		Caller:             caller,
+		LastStatus:         &models.TaskStatusResponse{TaskID: taskID, Status: status},
+		LastUpdate:         &now,
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.tasks[taskID] = info
-	log.Printf("Görev deftere kaydedildi: TaskID %s -> Agent %s", taskID, info.AgentName)
+	if err := r.store.Save(info); err != nil {
+		return err
+	}
+	log.Printf("Görev deftere kaydedildi: TaskID %s -> Agent %s @ %s (Agent TaskID %s)", taskID, info.AgentName, replica, start.TaskID)
+	r.enforceCapacity()
+	return nil
+}
+
+// RegisterSyncTask, zarflanan senkron bir çağrıyı bitmiş görev olarak deftere yazar.
+func (r *TaskRegistry) RegisterSyncTask(agent models.AgentDefinition, replica, caller string, status models.TaskStatusResponse) error {
+	now := time.Now()
+	info := TaskInfo{
+		TaskID:     status.TaskID,
+		AgentName:  agent.Name,
+		Replica:    replica,
+		CreatedAt:  r.createdAt(status.TaskID, now),
+		Sync:       true,
+		Caller:     caller,
+		LastStatus: &status,
+		LastUpdate: &now,
+	}
+
+	if err := r.store.Save(info); err != nil {
+		return err
+	}
+	log.Printf("Senkron görev deftere kaydedildi: TaskID %s -> Agent %s (%s)", status.TaskID, agent.Name, status.Status)
+	r.enforceCapacity()
+	return nil
+}
+
+// RegisterQueuedTask, agent'ın eşzamanlılık sınırı dolu olduğu için Orchestrator'ın kuyruğunda bekleyen görevi
+// deftere yazar. Görev gönderildiğinde kayıt RegisterTask ya da RegisterSyncTask ile güncellenir.
+func (r *TaskRegistry) RegisterQueuedTask(taskID string, agent models.AgentDefinition, caller string) error {
+	now := time.Now()
+	info := TaskInfo{
+		TaskID:     taskID,
+		AgentName:  agent.Name,
+		CreatedAt:  now,
+		Caller:     caller,
+		LastStatus: &models.TaskStatusResponse{TaskID: taskID, Status: models.StatusQueued},
+		LastUpdate: &now,
+	}
+
+	if err := r.store.Save(info); err != nil {
+		return err
+	}
+	log.Printf("Kuyruktaki görev deftere kaydedildi: TaskID %s -> Agent %s", taskID, agent.Name)
+	r.enforceCapacity()
 	return nil
 }
 
 func (r *TaskRegistry) GetTaskInfo(taskID string) (TaskInfo, bool) {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	info, ok := r.tasks[taskID]
+	info, ok, err := r.store.Load(taskID)
+	if err != nil {
+		log.Printf("Hata: Görev task store'dan okunamadı (TaskID %s): %v", taskID, err)
+		return TaskInfo{}, false
+	}
+	if ok {
+		r.touch(taskID)
+	}
 	return info, ok
 }
 
+// UpdateStatus, agent'tan öğrenilen son durumu göreve yazar. Bitmiş bir görevin durumu değiştirilemez,
+// durum değişmediyse depoya yazılmaz.
+func (r *TaskRegistry) UpdateStatus(taskID string, status models.TaskStatusResponse) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok, err := r.store.Load(taskID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTaskNotFound
+	}
+
+	// İstemci agent'ın yerel kimliğini değil, Orchestrator kimliğini görür
+	status.TaskID = taskID
+	status.Status = agentReportedStatus(status.Status)
+	status.NextPollAfterMs = 0
+	if info.LastStatus != nil && sameTaskStatus(*info.LastStatus, status) {
+		return nil
+	}
+	if info.LastStatus != nil && info.LastStatus.Status.Terminal() {
+		return ErrTaskFinished
+	}
+
+	now := time.Now()
+	info.LastStatus = &status
+	info.LastUpdate = &now
+	return r.store.Save(info)
+}
+
+// agentReportedStatus, agent'ın bildirdiği durumu deftere yazılacak duruma çevirir. "queued" defterde yalnızca
+// Orchestrator'ın kuyruğunda bekleyen, henüz gönderilmemiş görevleri gösterir; agent'ın kendi kuyruğundaki
+// görev Orchestrator için gönderilmiş ve beklemededir.
+func agentReportedStatus(status models.TaskStatus) models.TaskStatus {
+	if status == models.StatusQueued {
+		return models.StatusPending
+	}
+	return status
+}
+
+// createdAt, kuyruktan gönderilen görevlerde ilk kaydın oluşturulma zamanını korur.
+func (r *TaskRegistry) createdAt(taskID string, now time.Time) time.Time {
+	if info, ok, err := r.store.Load(taskID); err == nil && ok {
+		return info.CreatedAt
+	}
+	return now
+}
+
 func NewAgentRegistry() *AgentRegistry {
 	return &AgentRegistry{
-		agents: make(map[string]models.AgentDefinition),
+		agents:             make(map[string]models.AgentDefinition),
+		schemas:            make(map[string]*gojsonschema.Schema),
+		runtimeOnly:        make(map[string]bool),
+		health:             make(map[string]map[string]AgentHealth),
+		unhealthyToolsMode: UnhealthyToolsHide,
 	}
 }
 
@@ -78,50 +253,444 @@ func (r *AgentRegistry) Get(name string) (models.AgentDefinition, bool) {
 	return agent, ok
 }
 
-func (r *AgentRegistry) GetToolsSpec() []map[string]any {
+// Count, registry'deki agent sayısını döndürür.
+func (r *AgentRegistry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.agents)
+}
+
+// ValidateArguments, argümanları agent'ın kayıt sırasında derlenmiş şemasına göre doğrular.
+func (r *AgentRegistry) ValidateArguments(name string, args json.RawMessage) ([]models.SchemaViolation, error) {
+	r.mu.RLock()
+	schema := r.schemas[name]
+	r.mu.RUnlock()
+
+	return validateArguments(schema, args)
+}
+
+// List, registry'deki tüm agent tanımlarının bir kopyasını döndürür.
+func (r *AgentRegistry) List() []models.AgentDefinition {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	defs := make([]models.AgentDefinition, 0, len(r.agents))
+	for _, agent := range r.agents {
+		defs = append(defs, agent)
+	}
+	return defs
+}
+
+// GetToolsSpec, LLM'e sunulacak tool listesini döndürür; principal yalnızca kullanabileceği agent'ları görür.
+// Sağlıksız agent'lar moda göre listeden çıkarılır ya da "available": false ile işaretlenir.
+func (r *AgentRegistry) GetToolsSpec(principal *Principal) []map[string]any {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	specs := make([]map[string]any, 0, len(r.agents))
 	for _, agent := range r.agents {
-		specs = append(specs, map[string]any{
+		if !principal.AllowsAgent(agent.Name) {
+			continue
+		}
+		available := r.healthStatusOf(agent) != HealthUnhealthy
+		if !available && r.unhealthyToolsMode == UnhealthyToolsHide {
+			continue
+		}
+
+		spec := map[string]any{
 			"name":        agent.Name,
 			"description": agent.Description,
 			"schema":      agent.Schema,
-		})
+		}
+		if r.unhealthyToolsMode == UnhealthyToolsMark {
+			spec["available"] = available
+		}
+		specs = append(specs, spec)
 	}
 	return specs
 }
 
+// Statuses, her agent için tanım ve health bilgisini isme göre sıralı döndürür.
+func (r *AgentRegistry) Statuses() []AgentStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	statuses := make([]AgentStatus, 0, len(r.agents))
+	for name, agent := range r.agents {
+		replicas := make([]ReplicaStatus, 0, len(agent.Replicas()))
+		for _, replica := range agent.Replicas() {
+			replicas = append(replicas, ReplicaStatus{
+				URL:    replica.URL,
+				Weight: replica.Weight,
+				Health: r.replicaHealthOf(name, replica.URL),
+			})
+		}
+
+		statuses = append(statuses, AgentStatus{
+			Name:          name,
+			Description:   agent.Description,
+			LoadBalancing: agent.LoadBalancing,
+			RuntimeOnly:   r.runtimeOnly[name],
+			Health:        r.healthStatusOf(agent),
+			Replicas:      replicas,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// SetUnhealthyToolsMode, sağlıksız agent'ların GetToolsSpec'te gizlenmesini ya da işaretlenmesini ayarlar.
+func (r *AgentRegistry) SetUnhealthyToolsMode(mode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unhealthyToolsMode = mode
+}
+
+// AvailableReplicas, sağlıksız olarak işaretlenmemiş replikaları döndürür.
+// Bütün replikalar sağlıksızsa istek yine de denensin diye tüm replikalar döner.
+func (r *AgentRegistry) AvailableReplicas(agent models.AgentDefinition) []models.AgentEndpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	replicas := agent.Replicas()
+	available := make([]models.AgentEndpoint, 0, len(replicas))
+	for _, replica := range replicas {
+		if r.replicaHealthOf(agent.Name, replica.URL).Available() {
+			available = append(available, replica)
+		}
+	}
+	if len(available) == 0 {
+		return replicas
+	}
+	return available
+}
+
+// RecordHealth, bir replikanın health check sonucunu işler. Durum ancak eşik kadar ardışık başarı ya da
+// hata görüldüğünde değişir, böylece tek seferlik hatalar agent'ı listeden düşürmez.
+func (r *AgentRegistry) RecordHealth(name, replicaURL string, checkErr error, healthyThreshold, unhealthyThreshold int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Kontrol sürerken agent silinmiş olabilir
+	if _, exists := r.agents[name]; !exists {
+		return
+	}
+
+	now := time.Now()
+	h := r.replicaHealthOf(name, replicaURL)
+	h.LastCheck = &now
+
+	previous := h.Status
+	if checkErr == nil {
+		h.ConsecutiveSuccesses++
+		h.ConsecutiveFailures = 0
+		h.LastError = ""
+		if h.ConsecutiveSuccesses >= healthyThreshold {
+			h.Status = HealthHealthy
+		}
+	} else {
+		h.ConsecutiveFailures++
+		h.ConsecutiveSuccesses = 0
+		h.LastError = checkErr.Error()
+		if h.ConsecutiveFailures >= unhealthyThreshold {
+			h.Status = HealthUnhealthy
+		}
+	}
+
+	if h.Status != previous {
+		h.LastChange = &now
+		log.Printf("Agent '%s' (%s) health durumu değişti: %s -> %s", name, replicaURL, previous, h.Status)
+	}
+	if r.health[name] == nil {
+		r.health[name] = make(map[string]AgentHealth)
+	}
+	r.health[name][replicaURL] = h
+}
+
 // Orchestrator ilk başladığında config/agents.json'ı okuyarak agent'ları deftere otomatik kaydeder
 func LoadAgentsFromConfig(registry *AgentRegistry, configFile string) error {
 	log.Printf("Agent konfigrasyonu yükleniyor: %s", configFile)
 
-	file, err := os.Open(configFile)
+	definitions, err := ReadAgentConfig(configFile)
 	if err != nil {
 		return err
 	}
+
+	if _, err := registry.Replace(definitions); err != nil {
+		return err
+	}
+
+	log.Printf("%d agent eylemi başarıyla yüklendi.", len(definitions))
+	return nil
+}
+
+// ReadAgentConfig, config dosyasını okur ve registry'ye yüklenmeden önce doğrular.
+// Dosya geçersizse hiçbir agent döndürülmez, böylece çağıran taraf eski konfigürasyonu korur.
+func ReadAgentConfig(configFile string) ([]models.AgentDefinition, error) {
+	file, err := os.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
 	var definitions []models.AgentDefinition
 	if err := json.NewDecoder(file).Decode(&definitions); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(definitions))
+	for i, def := range definitions {
+		if err := ValidateAgentDefinition(def); err != nil {
+			return nil, fmt.Errorf("%d. agent tanımı geçersiz: %w", i+1, err)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("agent '%s' birden fazla kez tanımlanmış", def.Name)
+		}
+		seen[def.Name] = true
+	}
+	return definitions, nil
+}
+
+// ValidateAgentDefinition, bir agent tanımının adını, endpoint URL'ini ve şemasını kontrol eder.
+func ValidateAgentDefinition(def models.AgentDefinition) error {
+	if def.Name == "" {
+		return errors.New("agent adı boş olamaz")
+	}
+	if len(def.Endpoints) == 0 {
+		if err := validateEndpointURL(def.Endpoint); err != nil {
+			return fmt.Errorf("agent '%s' endpoint'i geçersiz: %w", def.Name, err)
+		}
+	}
+	for _, replica := range def.Endpoints {
+		if err := validateEndpointURL(replica.URL); err != nil {
+			return fmt.Errorf("agent '%s' replika endpoint'i geçersiz: %w", def.Name, err)
+		}
+		if replica.Weight < 0 {
+			return fmt.Errorf("agent '%s' replika ağırlığı negatif olamaz: %s", def.Name, replica.URL)
+		}
+	}
+	switch def.LoadBalancing {
+	case "", LBRoundRobin, LBLeastInFlight, LBWeighted:
+	default:
+		return fmt.Errorf("agent '%s' için bilinmeyen load balancing stratejisi: %s", def.Name, def.LoadBalancing)
+	}
+	if retry := def.Retry; retry != nil {
+		if retry.MaxAttempts < 0 || retry.Jitter < 0 || retry.Jitter > 1 {
+			return fmt.Errorf("agent '%s' retry ayarı geçersiz: max_attempts >= 0 ve 0 <= jitter <= 1 olmalı", def.Name)
+		}
+	}
+	if breaker := def.CircuitBreaker; breaker != nil && breaker.FailureThreshold < 0 {
+		return fmt.Errorf("agent '%s' circuit_breaker.failure_threshold negatif olamaz", def.Name)
+	}
+	if def.DispatchTimeout < 0 || def.StatusTimeout < 0 || def.StopTimeout < 0 {
+		return fmt.Errorf("agent '%s' zaman aşımı değerleri negatif olamaz", def.Name)
+	}
+	if def.MaxConcurrency < 0 || def.MaxQueue < 0 {
+		return fmt.Errorf("agent '%s' max_concurrency ve max_queue negatif olamaz", def.Name)
+	}
+	if err := validateAgentAuth(def.Auth); err != nil {
+		return fmt.Errorf("agent '%s' auth ayarı geçersiz: %w", def.Name, err)
+	}
+	if err := validateAgentTLS(def); err != nil {
+		return fmt.Errorf("agent '%s' tls ayarı geçersiz: %w", def.Name, err)
+	}
+	if _, err := compileAgentSchema(def); err != nil {
 		return err
 	}
+	return nil
+}
 
-	count := 0
-	for _, def := range definitions {
-		registry.register(def)
-		count++
+// Add, yeni bir agent kaydeder. Aynı isimde agent varsa ErrAgentExists döner.
+// runtimeOnly, agent'ın config dosyasına yazılmadığını ve config reload'da korunması gerektiğini belirtir.
+func (r *AgentRegistry) Add(def models.AgentDefinition, runtimeOnly bool) error {
+	schema, err := compileAgentSchema(def)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("%d agent eylemi başarıyla yüklendi.", count)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.agents[def.Name]; exists {
+		return ErrAgentExists
+	}
+	r.set(def, schema, runtimeOnly)
 	return nil
 }
 
-// ---------------------- HELPERS ----------------------
+// Update, var olan bir agent'ın tanımını değiştirir. Agent yoksa ErrAgentNotFound döner.
+func (r *AgentRegistry) Update(def models.AgentDefinition, runtimeOnly bool) error {
+	schema, err := compileAgentSchema(def)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.agents[def.Name]; !exists {
+		return ErrAgentNotFound
+	}
+	r.set(def, schema, runtimeOnly)
+	return nil
+}
+
+// Remove, agent'ı registry'den siler. Agent yoksa ErrAgentNotFound döner.
+func (r *AgentRegistry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.agents[name]; !exists {
+		return ErrAgentNotFound
+	}
+	delete(r.agents, name)
+	delete(r.schemas, name)
+	delete(r.runtimeOnly, name)
+	delete(r.health, name)
+	return nil
+}
+
+// AgentConfigDiff, iki konfigürasyon arasında eklenen, silinen ve değişen agent adlarını tutar.
+type AgentConfigDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+func (d AgentConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Replace, registry içeriğini verilen tanımlarla atomik olarak değiştirir ve farkı döndürür.
+// Şemalardan biri derlenemezse registry'ye hiç dokunulmaz. API ile eklenmiş ve dosyada
+// bulunmayan agent'lar korunur, fark hesabında görünmezler.
+func (r *AgentRegistry) Replace(definitions []models.AgentDefinition) (AgentConfigDiff, error) {
+	agents := make(map[string]models.AgentDefinition, len(definitions))
+	schemas := make(map[string]*gojsonschema.Schema, len(definitions))
+	for _, def := range definitions {
+		schema, err := compileAgentSchema(def)
+		if err != nil {
+			return AgentConfigDiff{}, err
+		}
+		agents[def.Name] = def
+		schemas[def.Name] = schema
+	}
 
-func (r *AgentRegistry) register(def models.AgentDefinition) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+
+	runtimeOnly := make(map[string]bool)
+	for name, def := range r.agents {
+		if !r.runtimeOnly[name] {
+			continue
+		}
+		// Aynı isim artık dosyada tanımlıysa dosyadaki tanım geçerli olur
+		if _, inFile := agents[name]; !inFile {
+			agents[name] = def
+			schemas[name] = r.schemas[name]
+			runtimeOnly[name] = true
+		}
+	}
+
+	// Korunan agent'lar iki tarafta da aynı olduğundan farkta görünmez; dosyadaki tanımın yerini aldığı
+	// API agent'ı ise tanımı farklıysa değişmiş sayılır
+	diff := diffAgents(r.agents, agents)
+	r.agents = agents
+	r.schemas = schemas
+	r.runtimeOnly = runtimeOnly
+
+	// Silinen ve tanımı değişen agent'ların health geçmişi artık geçerli değildir
+	for _, name := range append(diff.Removed, diff.Changed...) {
+		delete(r.health, name)
+	}
+	return diff, nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+// set, kilit tutulurken çağrılmalıdır.
+func (r *AgentRegistry) set(def models.AgentDefinition, schema *gojsonschema.Schema, runtimeOnly bool) {
 	r.agents[def.Name] = def
+	r.schemas[def.Name] = schema
+	delete(r.health, def.Name)
+	if runtimeOnly {
+		r.runtimeOnly[def.Name] = true
+	} else {
+		delete(r.runtimeOnly, def.Name)
+	}
+}
+
+// replicaHealthOf, kilit tutulurken çağrılmalıdır.
+func (r *AgentRegistry) replicaHealthOf(name, replicaURL string) AgentHealth {
+	if h, ok := r.health[name][replicaURL]; ok {
+		return h
+	}
+	return AgentHealth{Status: HealthUnknown}
+}
+
+// healthStatusOf, replikaların durumundan agent'ın genel durumunu çıkarır: en az bir replika sağlıklıysa
+// agent sağlıklıdır, bütün replikalar sağlıksızsa agent sağlıksızdır, diğer durumlarda bilinmiyordur.
+// Kilit tutulurken çağrılmalıdır.
+func (r *AgentRegistry) healthStatusOf(agent models.AgentDefinition) HealthStatus {
+	replicas := agent.Replicas()
+	unhealthy := 0
+	for _, replica := range replicas {
+		switch r.replicaHealthOf(agent.Name, replica.URL).Status {
+		case HealthHealthy:
+			return HealthHealthy
+		case HealthUnhealthy:
+			unhealthy++
+		}
+	}
+	if len(replicas) > 0 && unhealthy == len(replicas) {
+		return HealthUnhealthy
+	}
+	return HealthUnknown
+}
+
+func validateEndpointURL(raw string) error {
+	if raw == "" {
+		return errors.New("URL boş")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("desteklenmeyen şema: %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return errors.New("host eksik")
+	}
+	return nil
+}
+
+func diffAgents(oldAgents, newAgents map[string]models.AgentDefinition) AgentConfigDiff {
+	diff := AgentConfigDiff{
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+
+	for name, newDef := range newAgents {
+		oldDef, exists := oldAgents[name]
+		if !exists {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+
+		// RawMessage alanları Marshal sırasında sıkıştırıldığı için boşluk farkları değişiklik sayılmaz
+		oldJSON, _ := json.Marshal(oldDef)
+		newJSON, _ := json.Marshal(newDef)
+		if !bytes.Equal(oldJSON, newJSON) {
+			diff.Changed = append(diff.Changed, name)
+		}
+	}
+	for name := range oldAgents {
+		if _, exists := newAgents[name]; !exists {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
 }
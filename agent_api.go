@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// AgentAPI, deployment pipeline'larının çalışma anında agent kaydetmesi, güncellemesi ve silmesi için
+// AgentRegistry üzerine açılan CRUD endpoint'lerini sunar.
+type AgentAPI struct {
+	Registry   *AgentRegistry
+	ConfigFile string
+	// İstekte ?persist= verilmediğinde değişikliklerin config dosyasına yazılıp yazılmayacağı
+	PersistByDefault bool
+
+	// Config dosyasına eşzamanlı yazımları sıraya koyar
+	mu sync.Mutex
+}
+
+func NewAgentAPI(registry *AgentRegistry, configFile string, persistByDefault bool) *AgentAPI {
+	return &AgentAPI{
+		Registry:         registry,
+		ConfigFile:       configFile,
+		PersistByDefault: persistByDefault,
+	}
+}
+
+// AgentStatus, /api/v1/agents durum görünümündeki tek bir agent satırıdır.
+type AgentStatus struct {
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	LoadBalancing string          `json:"load_balancing,omitempty"`
+	RuntimeOnly   bool            `json:"runtime_only"`
+	Health        HealthStatus    `json:"health"`
+	Replicas      []ReplicaStatus `json:"replicas"`
+}
+
+// ReplicaStatus, bir agent replikasının endpoint'i ve health durumudur.
+type ReplicaStatus struct {
+	URL    string      `json:"url"`
+	Weight int         `json:"weight,omitempty"`
+	Health AgentHealth `json:"health"`
+}
+
+// HandleAgents, /api/v1/agents üzerinde GET ile agent'ların durumunu listeler, POST ile yeni agent kaydeder.
+func (a *AgentAPI) HandleAgents(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.Registry.Statuses())
+	case "POST":
+		a.createAgent(w, r)
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AgentAPI) createAgent(w http.ResponseWriter, r *http.Request) {
+	var def models.AgentDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := ValidateAgentDefinition(def); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	persist, err := a.persistRequested(r)
+	if err != nil {
+		http.Error(w, "Invalid persist parameter", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.Registry.Get(def.Name); exists {
+		http.Error(w, "Agent already exists, use PUT to update it", http.StatusConflict)
+		return
+	}
+	if persist {
+		if err := a.persistAgent(def.Name, &def); err != nil {
+			log.Printf("Hata: Agent '%s' config dosyasına yazılamadı: %v", def.Name, err)
+			http.Error(w, "Failed to persist agent config", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := a.Registry.Add(def, !persist); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+
+	log.Printf("Agent API ile kaydedildi: '%s' (persist: %t)", def.Name, persist)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(def)
+}
+
+// HandleAgent, /api/v1/agents/{name} üzerinde GET, PUT ve DELETE işlemlerini yürütür.
+func (a *AgentAPI) HandleAgent(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	switch r.Method {
+	case "GET":
+		def, ok := a.Registry.Get(name)
+		if !ok {
+			http.Error(w, "Agent not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(def)
+
+	case "PUT":
+		a.updateAgent(w, r, name)
+
+	case "DELETE":
+		a.deleteAgent(w, r, name)
+
+	default:
+		http.Error(w, "Only GET, PUT and DELETE methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *AgentAPI) updateAgent(w http.ResponseWriter, r *http.Request, name string) {
+	var def models.AgentDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Gövdede isim verilmediyse URL'deki isim kullanılır, verildiyse eşleşmek zorundadır
+	if def.Name == "" {
+		def.Name = name
+	}
+	if def.Name != name {
+		http.Error(w, "Agent name in body does not match URL", http.StatusBadRequest)
+		return
+	}
+
+	if err := ValidateAgentDefinition(def); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	persist, err := a.persistRequested(r)
+	if err != nil {
+		http.Error(w, "Invalid persist parameter", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.Registry.Get(name); !exists {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if persist {
+		if err := a.persistAgent(name, &def); err != nil {
+			log.Printf("Hata: Agent '%s' config dosyasına yazılamadı: %v", name, err)
+			http.Error(w, "Failed to persist agent config", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := a.Registry.Update(def, !persist); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+
+	log.Printf("Agent API ile güncellendi: '%s' (persist: %t)", name, persist)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(def)
+}
+
+// deleteAgent, agent'ı registry'den siler. persist verilmezse agent config dosyasında kalır
+// ve bir sonraki config reload'da geri yüklenir.
+func (a *AgentAPI) deleteAgent(w http.ResponseWriter, r *http.Request, name string) {
+	persist, err := a.persistRequested(r)
+	if err != nil {
+		http.Error(w, "Invalid persist parameter", http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.Registry.Get(name); !exists {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if persist {
+		if err := a.persistAgent(name, nil); err != nil {
+			log.Printf("Hata: Agent '%s' config dosyasından silinemedi: %v", name, err)
+			http.Error(w, "Failed to persist agent config", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := a.Registry.Remove(name); err != nil {
+		writeRegistryError(w, err)
+		return
+	}
+
+	log.Printf("Agent API ile silindi: '%s' (persist: %t)", name, persist)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------- HELPERS ----------------------
+
+func (a *AgentAPI) persistRequested(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("persist")
+	if raw == "" {
+		return a.PersistByDefault, nil
+	}
+	return strconv.ParseBool(raw)
+}
+
+// persistAgent, tek bir agent değişikliğini config dosyasına uygular. def nil ise agent dosyadan silinir.
+// Diğer kayıtlar ve sıraları korunur. Dosya geçici bir dosyaya yazılıp atomik olarak taşınır,
+// böylece config izleyicisi hiçbir zaman yarım yazılmış bir dosya görmez.
+func (a *AgentAPI) persistAgent(name string, def *models.AgentDefinition) error {
+	data, err := os.ReadFile(a.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	var entries []json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	updated := make([]json.RawMessage, 0, len(entries)+1)
+	found := false
+	for _, entry := range entries {
+		var header struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(entry, &header); err != nil {
+			return err
+		}
+		if header.Name != name {
+			updated = append(updated, entry)
+			continue
+		}
+
+		found = true
+		if def != nil {
+			raw, err := json.Marshal(def)
+			if err != nil {
+				return err
+			}
+			updated = append(updated, raw)
+		}
+	}
+	if !found && def != nil {
+		raw, err := json.Marshal(def)
+		if err != nil {
+			return err
+		}
+		updated = append(updated, raw)
+	}
+
+	out, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := a.ConfigFile + ".tmp"
+	if err := os.WriteFile(tmpPath, append(out, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, a.ConfigFile)
+}
+
+func writeRegistryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrAgentExists):
+		http.Error(w, "Agent already exists, use PUT to update it", http.StatusConflict)
+	case errors.Is(err, ErrAgentNotFound):
+		http.Error(w, "Agent not found", http.StatusNotFound)
+	default:
+		http.Error(w, fmt.Sprintf("Agent registry error: %v", err), http.StatusBadRequest)
+	}
+}
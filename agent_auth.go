@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// HMAC imzasının ve zaman damgasının varsayılan başlıkları
+const (
+	DefaultSignatureHeader = "X-GoSmith-Signature"
+	DefaultTimestampHeader = "X-GoSmith-Timestamp"
+)
+
+// AgentSecretEnvPrefix, agent auth'unda env ile okunabilecek değişkenlerin öneki. Böylece bir agent tanımı
+// Orchestrator'ın kendi gizli değerlerini (ör. AUTH_JWT_SECRET, CALLBACK_SECRET) agent'a gönderemez.
+const AgentSecretEnvPrefix = "GOSMITH_AGENT_SECRET_"
+
+// agentSecretsDir, file ile okunabilecek gizli değerlerin dizinidir (AGENT_SECRETS_DIR). Boşsa file kullanılamaz.
+var agentSecretsDir string
+
+// SetAgentSecretsDir, file referanslarının bulunabileceği dizini ayarlar. Agent'lar yüklenmeden önce çağrılmalıdır.
+func SetAgentSecretsDir(dir string) error {
+	if dir == "" {
+		agentSecretsDir = ""
+		return nil
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("AGENT_SECRETS_DIR okunamadı: %v", err)
+	}
+	if agentSecretsDir, err = filepath.Abs(resolved); err != nil {
+		return fmt.Errorf("AGENT_SECRETS_DIR okunamadı: %v", err)
+	}
+	return nil
+}
+
+// applyAgentAuth, agent'ın auth ayarındaki kimlik bilgilerini isteğe ekler. Gizli değerler her istekte
+// environment'tan ya da dosyadan okunur, böylece değiştirildiklerinde yeniden başlatma gerekmez.
+func applyAgentAuth(req *http.Request, auth *models.AgentAuth, body []byte) error {
+	if auth == nil {
+		return nil
+	}
+
+	if auth.Bearer != nil {
+		token, err := resolveSecret(*auth.Bearer)
+		if err != nil {
+			return fmt.Errorf("bearer token okunamadı: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	if auth.Basic != nil {
+		password, err := resolveSecret(auth.Basic.Password)
+		if err != nil {
+			return fmt.Errorf("basic auth şifresi okunamadı: %w", err)
+		}
+		req.SetBasicAuth(auth.Basic.Username, password)
+	}
+
+	for name, ref := range auth.Headers {
+		value, err := resolveSecret(ref)
+		if err != nil {
+			return fmt.Errorf("'%s' başlığı okunamadı: %w", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	if auth.HMAC != nil {
+		secret, err := resolveSecret(auth.HMAC.Secret)
+		if err != nil {
+			return fmt.Errorf("HMAC anahtarı okunamadı: %w", err)
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(headerOrDefault(auth.HMAC.TimestampHeader, DefaultTimestampHeader), timestamp)
+		req.Header.Set(headerOrDefault(auth.HMAC.SignatureHeader, DefaultSignatureHeader), signAgentRequest(secret, timestamp, req.Method, req.URL.RequestURI(), body))
+	}
+	return nil
+}
+
+// signAgentRequest, "<timestamp>.<method>.<path?query>.<body>" mesajının HMAC-SHA256 imzasını "sha256=<hex>" olarak döndürür.
+func signAgentRequest(secret, timestamp, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + method + "." + requestURI + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validateAgentAuth, auth ayarının tutarlı olduğunu ve bütün gizli değerlerin okunabildiğini kontrol eder.
+func validateAgentAuth(auth *models.AgentAuth) error {
+	if auth == nil {
+		return nil
+	}
+	if auth.Bearer != nil && auth.Basic != nil {
+		return errors.New("bearer ve basic birlikte kullanılamaz")
+	}
+
+	refs := map[string]models.SecretRef{}
+	if auth.Bearer != nil {
+		refs["bearer"] = *auth.Bearer
+	}
+	if auth.Basic != nil {
+		if auth.Basic.Username == "" {
+			return errors.New("basic.username boş olamaz")
+		}
+		refs["basic.password"] = auth.Basic.Password
+	}
+	for name, ref := range auth.Headers {
+		if http.CanonicalHeaderKey(name) == "Authorization" && (auth.Bearer != nil || auth.Basic != nil) {
+			return errors.New("Authorization başlığı bearer ya da basic ile birlikte verilemez")
+		}
+		refs["headers."+name] = ref
+	}
+	if auth.HMAC != nil {
+		refs["hmac.secret"] = auth.HMAC.Secret
+	}
+
+	for name, ref := range refs {
+		if _, err := resolveSecret(ref); err != nil {
+			return fmt.Errorf("auth.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+// resolveSecret, gizli değeri environment değişkeninden ya da dosyadan okur. Dosyanın sonundaki satır sonu atılır.
+// Yalnızca AgentSecretEnvPrefix ile başlayan değişkenler ve AGENT_SECRETS_DIR içindeki dosyalar okunabilir.
+func resolveSecret(ref models.SecretRef) (string, error) {
+	switch {
+	case ref.Env != "" && ref.File != "":
+		return "", errors.New("env ve file birlikte verilemez")
+	case ref.Env != "":
+		if !strings.HasPrefix(ref.Env, AgentSecretEnvPrefix) || ref.Env == AgentSecretEnvPrefix {
+			return "", fmt.Errorf("%s kullanılamaz, agent gizli değerleri %s ile başlamalı", ref.Env, AgentSecretEnvPrefix)
+		}
+		value, ok := os.LookupEnv(ref.Env)
+		if !ok || value == "" {
+			return "", fmt.Errorf("%s environment değişkeni tanımlı değil", ref.Env)
+		}
+		return value, nil
+	case ref.File != "":
+		path, err := secretFilePath(ref.File)
+		if err != nil {
+			return "", err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("%s dosyası boş", ref.File)
+		}
+		return value, nil
+	}
+	return "", errors.New("env ya da file verilmeli")
+}
+
+// secretFilePath, dosya yolunu symlink'leriyle birlikte çözer ve AGENT_SECRETS_DIR dışına çıkmadığını kontrol eder.
+// Göreli yollar AGENT_SECRETS_DIR'e göre çözülür.
+func secretFilePath(file string) (string, error) {
+	if agentSecretsDir == "" {
+		return "", fmt.Errorf("%s kullanılamaz, file referansları için AGENT_SECRETS_DIR tanımlanmalı", file)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(agentSecretsDir, file)
+	}
+	resolved, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(agentSecretsDir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s kullanılamaz, dosya AGENT_SECRETS_DIR (%s) içinde olmalı", file, agentSecretsDir)
+	}
+	return resolved, nil
+}
+
+func headerOrDefault(header, fallback string) string {
+	if header == "" {
+		return fallback
+	}
+	return header
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+func TestReplaceDiff(t *testing.T) {
+	fileAgent := models.AgentDefinition{Name: "file", Endpoint: "http://file:9000"}
+	apiAgent := models.AgentDefinition{Name: "api", Endpoint: "http://api:9000"}
+
+	tests := []struct {
+		name       string
+		definition []models.AgentDefinition
+		want       AgentConfigDiff
+		wantAgents []string
+	}{
+		{
+			name:       "dosya değişmediyse API agent'ı eklenmiş görünmez",
+			definition: []models.AgentDefinition{fileAgent},
+			want:       AgentConfigDiff{Added: []string{}, Removed: []string{}, Changed: []string{}},
+			wantAgents: []string{"api", "file"},
+		},
+		{
+			name:       "yeni dosya agent'ı eklenir, silinen dosya agent'ı çıkarılır",
+			definition: []models.AgentDefinition{{Name: "other", Endpoint: "http://other:9000"}},
+			want:       AgentConfigDiff{Added: []string{"other"}, Removed: []string{"file"}, Changed: []string{}},
+			wantAgents: []string{"api", "other"},
+		},
+		{
+			name:       "dosyadaki farklı tanım API agent'ının yerini alır",
+			definition: []models.AgentDefinition{fileAgent, {Name: "api", Endpoint: "http://api-v2:9000"}},
+			want:       AgentConfigDiff{Added: []string{}, Removed: []string{}, Changed: []string{"api"}},
+			wantAgents: []string{"api", "file"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewAgentRegistry()
+			if err := r.Add(fileAgent, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Add(apiAgent, true); err != nil {
+				t.Fatal(err)
+			}
+
+			diff, err := r.Replace(tt.definition)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(diff.Added, tt.want.Added) || !slices.Equal(diff.Removed, tt.want.Removed) || !slices.Equal(diff.Changed, tt.want.Changed) {
+				t.Errorf("fark = %+v, beklenen %+v", diff, tt.want)
+			}
+
+			var names []string
+			for _, name := range []string{"api", "file", "other"} {
+				if _, ok := r.Get(name); ok {
+					names = append(names, name)
+				}
+			}
+			if !slices.Equal(names, tt.wantAgents) {
+				t.Errorf("agent'lar = %v, beklenen %v", names, tt.wantAgents)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// agentTransport, tls ayarı olan bir agent'ın HTTP client'ıdır. Sertifikalar değiştiğinde client yeniden kurulur.
+type agentTransport struct {
+	settings   models.AgentTLS
+	material   *tlsMaterial
+	generation int
+	client     *http.Client
+}
+
+// AgentTransports, agent'lara yapılan çağrıların HTTP client'larını tutar. tls ayarı olmayan agent'lar ortak
+// client'ı kullanır; olanlar kendi CA, istemci sertifikası ve sürüm ayarlarıyla ayrı bir client alır.
+// Zaman aşımları client'ta değil, her işlem için istek context'inde uygulanır.
+type AgentTransports struct {
+	defaultClient *http.Client
+
+	mu     sync.Mutex
+	agents map[string]*agentTransport
+}
+
+func NewAgentTransports() *AgentTransports {
+	return &AgentTransports{
+		defaultClient: &http.Client{},
+		agents:        make(map[string]*agentTransport),
+	}
+}
+
+// Client, agent'a istek atılacak client'ı döndürür. tls ayarı değiştiyse ya da sertifika dosyaları
+// yenilendiyse yeni bağlantılar güncel sertifikalarla kurulur, eski client'ın boştaki bağlantıları kapatılır.
+func (t *AgentTransports) Client(agent models.AgentDefinition) (*http.Client, error) {
+	if agent.TLS == nil {
+		return t.defaultClient, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.agents[agent.Name]
+	if !ok || entry.settings != *agent.TLS {
+		material, err := newTLSMaterial(agent.TLS.CertFile, agent.TLS.KeyFile, agent.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("agent '%s' TLS dosyaları okunamadı: %w", agent.Name, err)
+		}
+		if ok {
+			entry.client.CloseIdleConnections()
+		}
+		entry = &agentTransport{settings: *agent.TLS, material: material}
+		t.agents[agent.Name] = entry
+	}
+
+	snapshot, err := entry.material.current()
+	if err != nil {
+		return nil, fmt.Errorf("agent '%s' TLS dosyaları okunamadı: %w", agent.Name, err)
+	}
+	if entry.client == nil || snapshot.generation != entry.generation {
+		if entry.client != nil {
+			entry.client.CloseIdleConnections()
+		}
+		// Sürüm ValidateAgentDefinition'da doğrulandı
+		minVersion, _ := parseTLSVersion(entry.settings.MinVersion)
+		config := &tls.Config{
+			RootCAs:    snapshot.pool,
+			ServerName: entry.settings.ServerName,
+			MinVersion: minVersion,
+		}
+		if snapshot.cert != nil {
+			config.Certificates = []tls.Certificate{*snapshot.cert}
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		entry.client = &http.Client{Transport: transport}
+		entry.generation = snapshot.generation
+	}
+	return entry.client, nil
+}
+
+// validateAgentTLS, tls ayarının yalnızca https endpoint'lerde kullanıldığını ve dosyaların okunabildiğini kontrol eder.
+func validateAgentTLS(def models.AgentDefinition) error {
+	if def.TLS == nil {
+		return nil
+	}
+	for _, replica := range def.Replicas() {
+		if u, err := url.Parse(replica.URL); err == nil && u.Scheme != "https" {
+			return fmt.Errorf("tls ayarı yalnızca https endpoint'lerde kullanılabilir: %s", replica.URL)
+		}
+	}
+	if _, err := parseTLSVersion(def.TLS.MinVersion); err != nil {
+		return err
+	}
+	if def.TLS.CertFile == "" && def.TLS.CAFile == "" && def.TLS.ServerName == "" && def.TLS.MinVersion == "" {
+		return errors.New("tls ayarı boş")
+	}
+	if _, err := newTLSMaterial(def.TLS.CertFile, def.TLS.KeyFile, def.TLS.CAFile); err != nil {
+		return err
+	}
+	return nil
+}
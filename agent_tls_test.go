@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Agent'a mTLS ile bağlanılır; istemci sertifikası değişince yeni bağlantılar yeni sertifikayla kurulur
+func TestAgentTransportsClientReload(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA := newTestCA(t, "Sunucu CA"), newTestCA(t, "İstemci CA")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeTestFile(t, caFile, serverCA.certPEM)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	clientCA.writeCert(t, "orchestrator-1", true, certFile, keyFile)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(clientCA.certPEM)
+	server := newTestTLSServer(t, &tls.Config{
+		Certificates: []tls.Certificate{serverCA.issue(t, "agent", false)},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+
+	agent := models.AgentDefinition{
+		Name:     "echo",
+		Endpoint: server.URL,
+		TLS:      &models.AgentTLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+	}
+	transports := NewAgentTransports()
+
+	tests := []struct {
+		name string
+		// İstekten önce dosyalara yapılan değişiklik
+		change     func(t *testing.T)
+		wantClient string
+		// Önceki adımdaki client'ın kullanılmaya devam etmesi bekleniyor mu
+		wantSameClient bool
+	}{
+		{"ilk bağlantı", func(t *testing.T) {}, "orchestrator-1", false},
+		{"dosyalar değişmeden aynı client", func(t *testing.T) {}, "orchestrator-1", true},
+		{"yenilenen istemci sertifikası", func(t *testing.T) {
+			clientCA.writeCert(t, "orchestrator-2", true, certFile, keyFile)
+		}, "orchestrator-2", false},
+	}
+
+	var previous *http.Client
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change(t)
+			client, err := transports.Client(agent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (client == previous) != tt.wantSameClient {
+				t.Errorf("aynı client: %t, beklenen %t", client == previous, tt.wantSameClient)
+			}
+			previous = client
+
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.wantClient {
+				t.Errorf("agent'ın gördüğü sertifika %s, beklenen %s", body, tt.wantClient)
+			}
+		})
+	}
+
+	// tls ayarı olmayan agent'lar ortak client'ı kullanır
+	first, _ := transports.Client(models.AgentDefinition{Name: "plain", Endpoint: "http://plain:9000"})
+	second, _ := transports.Client(models.AgentDefinition{Name: "other", Endpoint: "http://other:9000"})
+	if first != second {
+		t.Error("tls ayarı olmayan agent'lar farklı client aldı")
+	}
+}
+
+func TestValidateAgentTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	caFile := filepath.Join(dir, "ca.crt")
+	writeTestFile(t, caFile, ca.certPEM)
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	ca.writeCert(t, "orchestrator", true, certFile, keyFile)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		tls      *models.AgentTLS
+		wantErr  bool
+	}{
+		{"tls yok", "http://echo:9000", nil, false},
+		{"CA", "https://echo:9443", &models.AgentTLS{CAFile: caFile}, false},
+		{"mTLS", "https://echo:9443", &models.AgentTLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, false},
+		{"yalnızca sürüm", "https://echo:9443", &models.AgentTLS{MinVersion: "1.3"}, false},
+		{"http endpoint", "http://echo:9000", &models.AgentTLS{CAFile: caFile}, true},
+		{"boş ayar", "https://echo:9443", &models.AgentTLS{}, true},
+		{"geçersiz sürüm", "https://echo:9443", &models.AgentTLS{MinVersion: "1.0"}, true},
+		{"anahtarsız sertifika", "https://echo:9443", &models.AgentTLS{CertFile: certFile}, true},
+		{"olmayan CA dosyası", "https://echo:9443", &models.AgentTLS{CAFile: filepath.Join(dir, "missing.crt")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgentTLS(models.AgentDefinition{Name: "echo", Endpoint: tt.endpoint, TLS: tt.tls})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("hata %v, hata bekleniyor: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// API anahtarının Authorization yerine gönderilebileceği başlık
+const APIKeyHeader = "X-API-Key"
+
+// JWT'lerin exp/nbf kontrolünde saat farkı için tanınan pay
+const jwtLeeway = 30 * time.Second
+
+var (
+	ErrMissingCredentials = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrCredentialExpired  = errors.New("credential expired")
+)
+
+type principalKey struct{}
+
+// Kimliğin hangi yolla doğrulandığı
+const (
+	PrincipalAPIKey = "api_key"
+	PrincipalJWT    = "jwt"
+)
+
+// Principal, kimliği doğrulanmış istemcidir. Nil bir Principal, kimlik doğrulama kapalıyken her şeye izin verir.
+// Zamanlamalarla birlikte saklandığı için JSON'a yazılabilir.
+type Principal struct {
+	Name       string                 `json:"name"`
+	Kind       string                 `json:"kind"`
+	Agents     []string               `json:"agents"`
+	Operations []models.AuthOperation `json:"operations"`
+	// JWT'nin exp değeri; token'la oluşturulan zamanlamalar bu zamandan sonra çalıştırılmaz
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// AllowsAgent, kimliğin agent'ı kullanıp kullanamayacağını söyler.
+func (p *Principal) AllowsAgent(name string) bool {
+	return p == nil || slices.Contains(p.Agents, models.AuthWildcard) || slices.Contains(p.Agents, name)
+}
+
+// Allows, kimliğin işlemi yapıp yapamayacağını söyler.
+func (p *Principal) Allows(op models.AuthOperation) bool {
+	return p == nil || slices.Contains(p.Operations, models.AuthWildcard) || slices.Contains(p.Operations, op)
+}
+
+// principalFrom, isteği yapan kimliği döndürür; kimlik doğrulama kapalıysa nil döner.
+func principalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// jwtClaims, Orchestrator'ın HS256 JWT'lerden okuduğu alanlardır. Caller olarak sub kullanılır.
+type jwtClaims struct {
+	Agents     []string               `json:"agents"`
+	Operations []models.AuthOperation `json:"operations"`
+	jwt.RegisteredClaims
+}
+
+// Authenticator, Orchestrator API'sine gelen istekleri statik API anahtarları ya da HS256 JWT'lerle doğrular.
+// Ne anahtar dosyası ne de JWT secret tanımlıysa kapalıdır ve bütün istekleri geçirir.
+type Authenticator struct {
+	// SHA-256 özeti -> kimlik
+	apiKeys   map[string]*Principal
+	jwtSecret []byte
+	parser    *jwt.Parser
+}
+
+func NewAuthenticator(cfg *OrchestratorConfig) (*Authenticator, error) {
+	a := &Authenticator{apiKeys: make(map[string]*Principal)}
+
+	if cfg.AuthConfigFile != "" {
+		if err := a.loadAPIKeys(cfg.AuthConfigFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.AuthJWTSecret != "" {
+		a.jwtSecret = []byte(cfg.AuthJWTSecret)
+		options := []jwt.ParserOption{
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithExpirationRequired(),
+			jwt.WithLeeway(jwtLeeway),
+		}
+		if cfg.AuthJWTIssuer != "" {
+			options = append(options, jwt.WithIssuer(cfg.AuthJWTIssuer))
+		}
+		if cfg.AuthJWTAudience != "" {
+			options = append(options, jwt.WithAudience(cfg.AuthJWTAudience))
+		}
+		a.parser = jwt.NewParser(options...)
+	}
+
+	if a.Enabled() {
+		log.Printf("Kimlik doğrulama açık: %d API anahtarı, JWT: %t", len(a.apiKeys), a.parser != nil)
+	} else {
+		log.Println("Uyarı: AUTH_CONFIG_FILE ve AUTH_JWT_SECRET tanımlı değil, Orchestrator API'si kimlik doğrulamasız açık.")
+	}
+	return a, nil
+}
+
+func (a *Authenticator) Enabled() bool {
+	return len(a.apiKeys) > 0 || a.parser != nil
+}
+
+// Current, daha önce doğrulanmış bir kimliğin güncel halini döndürür; zamanlamalar gibi isteği yapan kimlik
+// adına sonradan çalışan işler için kullanılır. API anahtarları adıyla yeniden aranır, böylece silinen ya da
+// yetkisi daraltılan anahtarlar hemen etkili olur. JWT'ler yeniden doğrulanamadığından saklanan yetkileriyle,
+// JWT kabulü açık kaldıkça ve token'ın süresi dolana kadar geçerlidir; süresi dolmuşsa ErrCredentialExpired
+// döner. Kimlik doğrulama kapalıysa nil döner.
+func (a *Authenticator) Current(saved *Principal) (*Principal, error) {
+	if !a.Enabled() {
+		return nil, nil
+	}
+	if saved == nil {
+		return nil, ErrMissingCredentials
+	}
+
+	switch saved.Kind {
+	case PrincipalAPIKey:
+		for _, principal := range a.apiKeys {
+			if principal.Name == saved.Name {
+				return principal, nil
+			}
+		}
+	case PrincipalJWT:
+		if a.parser == nil {
+			break
+		}
+		// exp'siz kaydedilmiş eski kimlikler de süresi dolmuş sayılır
+		if saved.ExpiresAt == nil || time.Now().After(*saved.ExpiresAt) {
+			return nil, ErrCredentialExpired
+		}
+		return saved, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// Require, handler'ı kimlik doğrulama ve op yetkisi kontrolüyle sarar. Doğrulanan kimliğin adı X-Caller-ID
+// olarak isteğe yazılır, böylece istemcinin gönderdiği X-Caller-ID değeri yok sayılır.
+func (a *Authenticator) Require(op models.AuthOperation, next http.HandlerFunc) http.HandlerFunc {
+	return a.RequireByMethod(op, op, next)
+}
+
+// RequireByMethod, GET istekleri için read, diğer istekler için write yetkisi ister.
+func (a *Authenticator) RequireByMethod(read, write models.AuthOperation, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next(w, r)
+			return
+		}
+
+		principal, err := a.authenticate(r)
+		if err != nil {
+			log.Printf("Hata: Kimlik doğrulanamadı (%s %s): %v", r.Method, r.URL.Path, err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="go-smith"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		op := write
+		if r.Method == "GET" {
+			op = read
+		}
+		if !principal.Allows(op) {
+			log.Printf("Hata: '%s' kimliğinin '%s' yetkisi yok (%s %s)", principal.Name, op, r.Method, r.URL.Path)
+			http.Error(w, "Operation not allowed for this credential", http.StatusForbidden)
+			return
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+		r.Header.Set(CallerIDHeader, principal.Name)
+		next(w, r)
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// authenticate, "Authorization: Bearer <token>" ya da X-API-Key başlığındaki kimliği doğrular.
+// Üç parçalı token'lar JWT, diğerleri API anahtarı olarak değerlendirilir.
+func (a *Authenticator) authenticate(r *http.Request) (*Principal, error) {
+	token := r.Header.Get(APIKeyHeader)
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		scheme, value, ok := strings.Cut(auth, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, ErrInvalidCredentials
+		}
+		token = strings.TrimSpace(value)
+	}
+	if token == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	if strings.Count(token, ".") == 2 && a.parser != nil {
+		return a.authenticateJWT(token)
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	principal, ok := a.apiKeys[hex.EncodeToString(sum[:])]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
+
+func (a *Authenticator) authenticateJWT(token string) (*Principal, error) {
+	var claims jwtClaims
+	_, err := a.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return a.jwtSecret, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sub claim eksik", ErrInvalidCredentials)
+	}
+	// Parser exp'yi zorunlu tuttuğu için ExpiresAt her zaman doludur
+	expiresAt := claims.ExpiresAt.Time
+	return &Principal{Name: claims.Subject, Kind: PrincipalJWT, Agents: claims.Agents, Operations: claims.Operations, ExpiresAt: &expiresAt}, nil
+}
+
+// loadAPIKeys, statik API anahtarlarını dosyadan okur ve doğrular. Yanlış yazılmış bir alan ya da boş bir
+// anahtar listesi API'yi kimlik doğrulamasız bırakmasın diye hata sayılır.
+func (a *Authenticator) loadAPIKeys(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var config models.AuthConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return fmt.Errorf("auth dosyası okunamadı: %v", err)
+	}
+	if len(config.APIKeys) == 0 {
+		return fmt.Errorf("auth dosyasında API anahtarı yok: %s", path)
+	}
+
+	names := make(map[string]bool, len(config.APIKeys))
+	for _, key := range config.APIKeys {
+		if key.Name == "" {
+			return errors.New("API anahtarının name alanı boş")
+		}
+		if names[key.Name] {
+			return fmt.Errorf("API anahtarı adı tekrar ediyor: '%s'", key.Name)
+		}
+		names[key.Name] = true
+
+		hash := strings.ToLower(key.KeySHA256)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("'%s' anahtarının key_sha256 değeri geçersiz", key.Name)
+		}
+		for _, op := range key.Operations {
+			if op != models.AuthWildcard && !slices.Contains(models.AuthOperations, op) {
+				return fmt.Errorf("'%s' anahtarının işlemi geçersiz: '%s'", key.Name, op)
+			}
+		}
+		a.apiKeys[hash] = &Principal{Name: key.Name, Kind: PrincipalAPIKey, Agents: key.Agents, Operations: key.Operations}
+	}
+	return nil
+}
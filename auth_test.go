@@ -0,0 +1,358 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+const testJWTSecret = "test-secret"
+
+func keyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// writeAuthFile, içeriği geçici bir auth dosyasına yazar ve yolunu döndürür.
+func writeAuthFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	t.Helper()
+	path := writeAuthFile(t, `{"api_keys": [
+		{"name": "reader", "key_sha256": "`+keyHash("reader-key")+`", "agents": ["echo"], "operations": ["task_status"]},
+		{"name": "runner", "key_sha256": "`+keyHash("runner-key")+`", "agents": ["*"], "operations": ["run_task", "task_status"]}
+	]}`)
+	auth, err := NewAuthenticator(&OrchestratorConfig{
+		AuthConfigFile:  path,
+		AuthJWTSecret:   testJWTSecret,
+		AuthJWTIssuer:   "gateway",
+		AuthJWTAudience: "go-smith",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub":        "llm-gateway",
+			"iss":        "gateway",
+			"aud":        "go-smith",
+			"exp":        time.Now().Add(time.Hour).Unix(),
+			"agents":     []string{"echo"},
+			"operations": []string{"run_task"},
+		}
+	}
+	secret := []byte(testJWTSecret)
+
+	tests := []struct {
+		name   string
+		token  func() string
+		wantOK bool
+	}{
+		{"HS256 kabul edilir", func() string { return signToken(t, jwt.SigningMethodHS256, secret, valid()) }, true},
+		{"HS384 reddedilir", func() string { return signToken(t, jwt.SigningMethodHS384, secret, valid()) }, false},
+		{"alg none reddedilir", func() string {
+			return signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid())
+		}, false},
+		{"yanlış secret reddedilir", func() string { return signToken(t, jwt.SigningMethodHS256, []byte("other"), valid()) }, false},
+		{"exp olmadan reddedilir", func() string {
+			claims := valid()
+			delete(claims, "exp")
+			return signToken(t, jwt.SigningMethodHS256, secret, claims)
+		}, false},
+		{"süresi dolmuş reddedilir", func() string {
+			claims := valid()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return signToken(t, jwt.SigningMethodHS256, secret, claims)
+		}, false},
+		{"sub olmadan reddedilir", func() string {
+			claims := valid()
+			delete(claims, "sub")
+			return signToken(t, jwt.SigningMethodHS256, secret, claims)
+		}, false},
+		{"yanlış iss reddedilir", func() string {
+			claims := valid()
+			claims["iss"] = "someone-else"
+			return signToken(t, jwt.SigningMethodHS256, secret, claims)
+		}, false},
+		{"yanlış aud reddedilir", func() string {
+			claims := valid()
+			claims["aud"] = "other-service"
+			return signToken(t, jwt.SigningMethodHS256, secret, claims)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/tasks", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token())
+
+			principal, err := auth.authenticate(req)
+			if !tt.wantOK {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("ErrInvalidCredentials bekleniyordu, gelen: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token reddedildi: %v", err)
+			}
+			if principal.Name != "llm-gateway" || principal.Kind != PrincipalJWT {
+				t.Errorf("beklenmeyen kimlik: %+v", principal)
+			}
+			if !principal.AllowsAgent("echo") || principal.AllowsAgent("other") {
+				t.Errorf("agents claim'i uygulanmadı: %v", principal.Agents)
+			}
+			if principal.ExpiresAt == nil || principal.ExpiresAt.Before(time.Now()) {
+				t.Errorf("exp kimliğe yazılmadı: %v", principal.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		wantName string
+		wantErr  error
+	}{
+		{"X-API-Key", APIKeyHeader, "reader-key", "reader", nil},
+		{"Bearer", "Authorization", "Bearer runner-key", "runner", nil},
+		{"küçük harf bearer", "Authorization", "bearer runner-key", "runner", nil},
+		{"bilinmeyen anahtar", APIKeyHeader, "nope", "", ErrInvalidCredentials},
+		{"özetin kendisi anahtar değildir", APIKeyHeader, keyHash("reader-key"), "", ErrInvalidCredentials},
+		{"Basic şeması", "Authorization", "Basic cmVhZGVyLWtleQ==", "", ErrInvalidCredentials},
+		{"başlık yok", "", "", "", ErrMissingCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/tasks", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			principal, err := auth.authenticate(req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%v bekleniyordu, gelen: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Name != tt.wantName || principal.Kind != PrincipalAPIKey {
+				t.Errorf("beklenmeyen kimlik: %+v", principal)
+			}
+		})
+	}
+}
+
+func TestRequireByMethod(t *testing.T) {
+	auth := newTestAuthenticator(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		key        string
+		callerID   string
+		wantStatus int
+		wantCaller string
+	}{
+		{"anahtarsız istek", "GET", "", "", http.StatusUnauthorized, ""},
+		{"okuma yetkisi olan GET", "GET", "reader-key", "", http.StatusOK, "reader"},
+		{"okuma yetkisi olan POST", "POST", "reader-key", "", http.StatusForbidden, ""},
+		{"yazma yetkisi olan POST", "POST", "runner-key", "", http.StatusOK, "runner"},
+		{"gönderilen X-Caller-ID ezilir", "GET", "runner-key", "spoofed", http.StatusOK, "runner"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotCaller string
+			var gotPrincipal *Principal
+			handler := auth.RequireByMethod(models.OperationTaskStatus, models.OperationRunTask, func(w http.ResponseWriter, r *http.Request) {
+				gotCaller = r.Header.Get(CallerIDHeader)
+				gotPrincipal = principalFrom(r.Context())
+			})
+
+			req := httptest.NewRequest(tt.method, "/api/v1/workflows", nil)
+			if tt.key != "" {
+				req.Header.Set(APIKeyHeader, tt.key)
+			}
+			if tt.callerID != "" {
+				req.Header.Set(CallerIDHeader, tt.callerID)
+			}
+			recorder := httptest.NewRecorder()
+			handler(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("HTTP %d bekleniyordu, gelen: %d", tt.wantStatus, recorder.Code)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 cevabında WWW-Authenticate yok")
+			}
+			if gotCaller != tt.wantCaller {
+				t.Errorf("caller %q bekleniyordu, gelen: %q", tt.wantCaller, gotCaller)
+			}
+			if tt.wantStatus == http.StatusOK && (gotPrincipal == nil || gotPrincipal.Name != tt.wantCaller) {
+				t.Errorf("context'te kimlik yok: %+v", gotPrincipal)
+			}
+		})
+	}
+}
+
+func TestRequireDisabled(t *testing.T) {
+	auth, err := NewAuthenticator(&OrchestratorConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	handler := auth.Require(models.OperationAdmin, func(w http.ResponseWriter, r *http.Request) {
+		called = principalFrom(r.Context()) == nil
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/api/v1/agents", nil))
+	if !called {
+		t.Fatal("kimlik doğrulama kapalıyken istek kimliksiz geçmeli")
+	}
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	scoped := &Principal{Agents: []string{"echo"}, Operations: []models.AuthOperation{models.OperationRunTask}}
+	wildcard := &Principal{Agents: []string{models.AuthWildcard}, Operations: []models.AuthOperation{models.AuthWildcard}}
+	var disabled *Principal
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		agent     string
+		op        models.AuthOperation
+		wantAgent bool
+		wantOp    bool
+	}{
+		{"izinli agent ve işlem", scoped, "echo", models.OperationRunTask, true, true},
+		{"izinsiz agent ve işlem", scoped, "pdf_converter", models.OperationAdmin, false, false},
+		{"joker", wildcard, "pdf_converter", models.OperationAdmin, true, true},
+		{"kimlik doğrulama kapalı", disabled, "pdf_converter", models.OperationAdmin, true, true},
+		{"boş listeler", &Principal{}, "echo", models.OperationTools, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.AllowsAgent(tt.agent); got != tt.wantAgent {
+				t.Errorf("AllowsAgent(%q) = %t, beklenen %t", tt.agent, got, tt.wantAgent)
+			}
+			if got := tt.principal.Allows(tt.op); got != tt.wantOp {
+				t.Errorf("Allows(%q) = %t, beklenen %t", tt.op, got, tt.wantOp)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorCurrent(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		saved    *Principal
+		wantName string
+		wantErr  error
+	}{
+		{"mevcut API anahtarı", &Principal{Name: "runner", Kind: PrincipalAPIKey}, "runner", nil},
+		{"silinmiş API anahtarı", &Principal{Name: "revoked", Kind: PrincipalAPIKey}, "", ErrInvalidCredentials},
+		{"süresi dolmamış JWT", &Principal{Name: "llm-gateway", Kind: PrincipalJWT, ExpiresAt: &future}, "llm-gateway", nil},
+		{"süresi dolmuş JWT", &Principal{Name: "llm-gateway", Kind: PrincipalJWT, ExpiresAt: &past}, "", ErrCredentialExpired},
+		{"exp'siz kaydedilmiş JWT", &Principal{Name: "llm-gateway", Kind: PrincipalJWT}, "", ErrCredentialExpired},
+		{"kimliksiz zamanlama", nil, "", ErrMissingCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			principal, err := auth.Current(tt.saved)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("%v bekleniyordu, gelen: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if principal.Name != tt.wantName {
+				t.Errorf("%q bekleniyordu, gelen: %q", tt.wantName, principal.Name)
+			}
+		})
+	}
+}
+
+func TestLoadAPIKeysFailsClosed(t *testing.T) {
+	hash := keyHash("key")
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"bozuk JSON", `{"api_keys": [`},
+		{"yanlış yazılmış alan", `{"apikeys": [{"name": "a", "key_sha256": "` + hash + `"}]}`},
+		{"anahtarda bilinmeyen alan", `{"api_keys": [{"name": "a", "key": "plain", "key_sha256": "` + hash + `"}]}`},
+		{"boş anahtar listesi", `{"api_keys": []}`},
+		{"boş nesne", `{}`},
+		{"boş name", `{"api_keys": [{"name": "", "key_sha256": "` + hash + `"}]}`},
+		{"tekrar eden name", `{"api_keys": [{"name": "a", "key_sha256": "` + hash + `"}, {"name": "a", "key_sha256": "` + keyHash("other") + `"}]}`},
+		{"geçersiz özet", `{"api_keys": [{"name": "a", "key_sha256": "not-a-hash"}]}`},
+		{"kısa özet", `{"api_keys": [{"name": "a", "key_sha256": "abcd"}]}`},
+		{"geçersiz işlem", `{"api_keys": [{"name": "a", "key_sha256": "` + hash + `", "operations": ["delete_everything"]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAuthenticator(&OrchestratorConfig{AuthConfigFile: writeAuthFile(t, tt.content)})
+			if err == nil {
+				t.Fatal("hatalı auth dosyası kabul edildi")
+			}
+		})
+	}
+
+	t.Run("olmayan dosya", func(t *testing.T) {
+		_, err := NewAuthenticator(&OrchestratorConfig{AuthConfigFile: filepath.Join(t.TempDir(), "missing.json")})
+		if err == nil {
+			t.Fatal("olmayan auth dosyası kabul edildi")
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Agent replikaları arasında görev dağıtım stratejileri
+const (
+	LBRoundRobin    = "round_robin"
+	LBLeastInFlight = "least_in_flight"
+	LBWeighted      = "weighted"
+)
+
+// LoadBalancer, bir agent'ın replikaları arasından görevin gönderileceği endpoint'i seçer.
+// Durum agent adı ve replika URL'i ile tutulur, böylece config reload'dan sonra da geçerli kalır.
+type LoadBalancer struct {
+	mu sync.Mutex
+	// Round robin sayaçları (agent adı -> sayaç)
+	counters map[string]uint64
+	// Replikaya gönderilmiş ve henüz cevabı gelmemiş istekler ile replikada çalışan asenkron görevlerin
+	// sayısı (agent adı -> URL -> adet)
+	inFlight map[string]map[string]int
+	// Smooth weighted round robin için anlık ağırlıklar (agent adı -> URL -> ağırlık)
+	currentWeights map[string]map[string]int
+}
+
+func NewLoadBalancer() *LoadBalancer {
+	return &LoadBalancer{
+		counters:       make(map[string]uint64),
+		inFlight:       make(map[string]map[string]int),
+		currentWeights: make(map[string]map[string]int),
+	}
+}
+
+// Pick, agent'ın stratejisine göre adaylar arasından bir replika seçer ve in-flight sayacını artırır.
+// Dönen release fonksiyonu istek tamamlandığında, asenkron görevlerde görev bittiğinde çağrılmalıdır.
+func (lb *LoadBalancer) Pick(agent models.AgentDefinition, candidates []models.AgentEndpoint) (models.AgentEndpoint, func()) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	var chosen models.AgentEndpoint
+	switch {
+	case len(candidates) == 1:
+		chosen = candidates[0]
+	case agent.LoadBalancing == LBLeastInFlight:
+		chosen = lb.pickLeastInFlight(agent.Name, candidates)
+	case agent.LoadBalancing == LBWeighted:
+		chosen = lb.pickWeighted(agent.Name, candidates)
+	default:
+		chosen = candidates[lb.counters[agent.Name]%uint64(len(candidates))]
+		lb.counters[agent.Name]++
+	}
+
+	if lb.inFlight[agent.Name] == nil {
+		lb.inFlight[agent.Name] = make(map[string]int)
+	}
+	lb.inFlight[agent.Name][chosen.URL]++
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			lb.mu.Lock()
+			defer lb.mu.Unlock()
+			lb.inFlight[agent.Name][chosen.URL]--
+		})
+	}
+	return chosen, release
+}
+
+// ---------------------- HELPERS ----------------------
+
+// pickLeastInFlight, en az bekleyen isteği olan replikayı seçer. Eşitlikte listedeki ilk replika kazanır.
+func (lb *LoadBalancer) pickLeastInFlight(agentName string, candidates []models.AgentEndpoint) models.AgentEndpoint {
+	chosen := candidates[0]
+	least := lb.inFlight[agentName][chosen.URL]
+	for _, candidate := range candidates[1:] {
+		if count := lb.inFlight[agentName][candidate.URL]; count < least {
+			chosen, least = candidate, count
+		}
+	}
+	return chosen
+}
+
+// pickWeighted, nginx'in smooth weighted round robin algoritmasıyla seçim yapar; ağırlığı 3 olan
+// replika ağırlığı 1 olana göre üç kat fazla görev alır ve seçimler art arda yığılmaz.
+func (lb *LoadBalancer) pickWeighted(agentName string, candidates []models.AgentEndpoint) models.AgentEndpoint {
+	if lb.currentWeights[agentName] == nil {
+		lb.currentWeights[agentName] = make(map[string]int)
+	}
+	current := lb.currentWeights[agentName]
+
+	total := 0
+	best := -1
+	for i, candidate := range candidates {
+		weight := candidate.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		current[candidate.URL] += weight
+		if best == -1 || current[candidate.URL] > current[candidates[best].URL] {
+			best = i
+		}
+	}
+
+	current[candidates[best].URL] -= total
+	return candidates[best]
+}
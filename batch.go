@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// HandleBatchTask, LLM'in tek turda ürettiği birden fazla tool çağrısını /api/v1/run_tasks üzerinden alır.
+// Görevler BATCH_PARALLELISM sınırıyla paralel gönderilir, sonuçlar istekteki sırayla döner.
+// Hatalı bir görev yalnızca kendi sonucunda hata olarak görünür, diğerlerini etkilemez.
+func (o *Orchestrator) HandleBatchTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Öğeler tek tek çözülür, böylece hatalı bir öğe yalnızca kendi sonucunda 400 olarak görünür
+	var items []json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(items) == 0 {
+		http.Error(w, "Batch must contain at least one task", http.StatusBadRequest)
+		return
+	}
+	if o.Config.BatchMaxItems > 0 && len(items) > o.Config.BatchMaxItems {
+		http.Error(w, "Too many tasks in batch", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	caller := r.Header.Get(CallerIDHeader)
+	log.Printf("Toplu görev alındı: %d görev", len(items))
+
+	results := make([]models.BatchTaskResult, len(items))
+	slots := make(chan struct{}, o.Config.BatchParallelism)
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		// Yer goroutine başlatılmadan alınır, böylece aynı anda en fazla BATCH_PARALLELISM goroutine çalışır
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			var task models.OrchestratorTaskRequest
+			if err := json.Unmarshal(item, &task); err != nil {
+				log.Printf("Hata: Toplu görevin %d. öğesi çözülemedi: %v", i, err)
+				results[i] = batchError(http.StatusBadRequest, "Invalid task: "+err.Error())
+				return
+			}
+			if task.RunAt != nil || task.Schedule != "" {
+				results[i] = batchError(http.StatusBadRequest, "run_at and schedule are not supported in batches")
+				return
+			}
+			results[i] = o.submitTask(ctx, task, caller)
+		}()
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.BatchTaskResponse{Results: results})
+}
+
+// submitTask, tek bir görevi HandleTask ile aynı kontrollerden geçirip başlatır; toplu gönderim ve Scheduler
+// tarafından kullanılır. Senkron cevaplar her zaman zarflanır ve deftere yazılır, böylece her görevin sonucu
+// aynı biçimde döner. Kimlik ctx'ten okunur.
+func (o *Orchestrator) submitTask(ctx context.Context, task models.OrchestratorTaskRequest, caller string) models.BatchTaskResult {
+	agent, rejection := o.validateTask(ctx, task)
+	if rejection != nil {
+		result := batchError(rejection.HTTPStatus, rejection.Message)
+		result.Violations = rejection.Violations
+		return result
+	}
+
+	start, err := o.startTask(ctx, agent, task.Arguments, "", caller, task.Priority)
+	defer start.Close()
+
+	switch {
+	case errors.Is(err, errAgentStartResponse):
+		log.Printf("Hata: Agent'ın asenkron cevabı anlaşılamadı: %v", err)
+		return batchError(http.StatusInternalServerError, "Agent response parsing error")
+
+	case errors.Is(err, errTaskRegistration):
+		log.Printf("Hata: TaskRegistry'ye kayıt yapılamadı: %v", err)
+		return batchError(http.StatusInternalServerError, "Task registration error")
+
+	case err != nil:
+		log.Printf("Hata: Agent '%s' çağrılamadı: %v", agent.Name, err)
+		status := callErrorStatus(start.TaskID, err)
+		o.recordSyncTask(agent, start.Replica, caller, status)
+		return batchResultFromStatus(status.ErrorDetails.HTTPStatus, status)
+
+	case start.Accepted != nil:
+		return models.BatchTaskResult{
+			HTTPStatus:    http.StatusAccepted,
+			TaskID:        start.Accepted.TaskID,
+			Status:        start.Accepted.Status,
+			QueuePosition: start.Accepted.QueuePosition,
+		}
+	}
+
+	status, httpStatus, err := syncEnvelopeStatus(start.TaskID, start.Response)
+	if err != nil {
+		log.Printf("Hata: Agent '%s' cevabı okunamadı: %v", agent.Name, err)
+		status = callErrorStatus(start.TaskID, err)
+		httpStatus = status.ErrorDetails.HTTPStatus
+	}
+	o.recordSyncTask(agent, start.Replica, caller, status)
+	return batchResultFromStatus(httpStatus, status)
+}
+
+// ---------------------- HELPERS ----------------------
+
+func batchError(httpStatus int, message string) models.BatchTaskResult {
+	return models.BatchTaskResult{HTTPStatus: httpStatus, Error: message}
+}
+
+func batchResultFromStatus(httpStatus int, status models.TaskStatusResponse) models.BatchTaskResult {
+	return models.BatchTaskResult{
+		HTTPStatus:   httpStatus,
+		TaskID:       status.TaskID,
+		Status:       status.Status,
+		Result:       status.Result,
+		Error:        status.Error,
+		ErrorDetails: status.ErrorDetails,
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// newTestOrchestrator, verilen agent'ları bellekteki depoyla ve varsayılan ayarlarla çalışan bir Orchestrator'a ekler.
+func newTestOrchestrator(t *testing.T, agents ...models.AgentDefinition) *Orchestrator {
+	t.Helper()
+	cfg, err := NewOrchestratorConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.TaskStoreType = "memory"
+
+	registry := NewAgentRegistry()
+	for _, agent := range agents {
+		if err := registry.Add(agent, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewOrchestrator(registry, NewTaskRegistry(NewMemoryTaskStore(), cfg.TaskRetention), NewAgentTransports(), cfg)
+}
+
+func TestHandleBatchTaskItemErrors(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer agent.Close()
+	o := newTestOrchestrator(t, models.AgentDefinition{Name: "echo", Endpoint: agent.URL})
+
+	tests := []struct {
+		name       string
+		item       string
+		wantStatus int
+	}{
+		{"geçerli görev", `{"agent_name": "echo", "arguments": {}}`, http.StatusOK},
+		{"agent_name sayı", `{"agent_name": 5}`, http.StatusBadRequest},
+		{"priority sayı", `{"agent_name": "echo", "arguments": {"a": 1}, "priority": 3}`, http.StatusBadRequest},
+		{"okunamayan run_at", `{"agent_name": "echo", "run_at": "yarın"}`, http.StatusBadRequest},
+		{"geçerli run_at desteklenmez", `{"agent_name": "echo", "run_at": "2026-10-16T12:00:00Z"}`, http.StatusBadRequest},
+		{"bilinmeyen agent", `{"agent_name": "missing"}`, http.StatusNotFound},
+	}
+
+	items := make([]string, 0, len(tests))
+	for _, tt := range tests {
+		items = append(items, tt.item)
+	}
+	r := httptest.NewRequest("POST", "/api/v1/run_tasks", strings.NewReader("["+strings.Join(items, ",")+"]"))
+	recorder := httptest.NewRecorder()
+	o.HandleBatchTask(recorder, r)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("toplu cevap %d, beklenen %d: %s", recorder.Code, http.StatusOK, recorder.Body.String())
+	}
+	var resp models.BatchTaskResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != len(tests) {
+		t.Fatalf("%d sonuç, beklenen %d", len(resp.Results), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resp.Results[i].HTTPStatus; got != tt.wantStatus {
+				t.Errorf("http_status %d, beklenen %d (%s)", got, tt.wantStatus, resp.Results[i].Error)
+			}
+		})
+	}
+}
+
+func TestHandleBatchTaskRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"dizi olmayan gövde", `{"agent_name": "echo"}`, http.StatusBadRequest},
+		{"bozuk JSON", `[{"agent_name": "echo"`, http.StatusBadRequest},
+		{"boş dizi", `[]`, http.StatusBadRequest},
+		{"çok fazla görev", `[{}, {}, {}]`, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOrchestrator(t)
+			o.Config.BatchMaxItems = 2
+
+			recorder := httptest.NewRecorder()
+			o.HandleBatchTask(recorder, httptest.NewRequest("POST", "/api/v1/run_tasks", strings.NewReader(tt.body)))
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("%d, beklenen %d", recorder.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// BATCH_MAX_ITEMS sınırsızken de aynı anda en fazla BATCH_PARALLELISM görev gönderilir
+func TestHandleBatchTaskParallelism(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	defer agent.Close()
+
+	o := newTestOrchestrator(t, models.AgentDefinition{Name: "echo", Endpoint: agent.URL})
+	o.Config.BatchMaxItems = 0
+	o.Config.BatchParallelism = 2
+
+	body := "[" + strings.TrimSuffix(strings.Repeat(`{"agent_name": "echo"},`, 8), ",") + "]"
+	recorder := httptest.NewRecorder()
+	o.HandleBatchTask(recorder, httptest.NewRequest("POST", "/api/v1/run_tasks", strings.NewReader(body)))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("toplu cevap %d", recorder.Code)
+	}
+	if peak > 2 {
+		t.Errorf("aynı anda %d görev gönderildi, beklenen en fazla 2", peak)
+	}
+}
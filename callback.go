@@ -0,0 +1,225 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Görev gönderilirken agent'a iletilen callback başlıkları. Callback'i desteklemeyen agent'lar
+// bu başlıkları yok sayar ve durumları eskisi gibi polling ile sorgulanır.
+const (
+	CallbackURLHeader   = "X-Callback-URL"
+	CallbackTokenHeader = "X-Callback-Token"
+)
+
+// Deftere henüz yazılmamış görevler için gelen callback'lerin en fazla bekletileceği süre
+const heldCallbackTTL = 5 * time.Minute
+
+// CallbackSigner, her görev için callback token'ı üretir ve doğrular. Token, görev kimliğinin
+// gizli anahtarla alınmış HMAC-SHA256 imzasıdır; böylece token saklamaya gerek kalmaz.
+type CallbackSigner struct {
+	secret []byte
+}
+
+// NewCallbackSigner, verilen anahtarı kullanan bir imzalayıcı oluşturur. Anahtar boşsa rastgele
+// üretilir; bu durumda Orchestrator yeniden başladığında eski görevlerin token'ları geçersiz olur.
+func NewCallbackSigner(secret string) *CallbackSigner {
+	if secret != "" {
+		return &CallbackSigner{secret: []byte(secret)}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		log.Fatalf("Callback anahtarı üretilemedi: %v", err)
+	}
+	log.Println("Uyarı: CALLBACK_SECRET tanımlı değil, rastgele anahtar üretildi. Yeniden başlatmadan önce verilen callback token'ları geçersiz olacak.")
+	return &CallbackSigner{secret: random}
+}
+
+func (s *CallbackSigner) Token(taskID string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(taskID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *CallbackSigner) Verify(taskID, token string) bool {
+	return hmac.Equal([]byte(s.Token(taskID)), []byte(token))
+}
+
+// CallbackBuffer, agent'ın 202 cevabı deftere yazılmadan önce gelen callback'leri görev kaydedilene kadar bekletir.
+// Yalnızca Expect ile işaretlenmiş, o sırada agent'a gönderilmekte olan görevlerin callback'leri bekletilir;
+// gönderimi başarısız olan görevlerinkiler heldCallbackTTL sonunda silinir.
+type CallbackBuffer struct {
+	mu   sync.Mutex
+	held map[string]heldCallback
+	// Agent'a gönderilmekte olan, henüz deftere yazılmamış görevler
+	dispatching map[string]struct{}
+}
+
+type heldCallback struct {
+	status     models.TaskStatusResponse
+	receivedAt time.Time
+}
+
+func NewCallbackBuffer() *CallbackBuffer {
+	return &CallbackBuffer{
+		held:        make(map[string]heldCallback),
+		dispatching: make(map[string]struct{}),
+	}
+}
+
+// Expect, görevin agent'a gönderilmeye başlandığını işaretler; Done çağrılana kadar gelen callback'ler bekletilir.
+func (b *CallbackBuffer) Expect(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.dispatching[taskID] = struct{}{}
+}
+
+// Done, görevin gönderiminin bittiğini işaretler. Görev deftere yazıldıktan sonra ya da gönderim başarısız olunca çağrılır.
+func (b *CallbackBuffer) Done(taskID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.dispatching, taskID)
+}
+
+// Expecting, görevin o sırada gönderilmekte olduğunu, yani callback'inin bekletilmesi gerektiğini söyler.
+func (b *CallbackBuffer) Expecting(taskID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.dispatching[taskID]
+	return ok
+}
+
+// Hold, görevin bildirilen son durumunu saklar. Bitmiş bir durum sonradan gelen ara bir durumla ezilmez.
+func (b *CallbackBuffer) Hold(taskID string, status models.TaskStatusResponse) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	for id, held := range b.held {
+		if now.Sub(held.receivedAt) > heldCallbackTTL {
+			delete(b.held, id)
+		}
+	}
+	if held, ok := b.held[taskID]; ok && held.status.Status.Terminal() {
+		return
+	}
+	b.held[taskID] = heldCallback{status: status, receivedAt: now}
+}
+
+// Take, görev için bekletilen durumu döndürür ve siler.
+func (b *CallbackBuffer) Take(taskID string) (models.TaskStatusResponse, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	held, ok := b.held[taskID]
+	delete(b.held, taskID)
+	return held.status, ok
+}
+
+// HandleTaskCallback, agent'ların /api/v1/tasks/{id}/callback adresine gönderdiği durum güncellemelerini kaydeder.
+// Token, X-Callback-Token başlığında ya da "Authorization: Bearer" olarak gönderilebilir.
+func (o *Orchestrator) HandleTaskCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := r.PathValue("id")
+
+	// İmza görev aranmadan önce doğrulanır, böylece token'sız istekler görevlerin varlığını öğrenemez
+	if !o.Callbacks.Verify(taskID, callbackToken(r)) {
+		log.Printf("Hata: Geçersiz callback token'ı (TaskID %s)", taskID)
+		http.Error(w, "Invalid callback token", http.StatusUnauthorized)
+		return
+	}
+
+	var update models.TaskStatusResponse
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !update.Status.Valid() {
+		http.Error(w, "Invalid task status", http.StatusBadRequest)
+		return
+	}
+
+	// Agent, 202 cevabı deftere yazılmadan önce callback gönderebilir; güncelleme görev kaydedilince uygulanır.
+	// Görev bu arada kaydedildiyse bekletilen durum burada uygulanır, böylece iki taraf da güncellemeyi kaçırmaz.
+	// Gönderilmekte olmayan bilinmeyen görevler (ör. defterden silinmiş olanlar) 404 alır.
+	if o.HeldCallbacks.Expecting(taskID) {
+		o.HeldCallbacks.Hold(taskID, update)
+		if !o.HeldCallbacks.Expecting(taskID) {
+			o.applyHeldCallback(taskID)
+		}
+		log.Printf("Callback görev kaydından önce geldi, bekletiliyor: TaskID %s -> %s", taskID, update.Status)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := o.TaskRegistry.UpdateStatus(taskID, update); err != nil {
+		switch {
+		case errors.Is(err, ErrTaskNotFound):
+			http.Error(w, "Task not found", http.StatusNotFound)
+		case errors.Is(err, ErrTaskFinished):
+			http.Error(w, "Task already finished", http.StatusConflict)
+		default:
+			log.Printf("Hata: Callback durumu kaydedilemedi (TaskID %s): %v", taskID, err)
+			http.Error(w, "Task update error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	log.Printf("Callback alındı: TaskID %s -> %s", taskID, update.Status)
+	update.TaskID = taskID
+	o.Events.Publish(taskID, update)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ---------------------- HELPERS ----------------------
+
+// setCallbackHeaders, CALLBACK_BASE_URL tanımlıysa görevin callback adresini ve token'ını başlıklara ekler.
+func (o *Orchestrator) setCallbackHeaders(header http.Header, taskID string) {
+	if o.Config.CallbackBaseURL == "" {
+		return
+	}
+	header.Set(CallbackURLHeader, o.Config.CallbackBaseURL+"/api/v1/tasks/"+url.PathEscape(taskID)+"/callback")
+	header.Set(CallbackTokenHeader, o.Callbacks.Token(taskID))
+}
+
+// applyHeldCallback, görev için bekletilen callback varsa deftere yazar ve abonelere iletir.
+func (o *Orchestrator) applyHeldCallback(taskID string) {
+	update, ok := o.HeldCallbacks.Take(taskID)
+	if !ok {
+		return
+	}
+	if err := o.TaskRegistry.UpdateStatus(taskID, update); err != nil {
+		if !errors.Is(err, ErrTaskFinished) {
+			log.Printf("Hata: Bekletilen callback kaydedilemedi (TaskID %s): %v", taskID, err)
+		}
+		return
+	}
+	log.Printf("Bekletilen callback uygulandı: TaskID %s -> %s", taskID, update.Status)
+	update.TaskID = taskID
+	o.Events.Publish(taskID, update)
+}
+
+func callbackToken(r *http.Request) string {
+	if token := r.Header.Get(CallbackTokenHeader); token != "" {
+		return token
+	}
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return token
+}
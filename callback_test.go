@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+func TestHandleTaskCallback(t *testing.T) {
+	agent := models.AgentDefinition{Name: "echo", Endpoint: "http://echo:9000"}
+
+	tests := []struct {
+		name string
+		// Callback gelmeden önce görevin durumu: "unknown", "dispatching" ya da agent'ın 202 cevabındaki durum
+		state      string
+		wantStatus int
+		// Callback'ten sonra (bekletildiyse görev kaydedildikten sonra) defterdeki durum
+		wantTaskStatus models.TaskStatus
+	}{
+		{"bilinmeyen görev 404", "unknown", http.StatusNotFound, ""},
+		{"gönderilen görevin callback'i bekletilir", "dispatching", http.StatusAccepted, models.StatusCompleted},
+		{"kayıtlı görev güncellenir", string(models.StatusRunning), http.StatusNoContent, models.StatusCompleted},
+		{"agent'ın queued cevabı beklemede sayılır", string(models.StatusQueued), http.StatusNoContent, models.StatusCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOrchestrator(t, agent)
+			taskID := NewTaskID()
+
+			switch tt.state {
+			case "unknown":
+			case "dispatching":
+				o.HeldCallbacks.Expect(taskID)
+			default:
+				start := models.TaskStartResponse{TaskID: "agent-1", Status: models.TaskStatus(tt.state)}
+				if err := o.TaskRegistry.RegisterTask(taskID, start, agent, agent.Endpoint, ""); err != nil {
+					t.Fatal(err)
+				}
+				info, _ := o.TaskRegistry.GetTaskInfo(taskID)
+				if info.LastStatus.Status == models.StatusQueued {
+					t.Fatal("agent'ın queued durumu deftere queued olarak yazıldı")
+				}
+			}
+
+			r := httptest.NewRequest("POST", "/api/v1/tasks/"+taskID+"/callback", strings.NewReader(`{"status": "completed", "result": {"ok": true}}`))
+			r.SetPathValue("id", taskID)
+			r.Header.Set("Authorization", "Bearer "+o.Callbacks.Token(taskID))
+			recorder := httptest.NewRecorder()
+			o.HandleTaskCallback(recorder, r)
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("%d, beklenen %d", recorder.Code, tt.wantStatus)
+			}
+
+			if tt.state == "dispatching" {
+				// Görev agent'ın 202 cevabıyla kaydedilince bekletilen durum uygulanır
+				if err := o.TaskRegistry.RegisterTask(taskID, models.TaskStartResponse{TaskID: "agent-1"}, agent, agent.Endpoint, ""); err != nil {
+					t.Fatal(err)
+				}
+				o.HeldCallbacks.Done(taskID)
+				o.applyHeldCallback(taskID)
+			}
+
+			info, ok := o.TaskRegistry.GetTaskInfo(taskID)
+			if tt.wantTaskStatus == "" {
+				if ok {
+					t.Error("bilinmeyen görev deftere yazıldı")
+				}
+				return
+			}
+			if !ok || info.LastStatus.Status != tt.wantTaskStatus {
+				t.Errorf("defterdeki durum %+v, beklenen %s", info.LastStatus, tt.wantTaskStatus)
+			}
+		})
+	}
+}
+
+func TestHandleTaskCallbackInvalidToken(t *testing.T) {
+	o := newTestOrchestrator(t)
+	taskID := NewTaskID()
+
+	r := httptest.NewRequest("POST", "/api/v1/tasks/"+taskID+"/callback", strings.NewReader(`{"status": "completed"}`))
+	r.SetPathValue("id", taskID)
+	r.Header.Set(CallbackTokenHeader, o.Callbacks.Token("other-task"))
+	recorder := httptest.NewRecorder()
+	o.HandleTaskCallback(recorder, r)
+
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("%d, beklenen %d", recorder.Code, http.StatusUnauthorized)
+	}
+}
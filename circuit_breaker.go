@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type circuitState string
+
+const (
+	circuitClosed   circuitState = "closed"
+	circuitOpen     circuitState = "open"
+	circuitHalfOpen circuitState = "half_open"
+)
+
+// circuitBreaker, tek bir agent replikasının breaker durumudur.
+type circuitBreaker struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// Half-open durumda yalnızca tek bir deneme isteğine izin verilir
+	probeInFlight bool
+}
+
+// CircuitBreakers, her agent replikası için ayrı bir breaker tutar. Art arda hata veren replikaya
+// istek atmak cooldown süresince kesilir, cooldown bitince tek bir deneme isteğiyle (half-open)
+// replikanın düzelip düzelmediği kontrol edilir.
+type CircuitBreakers struct {
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	// Agent kendi ayarını tanımlamadığında kullanılan varsayılan; FailureThreshold 0 ise breaker kapalıdır
+	defaults models.CircuitBreakerPolicy
+}
+
+func NewCircuitBreakers(defaults models.CircuitBreakerPolicy) *CircuitBreakers {
+	return &CircuitBreakers{
+		breakers: make(map[string]*circuitBreaker),
+		defaults: defaults,
+	}
+}
+
+// Allow, replikaya istek atılıp atılamayacağını söyler. Breaker açıksa ErrCircuitOpen döner.
+// Allow nil döndürdüyse isteğin sonucu mutlaka Record ile bildirilmelidir.
+func (c *CircuitBreakers) Allow(agent models.AgentDefinition, replica string) error {
+	policy := c.policyFor(agent)
+	if policy.FailureThreshold <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.breakerFor(agent.Name, replica)
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < policy.Cooldown.Std() {
+			return ErrCircuitOpen
+		}
+		b.state = circuitHalfOpen
+		b.probeInFlight = true
+		log.Printf("Circuit breaker half-open: Agent '%s' (%s), deneme isteği gönderiliyor", agent.Name, replica)
+		return nil
+	case circuitHalfOpen:
+		if b.probeInFlight {
+			return ErrCircuitOpen
+		}
+		b.probeInFlight = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Record, Allow ile izin verilen isteğin sonucunu işler. counted false ise (ör. istemci isteği iptal etti)
+// sonuç breaker'ı etkilemez, yalnızca half-open deneme hakkı serbest bırakılır.
+func (c *CircuitBreakers) Record(agent models.AgentDefinition, replica string, success, counted bool) {
+	policy := c.policyFor(agent)
+	if policy.FailureThreshold <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.breakerFor(agent.Name, replica)
+	b.probeInFlight = false
+	if !counted {
+		return
+	}
+
+	if success {
+		if b.state != circuitClosed {
+			log.Printf("Circuit breaker kapandı: Agent '%s' (%s) tekrar cevap veriyor", agent.Name, replica)
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= policy.FailureThreshold {
+		if b.state != circuitOpen {
+			log.Printf("Circuit breaker açıldı: Agent '%s' (%s), %d ardışık hata, %s boyunca istek atılmayacak",
+				agent.Name, replica, b.failures, policy.Cooldown.Std())
+		}
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Closed, breaker'ı açık olan replikaları adaylardan çıkarır. Cooldown'u dolmuş replikalar
+// half-open denemesi yapılabilsin diye listede kalır.
+func (c *CircuitBreakers) Closed(agent models.AgentDefinition, candidates []models.AgentEndpoint) []models.AgentEndpoint {
+	policy := c.policyFor(agent)
+	if policy.FailureThreshold <= 0 {
+		return candidates
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	allowed := make([]models.AgentEndpoint, 0, len(candidates))
+	for _, candidate := range candidates {
+		b, ok := c.breakers[breakerKey(agent.Name, candidate.URL)]
+		if ok && b.state == circuitOpen && time.Since(b.openedAt) < policy.Cooldown.Std() {
+			continue
+		}
+		if ok && b.state == circuitHalfOpen && b.probeInFlight {
+			continue
+		}
+		allowed = append(allowed, candidate)
+	}
+	return allowed
+}
+
+// RetryAfter, replikanın breaker'ı açıksa cooldown'un bitmesine kalan süreyi döndürür.
+// replica boşsa agent'ın replikaları arasında en erken açılacak olanın süresi döner.
+func (c *CircuitBreakers) RetryAfter(agent models.AgentDefinition, replica string) time.Duration {
+	policy := c.policyFor(agent)
+
+	replicas := []string{replica}
+	if replica == "" {
+		replicas = replicas[:0]
+		for _, endpoint := range agent.Replicas() {
+			replicas = append(replicas, endpoint.URL)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var earliest time.Duration
+	for i, url := range replicas {
+		b, ok := c.breakers[breakerKey(agent.Name, url)]
+		if !ok || b.state != circuitOpen {
+			return 0
+		}
+		remaining := max(policy.Cooldown.Std()-time.Since(b.openedAt), 0)
+		if i == 0 || remaining < earliest {
+			earliest = remaining
+		}
+	}
+	return earliest
+}
+
+// ---------------------- HELPERS ----------------------
+
+func (c *CircuitBreakers) policyFor(agent models.AgentDefinition) models.CircuitBreakerPolicy {
+	if agent.CircuitBreaker != nil {
+		return *agent.CircuitBreaker
+	}
+	return c.defaults
+}
+
+// breakerFor, kilit tutulurken çağrılmalıdır.
+func (c *CircuitBreakers) breakerFor(agentName, replica string) *circuitBreaker {
+	key := breakerKey(agentName, replica)
+	b, ok := c.breakers[key]
+	if !ok {
+		b = &circuitBreaker{state: circuitClosed}
+		c.breakers[key] = b
+	}
+	return b
+}
+
+func breakerKey(agentName, replica string) string {
+	return agentName + "|" + replica
+}
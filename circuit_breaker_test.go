@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Test breaker'larının cooldown süresi
+const testBreakerCooldown = 30 * time.Millisecond
+
+// breakerStep, breaker'a sırayla uygulanan tek bir adımdır.
+type breakerStep struct {
+	// "allow", "success", "failure", "canceled" ya da "cooldown"
+	op string
+	// "allow" adımında Allow'un ErrCircuitOpen dönmesi bekleniyor mu
+	wantOpen bool
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []breakerStep
+		// Son adımdan sonra breaker'ın durumu
+		want circuitState
+	}{
+		{
+			name:  "eşiğin altındaki hatalar breaker'ı açmaz",
+			steps: []breakerStep{{op: "allow"}, {op: "failure"}, {op: "allow"}, {op: "failure"}, {op: "allow"}},
+			want:  circuitClosed,
+		},
+		{
+			name:  "başarı hata sayacını sıfırlar",
+			steps: []breakerStep{{op: "failure"}, {op: "failure"}, {op: "success"}, {op: "failure"}, {op: "failure"}, {op: "allow"}},
+			want:  circuitClosed,
+		},
+		{
+			name:  "ardışık hatalar breaker'ı açar",
+			steps: []breakerStep{{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "allow", wantOpen: true}},
+			want:  circuitOpen,
+		},
+		{
+			name:  "iptal edilen istekler sayılmaz",
+			steps: []breakerStep{{op: "failure"}, {op: "failure"}, {op: "canceled"}, {op: "canceled"}, {op: "allow"}},
+			want:  circuitClosed,
+		},
+		{
+			name: "cooldown sonunda tek deneme isteğine izin verilir",
+			steps: []breakerStep{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "cooldown"},
+				{op: "allow"}, {op: "allow", wantOpen: true},
+			},
+			want: circuitHalfOpen,
+		},
+		{
+			name: "başarılı deneme breaker'ı kapatır",
+			steps: []breakerStep{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "cooldown"},
+				{op: "allow"}, {op: "success"}, {op: "allow"}, {op: "allow"},
+			},
+			want: circuitClosed,
+		},
+		{
+			name: "başarısız deneme breaker'ı yeniden açar",
+			steps: []breakerStep{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "cooldown"},
+				{op: "allow"}, {op: "failure"}, {op: "allow", wantOpen: true},
+			},
+			want: circuitOpen,
+		},
+		{
+			name: "iptal edilen deneme hakkı geri verir",
+			steps: []breakerStep{
+				{op: "failure"}, {op: "failure"}, {op: "failure"}, {op: "cooldown"},
+				{op: "allow"}, {op: "canceled"}, {op: "allow"},
+			},
+			want: circuitHalfOpen,
+		},
+	}
+
+	agent := models.AgentDefinition{
+		Name:           "echo",
+		CircuitBreaker: &models.CircuitBreakerPolicy{FailureThreshold: 3, Cooldown: models.Duration(testBreakerCooldown)},
+	}
+	const replica = "http://echo:9000"
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCircuitBreakers(models.CircuitBreakerPolicy{})
+			for i, step := range tt.steps {
+				switch step.op {
+				case "allow":
+					err := c.Allow(agent, replica)
+					if open := errors.Is(err, ErrCircuitOpen); open != step.wantOpen {
+						t.Fatalf("adım %d: Allow hatası %v, açık bekleniyor: %t", i, err, step.wantOpen)
+					}
+				case "success":
+					c.Record(agent, replica, true, true)
+				case "failure":
+					c.Record(agent, replica, false, true)
+				case "canceled":
+					c.Record(agent, replica, false, false)
+				case "cooldown":
+					time.Sleep(testBreakerCooldown + 10*time.Millisecond)
+				}
+			}
+
+			if got := c.breakerFor(agent.Name, replica).state; got != tt.want {
+				t.Errorf("durum %s, beklenen %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults models.CircuitBreakerPolicy
+		agent    *models.CircuitBreakerPolicy
+	}{
+		{"varsayılan eşik 0", models.CircuitBreakerPolicy{}, nil},
+		{"agent'ın eşiği 0", models.CircuitBreakerPolicy{FailureThreshold: 1}, &models.CircuitBreakerPolicy{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCircuitBreakers(tt.defaults)
+			agent := models.AgentDefinition{Name: "echo", CircuitBreaker: tt.agent}
+			for range 5 {
+				c.Record(agent, "http://echo:9000", false, true)
+			}
+			if err := c.Allow(agent, "http://echo:9000"); err != nil {
+				t.Errorf("breaker kapalıyken Allow hata verdi: %v", err)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerClosedAndRetryAfter(t *testing.T) {
+	agent := models.AgentDefinition{
+		Name:           "echo",
+		Endpoints:      []models.AgentEndpoint{{URL: "http://a:9000"}, {URL: "http://b:9000"}},
+		CircuitBreaker: &models.CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: models.Duration(time.Minute)},
+	}
+
+	tests := []struct {
+		name string
+		// Breaker'ı açılan replikalar
+		open []string
+		want []string
+		// RetryAfter(agent, "") dolu olmalı mı
+		wantRetryAfter bool
+	}{
+		{"hiçbiri açık değil", nil, []string{"http://a:9000", "http://b:9000"}, false},
+		{"bir replika açık", []string{"http://a:9000"}, []string{"http://b:9000"}, false},
+		{"hepsi açık", []string{"http://a:9000", "http://b:9000"}, []string{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCircuitBreakers(models.CircuitBreakerPolicy{})
+			for _, replica := range tt.open {
+				c.Record(agent, replica, false, true)
+			}
+
+			var got []string
+			for _, replica := range c.Closed(agent, agent.Replicas()) {
+				got = append(got, replica.URL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("adaylar %v, beklenen %v", got, tt.want)
+			}
+
+			retryAfter := c.RetryAfter(agent, "")
+			if (retryAfter > 0) != tt.wantRetryAfter || retryAfter > time.Minute {
+				t.Errorf("RetryAfter %s", retryAfter)
+			}
+			for _, replica := range tt.open {
+				if d := c.RetryAfter(agent, replica); d <= 0 || d > time.Minute {
+					t.Errorf("%s için RetryAfter %s", replica, d)
+				}
+			}
+		})
+	}
+}
+
+// Breaker açıkken run_task agent'ı çağırmadan 503 ve Retry-After döner
+func TestHandleTaskCircuitOpenRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	o := newTestOrchestrator(t, models.AgentDefinition{
+		Name:           "echo",
+		Endpoint:       server.URL,
+		CircuitBreaker: &models.CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: models.Duration(30 * time.Second)},
+	})
+
+	tests := []struct {
+		name           string
+		wantStatus     int
+		wantRetryAfter bool
+	}{
+		{"agent'ın 500 cevabı aktarılır", http.StatusInternalServerError, false},
+		{"breaker açıkken 503", http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			o.HandleTask(recorder, httptest.NewRequest("POST", "/api/v1/run_task", strings.NewReader(`{"agent_name": "echo"}`)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("%d, beklenen %d", recorder.Code, tt.wantStatus)
+			}
+			header := recorder.Header().Get("Retry-After")
+			if !tt.wantRetryAfter {
+				if header != "" {
+					t.Errorf("beklenmeyen Retry-After: %s", header)
+				}
+				return
+			}
+			seconds, err := strconv.Atoi(header)
+			if err != nil || seconds < 1 || seconds > 30 {
+				t.Errorf("Retry-After %q, 1 ile 30 arasında bekleniyordu", header)
+			}
+		})
+	}
+	if calls != 1 {
+		t.Errorf("agent %d kez çağrıldı, beklenen 1", calls)
+	}
+}
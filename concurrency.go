@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+var (
+	ErrAgentQueueFull    = errors.New("agent queue is full")
+	ErrAgentQueueTimeout = errors.New("agent queue wait timed out")
+	ErrQueuedTaskStopped = errors.New("queued task stopped before dispatch")
+)
+
+// Agent kuyruklarının metrikleri /debug/vars altında "agent_queues" anahtarıyla, agent adına göre yayınlanır
+var queueMetrics = expvar.NewMap("agent_queues")
+
+// queueTicket, agent'ın kuyruğunda bekleyen tek bir görevdir.
+type queueTicket struct {
+	taskID   string
+	agent    *agentLimiter
+	priority models.TaskPriority
+	queuedAt time.Time
+	// Sırası geldiğinde bir değer yazılır
+	ready chan struct{}
+	// Görev kuyruktayken durdurulduğunda kapatılır
+	stopped chan struct{}
+}
+
+// agentLimiter, tek bir agent'ın eşzamanlılık sınırını ve öncelik sırasına göre bekleyen görevlerini tutar.
+type agentLimiter struct {
+	limit    int
+	maxQueue int
+	active   int
+	queues   map[models.TaskPriority][]*queueTicket
+	// Smooth weighted round robin için önceliklerin anlık ağırlıkları
+	currentWeights map[models.TaskPriority]int
+
+	queuedTotal int64
+	rejected    int64
+	waitTotal   time.Duration
+	waitMax     time.Duration
+}
+
+// ConcurrencyLimiter, max_concurrency tanımlı agent'lara aynı anda gönderilen görev sayısını sınırlar.
+// Sınır doluyken gelen görevler max_queue kadar kuyrukta bekler, kuyruk da doluysa reddedilir.
+// Boşalan yer öncelikler arasında ağırlıklarına göre paylaştırılır. Asenkron görevler yerlerini bitene kadar tutar.
+type ConcurrencyLimiter struct {
+	mu      sync.Mutex
+	agents  map[string]*agentLimiter
+	tickets map[string]*queueTicket
+	weights map[models.TaskPriority]int
+	// Bir görevin kuyrukta en fazla bekleyebileceği süre; 0 ise süresiz
+	queueTimeout time.Duration
+}
+
+func NewConcurrencyLimiter(queueTimeout time.Duration, weights map[models.TaskPriority]int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{
+		agents:       make(map[string]*agentLimiter),
+		tickets:      make(map[string]*queueTicket),
+		weights:      weights,
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Reserve, agent'ta görev için yer ayırır. Yer varsa release döner ve görev hemen gönderilebilir. Yer yoksa
+// görev kuyruğa alınır ve sırası Wait ile beklenecek bilet döner. Kuyruk doluysa ErrAgentQueueFull döner.
+// release, yer artık kullanılmadığında çağrılmalıdır, birden fazla çağrılması sorun değildir.
+func (l *ConcurrencyLimiter) Reserve(agent models.AgentDefinition, priority models.TaskPriority, taskID string) (func(), *queueTicket, error) {
+	if agent.MaxConcurrency <= 0 {
+		return func() {}, nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := l.limiterFor(agent)
+	l.grantLocked(a)
+
+	queued := a.queued()
+	if a.active < a.limit && queued == 0 {
+		a.active++
+		return l.releaser(a), nil, nil
+	}
+	if queued >= a.maxQueue {
+		a.rejected++
+		log.Printf("Agent '%s' kuyruğu dolu, görev reddedildi (%d çalışan, %d bekleyen)", agent.Name, a.active, queued)
+		return nil, nil, ErrAgentQueueFull
+	}
+
+	ticket := &queueTicket{
+		taskID:   taskID,
+		agent:    a,
+		priority: priority,
+		queuedAt: time.Now(),
+		ready:    make(chan struct{}, 1),
+		stopped:  make(chan struct{}),
+	}
+	a.queues[priority] = append(a.queues[priority], ticket)
+	a.queuedTotal++
+	l.tickets[taskID] = ticket
+	log.Printf("Agent '%s' dolu, görev kuyruğa alındı: TaskID %s (öncelik: %s, sıra: %d)", agent.Name, taskID, priority, l.positionLocked(ticket))
+	return nil, ticket, nil
+}
+
+// Wait, kuyruktaki görevin sırası gelene kadar bekler. Görev kuyruktayken durdurulursa ErrQueuedTaskStopped,
+// bekleme süresi dolarsa ErrAgentQueueTimeout döner.
+func (l *ConcurrencyLimiter) Wait(ticket *queueTicket) (func(), error) {
+	var timeout <-chan time.Time
+	if l.queueTimeout > 0 {
+		timer := time.NewTimer(l.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
+	select {
+	case <-ticket.ready:
+	case <-ticket.stopped:
+		err = ErrQueuedTaskStopped
+	case <-timeout:
+		err = ErrAgentQueueTimeout
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a := ticket.agent
+	wait := time.Since(ticket.queuedAt)
+	a.waitTotal += wait
+	a.waitMax = max(a.waitMax, wait)
+	delete(l.tickets, ticket.taskID)
+
+	switch {
+	case err == nil:
+		return l.releaser(a), nil
+	case errors.Is(err, ErrQueuedTaskStopped) || l.removeLocked(ticket):
+		return nil, err
+	default:
+		// Süre dolarken sıra da gelmişse ayrılan yer bir sonrakine bırakılır
+		a.active--
+		l.grantLocked(a)
+		return nil, err
+	}
+}
+
+// Position, kuyruktaki görevin sırasını döndürür; 1 sıradaki ilk görevdir. Görev kuyrukta değilse ok false olur.
+func (l *ConcurrencyLimiter) Position(taskID string) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ticket, ok := l.tickets[taskID]
+	if !ok {
+		return 0, false
+	}
+	position := l.positionLocked(ticket)
+	return position, position > 0
+}
+
+// Stop, görevi henüz gönderilmemişse kuyruktan çıkarır. Görev kuyrukta değilse false döner.
+func (l *ConcurrencyLimiter) Stop(taskID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ticket, ok := l.tickets[taskID]
+	if !ok || !l.removeLocked(ticket) {
+		return false
+	}
+	delete(l.tickets, taskID)
+	close(ticket.stopped)
+	return true
+}
+
+// RetryAfter, kuyruğu dolu agent için istemciye önerilecek bekleme süresidir: kuyrukta geçen ortalama süre, en az 1 saniye.
+func (l *ConcurrencyLimiter) RetryAfter(agentName string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	a, ok := l.agents[agentName]
+	if !ok || a.queuedTotal == 0 {
+		return time.Second
+	}
+	return max(a.waitTotal/time.Duration(a.queuedTotal), time.Second)
+}
+
+// releaseWhenFinished, asenkron görevin tuttuğu yeri görev bitene, defterden silinene ya da AGENT_SLOT_MAX_HOLD
+// dolana kadar bırakmaz. Bitiş callback'lerden ve diğer abonelerin yoklamalarından hemen öğrenilir; agent'ın
+// durumu ise yalnızca AGENT_SLOT_CHECK_INTERVAL'da bir sorulur, böylece SSE poller'ı arka planda çalıştırılmaz.
+func (o *Orchestrator) releaseWhenFinished(taskID string, release func()) {
+	defer release()
+
+	ctx := context.Background()
+	if hold := o.Config.AgentSlotMaxHold; hold > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hold)
+		defer cancel()
+	}
+
+	// Kayıttan sonra gelen bitiş bildirimleri kaçırılmasın diye defter abonelikten sonra okunur
+	finished, stop := o.Events.NotifyFinished(taskID)
+	defer stop()
+
+	ticker := time.NewTicker(o.Config.AgentSlotCheckInterval)
+	defer ticker.Stop()
+
+	if info, ok := o.TaskRegistry.GetTaskInfo(taskID); !ok || taskFinished(info) {
+		return
+	}
+	// Agent'ın görevi art arda tanımadığı sorgular; görev başarısız sayılmaz, yalnızca yeri bırakılır
+	misses := 0
+	for {
+		select {
+		case <-finished:
+			return
+		case <-ctx.Done():
+			log.Printf("Uyarı: Görev %s içinde bitmedi, görevin yeri bırakılıyor (TaskID %s)", o.Config.AgentSlotMaxHold, taskID)
+			return
+		case <-ticker.C:
+		}
+		if o.slotTaskFinished(ctx, taskID, &misses) {
+			return
+		}
+		if misses >= taskLostThreshold {
+			log.Printf("Uyarı: Agent görevi %d kez tanımadı, görevin yeri bırakılıyor (TaskID %s)", misses, taskID)
+			return
+		}
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// limiterFor, agent'ın sınırlayıcısını döndürür; yoksa oluşturur. Sınırlar her çağrıda agent tanımından
+// güncellenir, böylece config reload'da yeniden başlatmaya gerek kalmaz. l.mu tutulurken çağrılmalıdır.
+func (l *ConcurrencyLimiter) limiterFor(agent models.AgentDefinition) *agentLimiter {
+	a, ok := l.agents[agent.Name]
+	if !ok {
+		a = &agentLimiter{
+			queues:         make(map[models.TaskPriority][]*queueTicket),
+			currentWeights: make(map[models.TaskPriority]int),
+		}
+		l.agents[agent.Name] = a
+		queueMetrics.Set(agent.Name, expvar.Func(func() any { return l.stats(a) }))
+	}
+	a.limit = agent.MaxConcurrency
+	a.maxQueue = agent.MaxQueue
+	return a
+}
+
+// slotTaskFinished, yer tutan görevin bittiğini ya da defterden silindiğini söyler. Defterde bitmemiş görünen
+// görevin durumu agent'a sorulur; sorgu başarısız olursa görev bitmemiş sayılır ve sonraki turda tekrar sorulur.
+// misses, agent'ın görevi art arda kaç kez tanımadığını (404) sayar ve başka bir cevapta sıfırlanır.
+func (o *Orchestrator) slotTaskFinished(ctx context.Context, taskID string, misses *int) bool {
+	info, ok := o.TaskRegistry.GetTaskInfo(taskID)
+	if !ok || taskFinished(info) {
+		return true
+	}
+
+	result, err := o.fetchAgentStatus(ctx, info)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Uyarı: Yer tutan görevin durumu sorulamadı (TaskID %s): %v", taskID, err)
+		}
+		return false
+	}
+	if result.StatusCode == http.StatusNotFound {
+		*misses++
+		return false
+	}
+	*misses = 0
+	if result.StatusCode < 200 || result.StatusCode > 299 {
+		return false
+	}
+	// fetchAgentStatus başarılı cevabı deftere yazdığı için güncel durum defterden okunur
+	info, ok = o.TaskRegistry.GetTaskInfo(taskID)
+	return !ok || taskFinished(info)
+}
+
+// grantLocked, boş yer kaldıkça kuyruktan seçilen görevlere yer verir. l.mu tutulurken çağrılmalıdır.
+func (l *ConcurrencyLimiter) grantLocked(a *agentLimiter) {
+	for a.active < a.limit {
+		priority, ok := l.pickPriority(a.queues, a.currentWeights)
+		if !ok {
+			return
+		}
+		next := a.queues[priority][0]
+		a.queues[priority] = a.queues[priority][1:]
+		a.active++
+		next.ready <- struct{}{}
+	}
+}
+
+// pickPriority, bekleyen görevi olan öncelikler arasından smooth weighted round robin ile seçim yapar.
+// Ağırlığı 8 olan öncelik ağırlığı 1 olana göre sekiz kat fazla yer alır, düşük öncelik de payına düşen sırayı alır.
+func (l *ConcurrencyLimiter) pickPriority(queues map[models.TaskPriority][]*queueTicket, current map[models.TaskPriority]int) (models.TaskPriority, bool) {
+	total := 0
+	var best models.TaskPriority
+	for _, priority := range models.TaskPriorities {
+		if len(queues[priority]) == 0 {
+			continue
+		}
+		weight := max(l.weights[priority], 1)
+		total += weight
+		current[priority] += weight
+		if best == "" || current[priority] > current[best] {
+			best = priority
+		}
+	}
+	if best == "" {
+		return "", false
+	}
+	current[best] -= total
+	return best, true
+}
+
+// positionLocked, kuyruğun bundan sonra nasıl boşalacağını anlık ağırlıklarla canlandırarak görevin sırasını
+// bulur. Sonradan gelen yüksek öncelikli görevler sırayı değiştirebilir. l.mu tutulurken çağrılmalıdır.
+func (l *ConcurrencyLimiter) positionLocked(ticket *queueTicket) int {
+	a := ticket.agent
+	queues := make(map[models.TaskPriority][]*queueTicket, len(a.queues))
+	for priority, queue := range a.queues {
+		queues[priority] = queue
+	}
+	current := make(map[models.TaskPriority]int, len(a.currentWeights))
+	for priority, weight := range a.currentWeights {
+		current[priority] = weight
+	}
+
+	for position := 1; ; position++ {
+		priority, ok := l.pickPriority(queues, current)
+		if !ok {
+			return 0
+		}
+		if queues[priority][0] == ticket {
+			return position
+		}
+		queues[priority] = queues[priority][1:]
+	}
+}
+
+// removeLocked, bileti kuyruktan çıkarır. Bilet kuyrukta değilse (sırası gelmişse) false döner.
+func (l *ConcurrencyLimiter) removeLocked(ticket *queueTicket) bool {
+	queue := ticket.agent.queues[ticket.priority]
+	i := slices.Index(queue, ticket)
+	if i < 0 {
+		return false
+	}
+	ticket.agent.queues[ticket.priority] = slices.Delete(queue, i, i+1)
+	return true
+}
+
+func (l *ConcurrencyLimiter) releaser(a *agentLimiter) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			a.active--
+			l.grantLocked(a)
+		})
+	}
+}
+
+func (a *agentLimiter) queued() int {
+	queued := 0
+	for _, queue := range a.queues {
+		queued += len(queue)
+	}
+	return queued
+}
+
+func (l *ConcurrencyLimiter) stats(a *agentLimiter) map[string]any {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	queuedByPriority := make(map[models.TaskPriority]int, len(models.TaskPriorities))
+	for _, priority := range models.TaskPriorities {
+		queuedByPriority[priority] = len(a.queues[priority])
+	}
+
+	var avgWait time.Duration
+	if a.queuedTotal > 0 {
+		avgWait = a.waitTotal / time.Duration(a.queuedTotal)
+	}
+	return map[string]any{
+		"max_concurrency":    a.limit,
+		"max_queue":          a.maxQueue,
+		"active":             a.active,
+		"queued":             a.queued(),
+		"queued_by_priority": queuedByPriority,
+		"queued_total":       a.queuedTotal,
+		"rejected":           a.rejected,
+		"wait_ms_avg":        avgWait.Milliseconds(),
+		"wait_ms_max":        a.waitMax.Milliseconds(),
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+var testWeights = map[models.TaskPriority]int{models.PriorityHigh: 8, models.PriorityNormal: 4, models.PriorityLow: 1}
+
+const (
+	high   = models.PriorityHigh
+	normal = models.PriorityNormal
+	low    = models.PriorityLow
+)
+
+func limitedAgent(name string, limit, queue int) models.AgentDefinition {
+	return models.AgentDefinition{Name: name, MaxConcurrency: limit, MaxQueue: queue}
+}
+
+// mustQueue, agent doluyken görevi kuyruğa alır ve biletini döndürür.
+func mustQueue(t *testing.T, l *ConcurrencyLimiter, agent models.AgentDefinition, priority models.TaskPriority, taskID string) *queueTicket {
+	t.Helper()
+	release, ticket, err := l.Reserve(agent, priority, taskID)
+	if err != nil || release != nil || ticket == nil {
+		t.Fatalf("%s kuyruğa alınmadı (release: %t, err: %v)", taskID, release != nil, err)
+	}
+	return ticket
+}
+
+func TestPickPriorityWeightedOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights map[models.TaskPriority]int
+		queued  []models.TaskPriority
+		want    []models.TaskPriority
+	}{
+		{
+			name:    "8/4/1 ağırlıklar",
+			weights: testWeights,
+			queued:  []models.TaskPriority{high, normal, low},
+			want:    []models.TaskPriority{high, normal, high, high, normal, high, low, high, normal, high, high, normal, high},
+		},
+		{
+			name:    "yüksek öncelik bekleyen yokken",
+			weights: testWeights,
+			queued:  []models.TaskPriority{normal, low},
+			want:    []models.TaskPriority{normal, normal, low, normal, normal},
+		},
+		{
+			name:    "tek öncelik",
+			weights: testWeights,
+			queued:  []models.TaskPriority{low},
+			want:    []models.TaskPriority{low, low, low},
+		},
+		{
+			name:    "sıfır ağırlık 1 sayılır",
+			weights: map[models.TaskPriority]int{models.PriorityHigh: 1},
+			queued:  []models.TaskPriority{high, low},
+			want:    []models.TaskPriority{high, low, high, low},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewConcurrencyLimiter(0, tt.weights)
+			// Kuyruklar hiç boşalmasın diye her öncelikte yeterince bilet tutulur
+			queues := make(map[models.TaskPriority][]*queueTicket)
+			for _, priority := range tt.queued {
+				queues[priority] = make([]*queueTicket, len(tt.want))
+			}
+			current := make(map[models.TaskPriority]int)
+
+			for i, want := range tt.want {
+				got, ok := l.pickPriority(queues, current)
+				if !ok || got != want {
+					t.Fatalf("%d. seçim %q (ok: %t), beklenen %q", i+1, got, ok, want)
+				}
+			}
+		})
+	}
+
+	t.Run("boş kuyruk", func(t *testing.T) {
+		l := NewConcurrencyLimiter(0, testWeights)
+		if _, ok := l.pickPriority(map[models.TaskPriority][]*queueTicket{}, map[models.TaskPriority]int{}); ok {
+			t.Fatal("boş kuyruktan seçim yapıldı")
+		}
+	})
+}
+
+func TestQueuePosition(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued []models.TaskPriority
+		// Görevlerin sırası, kuyruğa alınma sırasıyla
+		want []int
+	}{
+		{"tek öncelik sırayla", []models.TaskPriority{normal, normal, normal}, []int{1, 2, 3}},
+		{"sonra gelen yüksek öncelik öne geçer", []models.TaskPriority{low, normal, high}, []int{3, 2, 1}},
+		{"düşük öncelik payına düşen sırayı alır", []models.TaskPriority{low, high, high, high}, []int{4, 1, 2, 3}},
+		{"ağırlıklar araya karışır", []models.TaskPriority{normal, normal, high, high, high}, []int{2, 5, 1, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewConcurrencyLimiter(0, testWeights)
+			agent := limitedAgent("position-"+tt.name, 1, len(tt.queued))
+			if _, _, err := l.Reserve(agent, normal, "running"); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, priority := range tt.queued {
+				mustQueue(t, l, agent, priority, fmt.Sprintf("task-%d", i))
+			}
+			for i, want := range tt.want {
+				got, ok := l.Position(fmt.Sprintf("task-%d", i))
+				if !ok || got != want {
+					t.Errorf("task-%d (%s) sırası %d (ok: %t), beklenen %d", i, tt.queued[i], got, ok, want)
+				}
+			}
+		})
+	}
+
+	t.Run("sırası gelen görev kuyruktan çıkar", func(t *testing.T) {
+		l := NewConcurrencyLimiter(0, testWeights)
+		agent := limitedAgent("position-granted", 1, 2)
+		release, _, _ := l.Reserve(agent, normal, "running")
+		first := mustQueue(t, l, agent, normal, "first")
+		mustQueue(t, l, agent, normal, "second")
+
+		release()
+		if _, err := l.Wait(first); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := l.Position("first"); ok {
+			t.Error("gönderilen görev hâlâ kuyrukta görünüyor")
+		}
+		if got, ok := l.Position("second"); !ok || got != 1 {
+			t.Errorf("second sırası %d (ok: %t), beklenen 1", got, ok)
+		}
+	})
+
+	t.Run("kuyruk dolu", func(t *testing.T) {
+		l := NewConcurrencyLimiter(0, testWeights)
+		agent := limitedAgent("position-full", 1, 1)
+		l.Reserve(agent, normal, "running")
+		mustQueue(t, l, agent, normal, "queued")
+		if _, _, err := l.Reserve(agent, high, "rejected"); !errors.Is(err, ErrAgentQueueFull) {
+			t.Fatalf("ErrAgentQueueFull bekleniyordu, gelen: %v", err)
+		}
+	})
+}
+
+func TestWaitStopAndTimeout(t *testing.T) {
+	t.Run("kuyruktayken durdurma", func(t *testing.T) {
+		l := NewConcurrencyLimiter(0, testWeights)
+		agent := limitedAgent("wait-stop", 1, 1)
+		release, _, _ := l.Reserve(agent, normal, "running")
+		ticket := mustQueue(t, l, agent, normal, "queued")
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := l.Wait(ticket)
+			done <- err
+		}()
+		if !l.Stop("queued") {
+			t.Fatal("kuyruktaki görev durdurulamadı")
+		}
+		if err := <-done; !errors.Is(err, ErrQueuedTaskStopped) {
+			t.Fatalf("ErrQueuedTaskStopped bekleniyordu, gelen: %v", err)
+		}
+		if l.Stop("queued") {
+			t.Error("görev ikinci kez durduruldu")
+		}
+
+		// Durdurulan görev yer tutmaz
+		release()
+		assertActive(t, l, agent, 0)
+	})
+
+	t.Run("yer verildikten sonra durdurma", func(t *testing.T) {
+		l := NewConcurrencyLimiter(0, testWeights)
+		agent := limitedAgent("wait-stop-granted", 1, 1)
+		release, _, _ := l.Reserve(agent, normal, "running")
+		ticket := mustQueue(t, l, agent, normal, "queued")
+
+		// Yer verildi ama Wait henüz dönmedi; görev artık kuyrukta değildir ve gönderilecektir
+		release()
+		if l.Stop("queued") {
+			t.Fatal("yer verilmiş görev kuyruktan durduruldu")
+		}
+		granted, err := l.Wait(ticket)
+		if err != nil {
+			t.Fatalf("yer verilmiş görev beklenemedi: %v", err)
+		}
+		assertActive(t, l, agent, 1)
+		granted()
+		assertActive(t, l, agent, 0)
+	})
+
+	t.Run("bekleme süresi dolması", func(t *testing.T) {
+		l := NewConcurrencyLimiter(20*time.Millisecond, testWeights)
+		agent := limitedAgent("wait-timeout", 1, 1)
+		release, _, _ := l.Reserve(agent, normal, "running")
+		ticket := mustQueue(t, l, agent, normal, "queued")
+
+		if _, err := l.Wait(ticket); !errors.Is(err, ErrAgentQueueTimeout) {
+			t.Fatalf("ErrAgentQueueTimeout bekleniyordu, gelen: %v", err)
+		}
+		if _, ok := l.Position("queued"); ok {
+			t.Error("süresi dolan görev hâlâ kuyrukta")
+		}
+
+		release()
+		assertActive(t, l, agent, 0)
+	})
+
+	// Süre dolarken yer de verilmişse hangisinin seçildiğine bakılmaksızın yer kaybolmamalı
+	t.Run("süre dolarken yer verilmesi", func(t *testing.T) {
+		for i := range 50 {
+			l := NewConcurrencyLimiter(time.Nanosecond, testWeights)
+			agent := limitedAgent(fmt.Sprintf("wait-race-%d", i), 1, 1)
+			release, _, _ := l.Reserve(agent, normal, "running")
+			ticket := mustQueue(t, l, agent, normal, "queued")
+			release()
+
+			granted, err := l.Wait(ticket)
+			switch {
+			case err == nil:
+				granted()
+			case !errors.Is(err, ErrAgentQueueTimeout):
+				t.Fatalf("beklenmeyen hata: %v", err)
+			}
+			assertActive(t, l, agent, 0)
+		}
+	})
+}
+
+func assertActive(t *testing.T, l *ConcurrencyLimiter, agent models.AgentDefinition, want int) {
+	t.Helper()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if got := l.agents[agent.Name].active; got != want {
+		t.Fatalf("agent '%s' için %d aktif görev bekleniyordu, gelen: %d", agent.Name, want, got)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// OrchestratorConfig, Orchestrator'ın environment değişkenlerinden okunan ayarlarını tutar.
+type OrchestratorConfig struct {
+	// Düz HTTP listener'ın adresi; boşsa kapalıdır ve API yalnızca TLS listener'dan sunulur
+	ListenAddress   string
+	AgentConfigFile string
+	// Boş değilse API ayrıca bu adreste TLS ile sunulur; TLSClientCAFile verilirse istemci sertifikası zorunludur
+	TLSListenAddress string
+	TLSCertFile      string
+	TLSKeyFile       string
+	TLSClientCAFile  string
+	TLSMinVersion    string
+	// "file" ya da "memory"
+	TaskStoreType string
+	TaskStorePath string
+	// Zamanlanmış görevlerin saklandığı dosya
+	ScheduleStorePath string
+	// Kapalıyken kaçırılan zamanlanmış çalıştırmalar için "skip", "once" ya da "all"
+	ScheduleCatchUp string
+	// Agent API değişikliklerinin varsayılan olarak config dosyasına yazılıp yazılmayacağı
+	AgentAPIPersist bool
+
+	// Agent health check ayarları
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+	HealthyThreshold    int
+	UnhealthyThreshold  int
+	// "hide" sağlıksız agent'ları tool listesinden çıkarır, "mark" ise available: false ile işaretler
+	UnhealthyToolsMode string
+
+	// Agent kendi circuit_breaker ayarını tanımlamadığında kullanılır; eşik 0 ise breaker kapalıdır
+	CircuitBreakerFailureThreshold int
+	CircuitBreakerCooldown         time.Duration
+
+	// Agent kendi zaman aşımını tanımlamadığında kullanılan varsayılanlar
+	DispatchTimeout time.Duration
+	StatusTimeout   time.Duration
+	StopTimeout     time.Duration
+	// max_concurrency tanımlı agent'lara gönderimlerin kuyrukta en fazla bekleyebileceği süre
+	AgentQueueTimeout time.Duration
+	// Asenkron bir görevin max_concurrency yerini en fazla tutabileceği süre; 0 ise görev bitene kadar tutar
+	AgentSlotMaxHold time.Duration
+	// Yer tutan asenkron görevin durumunun agent'a sorulma aralığı; callback'ler görevin bitişini daha önce bildirir
+	AgentSlotCheckInterval time.Duration
+	// Kuyruktaki önceliklerin ağırlıkları; düşük öncelik de ağırlığı oranında sıra alır, böylece aç kalmaz
+	QueuePriorityWeights map[models.TaskPriority]int
+
+	// Görev defterinin temizlik ayarları
+	TaskRetention  TaskRetention
+	TaskGCInterval time.Duration
+
+	// SSE abonesi olan görevlerin agent'tan yoklanma aralığı, agent öneri vermediğinde istemciye önerilen bekleme süresi de budur
+	TaskEventsPollInterval time.Duration
+	// Biten görevlerin sonucunun Orchestrator'dan sunulacağı süre; 0 ise süresiz
+	TaskResultTTL time.Duration
+	// Devam eden görevlerde agent'ın durum cevabının tekrar kullanılacağı süre
+	TaskStatusCacheTTL time.Duration
+	// task_status?wait= ile bir isteğin en fazla bekletilebileceği süre
+	TaskStatusMaxWait time.Duration
+
+	// run_tasks ile tek istekte gönderilebilecek görev sayısı ve aynı anda agent'a gönderilenlerin üst sınırı
+	BatchMaxItems    int
+	BatchParallelism int
+
+	// Statik API anahtarlarının dosyası ve HS256 JWT ayarları; ikisi de boşsa API kimlik doğrulamasız açıktır
+	AuthConfigFile  string
+	AuthJWTSecret   string
+	AuthJWTIssuer   string
+	AuthJWTAudience string
+
+	// Idempotency-Key taşıyan run_task cevaplarının saklanacağı süre; 0 ise anahtarlar hatırlanmaz
+	IdempotencyTTL time.Duration
+	// Bellekte tutulan Idempotency-Key kayıtlarının üst sınırı; 0 ise sınır yoktur
+	IdempotencyMaxEntries int
+
+	// Agent auth'undaki file referanslarının bulunabileceği dizin; boşsa file referansları kabul edilmez
+	AgentSecretsDir string
+
+	// Agent'ların durum bildirmek için çağıracağı Orchestrator adresi; boşsa callback başlıkları gönderilmez
+	CallbackBaseURL string
+	// Callback token'larını imzalayan anahtar; boşsa her açılışta rastgele üretilir
+	CallbackSecret string
+}
+
+func NewOrchestratorConfig() (*OrchestratorConfig, error) {
+	agentAPIPersist, err := envBool("AGENT_API_PERSIST", false)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &OrchestratorConfig{
+		ListenAddress:      listenAddress(),
+		AgentConfigFile:    envOrDefault("AGENT_CONFIG_FILE", "config/agents.json"),
+		TaskStoreType:      envOrDefault("TASK_STORE_TYPE", "file"),
+		TaskStorePath:      envOrDefault("TASK_STORE_PATH", "data/tasks.log"),
+		ScheduleStorePath:  envOrDefault("SCHEDULE_STORE_PATH", "data/schedules.json"),
+		ScheduleCatchUp:    envOrDefault("SCHEDULE_CATCH_UP", CatchUpOnce),
+		AgentAPIPersist:    agentAPIPersist,
+		UnhealthyToolsMode: envOrDefault("HEALTH_UNHEALTHY_TOOLS_MODE", "hide"),
+		CallbackBaseURL:    strings.TrimSuffix(os.Getenv("CALLBACK_BASE_URL"), "/"),
+		CallbackSecret:     os.Getenv("CALLBACK_SECRET"),
+		TLSListenAddress:   os.Getenv("TLS_LISTEN_ADDR"),
+		TLSCertFile:        os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:         os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile:    os.Getenv("TLS_CLIENT_CA_FILE"),
+		TLSMinVersion:      os.Getenv("TLS_MIN_VERSION"),
+		AuthConfigFile:     os.Getenv("AUTH_CONFIG_FILE"),
+		AuthJWTSecret:      os.Getenv("AUTH_JWT_SECRET"),
+		AuthJWTIssuer:      os.Getenv("AUTH_JWT_ISSUER"),
+		AuthJWTAudience:    os.Getenv("AUTH_JWT_AUDIENCE"),
+		AgentSecretsDir:    os.Getenv("AGENT_SECRETS_DIR"),
+	}
+
+	if cfg.HealthCheckInterval, err = envDuration("HEALTH_CHECK_INTERVAL", 15*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HealthCheckTimeout, err = envDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.HealthyThreshold, err = envInt("HEALTH_HEALTHY_THRESHOLD", 2); err != nil {
+		return nil, err
+	}
+	if cfg.UnhealthyThreshold, err = envInt("HEALTH_UNHEALTHY_THRESHOLD", 3); err != nil {
+		return nil, err
+	}
+	if cfg.CircuitBreakerFailureThreshold, err = envInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", 5); err != nil {
+		return nil, err
+	}
+	if cfg.CircuitBreakerCooldown, err = envDuration("CIRCUIT_BREAKER_COOLDOWN", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.DispatchTimeout, err = envDuration("AGENT_DISPATCH_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.StatusTimeout, err = envDuration("AGENT_STATUS_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.StopTimeout, err = envDuration("AGENT_STOP_TIMEOUT", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.AgentQueueTimeout, err = envDuration("AGENT_QUEUE_TIMEOUT", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.AgentSlotMaxHold, err = envDuration("AGENT_SLOT_MAX_HOLD", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.AgentSlotCheckInterval, err = envDuration("AGENT_SLOT_CHECK_INTERVAL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.QueuePriorityWeights, err = envPriorityWeights("QUEUE_PRIORITY_WEIGHTS", "high=8,normal=4,low=1"); err != nil {
+		return nil, err
+	}
+	if cfg.TaskRetention.TerminalTTL, err = envDuration("TASK_TTL_TERMINAL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TaskRetention.ActiveTTL, err = envDuration("TASK_TTL_ACTIVE", 7*24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.TaskRetention.MaxEntries, err = envInt("TASK_MAX_ENTRIES", 100000); err != nil {
+		return nil, err
+	}
+	if cfg.TaskGCInterval, err = envDuration("TASK_GC_INTERVAL", time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.TaskEventsPollInterval, err = envDuration("TASK_EVENTS_POLL_INTERVAL", 2*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.TaskResultTTL, err = envDuration("TASK_RESULT_TTL", 0); err != nil {
+		return nil, err
+	}
+	if cfg.TaskStatusCacheTTL, err = envDuration("TASK_STATUS_CACHE_TTL", time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.TaskStatusMaxWait, err = envDuration("TASK_STATUS_MAX_WAIT", 60*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.BatchMaxItems, err = envInt("BATCH_MAX_ITEMS", 50); err != nil {
+		return nil, err
+	}
+	if cfg.BatchParallelism, err = envInt("BATCH_PARALLELISM", 8); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyTTL, err = envDuration("IDEMPOTENCY_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.IdempotencyMaxEntries, err = envInt("IDEMPOTENCY_MAX_ENTRIES", 10000); err != nil {
+		return nil, err
+	}
+	if cfg.ListenAddress == "" && cfg.TLSListenAddress == "" {
+		return nil, fmt.Errorf("ORCHESTRATOR_LISTEN_ADDR kapalıyken TLS_LISTEN_ADDR tanımlanmalı")
+	}
+	if cfg.HealthCheckInterval <= 0 {
+		return nil, fmt.Errorf("HEALTH_CHECK_INTERVAL sıfırdan büyük olmalı: %s", cfg.HealthCheckInterval)
+	}
+	if cfg.TaskGCInterval <= 0 {
+		return nil, fmt.Errorf("TASK_GC_INTERVAL sıfırdan büyük olmalı: %s", cfg.TaskGCInterval)
+	}
+	if cfg.AgentSlotCheckInterval <= 0 {
+		return nil, fmt.Errorf("AGENT_SLOT_CHECK_INTERVAL sıfırdan büyük olmalı: %s", cfg.AgentSlotCheckInterval)
+	}
+	if cfg.TaskEventsPollInterval <= 0 {
+		return nil, fmt.Errorf("TASK_EVENTS_POLL_INTERVAL sıfırdan büyük olmalı: %s", cfg.TaskEventsPollInterval)
+	}
+	if cfg.BatchParallelism < 1 {
+		return nil, fmt.Errorf("BATCH_PARALLELISM en az 1 olmalı: %d", cfg.BatchParallelism)
+	}
+	if cfg.ScheduleCatchUp != CatchUpSkip && cfg.ScheduleCatchUp != CatchUpOnce && cfg.ScheduleCatchUp != CatchUpAll {
+		return nil, fmt.Errorf("SCHEDULE_CATCH_UP geçersiz: %s", cfg.ScheduleCatchUp)
+	}
+	if cfg.UnhealthyToolsMode != "hide" && cfg.UnhealthyToolsMode != "mark" {
+		return nil, fmt.Errorf("HEALTH_UNHEALTHY_TOOLS_MODE geçersiz: %s", cfg.UnhealthyToolsMode)
+	}
+
+	return cfg, nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// listenAddress, ORCHESTRATOR_LISTEN_ADDR'i okur. Boş ya da "off" değeri düz HTTP listener'ı kapatır. Değişken
+// hiç verilmemişse TLS_CLIENT_CA_FILE tanımlıyken kapalıdır, böylece mTLS istenen kurulumda API sertifikasız açık kalmaz.
+func listenAddress() string {
+	value, ok := os.LookupEnv("ORCHESTRATOR_LISTEN_ADDR")
+	switch {
+	case !ok && os.Getenv("TLS_CLIENT_CA_FILE") != "":
+		return ""
+	case !ok:
+		return ":8080"
+	case value == "off":
+		return ""
+	}
+	return value
+}
+
+func envInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s geçersiz: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// envDuration, "15s", "500ms" gibi Go duration formatındaki değerleri okur.
+func envDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s geçersiz: %v", key, err)
+	}
+	return parsed, nil
+}
+
+func envBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s geçersiz: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// envPriorityWeights, "high=8,normal=4,low=1" biçimindeki öncelik ağırlıklarını okur. Verilmeyen öncelikler
+// varsayılan ağırlığını korur, ağırlıklar en az 1 olmalıdır.
+func envPriorityWeights(key, fallback string) (map[models.TaskPriority]int, error) {
+	weights := make(map[models.TaskPriority]int)
+	for _, value := range []string{fallback, os.Getenv(key)} {
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			name, rawWeight, ok := strings.Cut(pair, "=")
+			priority := models.TaskPriority(strings.TrimSpace(name))
+			weight, err := strconv.Atoi(strings.TrimSpace(rawWeight))
+			if !ok || !priority.Valid() || err != nil || weight < 1 {
+				return nil, fmt.Errorf("%s geçersiz: %s", key, pair)
+			}
+			weights[priority] = weight
+		}
+	}
+	return weights, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Editörler dosyayı birkaç adımda yazdığı için ardışık olaylar bu süre boyunca tek bir reload'da birleştirilir.
+const configReloadDebounce = 300 * time.Millisecond
+
+// ReloadResult, son konfigürasyon yeniden yükleme denemesinin sonucudur.
+type ReloadResult struct {
+	Trigger    string           `json:"trigger"`
+	Time       time.Time        `json:"time"`
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+	Diff       *AgentConfigDiff `json:"diff,omitempty"`
+	AgentCount int              `json:"agent_count"`
+}
+
+// ConfigReloader, agents.json dosyasını izler ve değiştiğinde AgentRegistry'yi yeniden yükler.
+type ConfigReloader struct {
+	Registry   *AgentRegistry
+	ConfigFile string
+
+	mu         sync.Mutex
+	lastResult *ReloadResult
+}
+
+func NewConfigReloader(registry *AgentRegistry, configFile string) *ConfigReloader {
+	return &ConfigReloader{
+		Registry:   registry,
+		ConfigFile: configFile,
+	}
+}
+
+// Reload, config dosyasını okuyup doğrular ve geçerliyse registry içeriğini atomik olarak değiştirir.
+// Dosya geçersizse registry'deki eski konfigürasyon olduğu gibi kalır.
+func (c *ConfigReloader) Reload(trigger string) ReloadResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := ReloadResult{
+		Trigger: trigger,
+		Time:    time.Now(),
+	}
+
+	definitions, err := ReadAgentConfig(c.ConfigFile)
+	var diff AgentConfigDiff
+	if err == nil {
+		diff, err = c.Registry.Replace(definitions)
+	}
+
+	if err != nil {
+		result.Error = err.Error()
+		result.AgentCount = c.Registry.Count()
+		log.Printf("Hata: Agent konfigürasyonu yeniden yüklenemedi (%s), eski konfigürasyon korunuyor: %v", trigger, err)
+	} else {
+		result.Success = true
+		result.Diff = &diff
+		result.AgentCount = len(definitions)
+		log.Printf("Agent konfigürasyonu yeniden yüklendi (%s): %d eklendi %v, %d silindi %v, %d değişti %v",
+			trigger,
+			len(diff.Added), diff.Added,
+			len(diff.Removed), diff.Removed,
+			len(diff.Changed), diff.Changed)
+	}
+
+	c.lastResult = &result
+	return result
+}
+
+// LastResult, en son reload denemesinin sonucunu döndürür. Henüz reload yapılmadıysa nil döner.
+func (c *ConfigReloader) LastResult() *ReloadResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastResult
+}
+
+// Watch, config dosyasının bulunduğu klasörü izler ve dosya değiştiğinde reload tetikler.
+// Editörlerin dosyayı silip yeniden oluşturması da yakalansın diye dosyanın kendisi değil klasörü izlenir.
+func (c *ConfigReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(c.ConfigFile)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(absPath)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != absPath {
+					continue
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				debounce = time.After(configReloadDebounce)
+
+			case <-debounce:
+				debounce = nil
+				c.Reload("file_watch")
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Hata: Config izleyici hatası: %v", err)
+			}
+		}
+	}()
+
+	log.Printf("Agent konfigürasyonu izleniyor: %s", absPath)
+	return nil
+}
+
+// HandleConfigReload, GET ile son reload sonucunu gösterir, POST ile elle reload tetikler.
+func (c *ConfigReloader) HandleConfigReload(w http.ResponseWriter, r *http.Request) {
+	var result *ReloadResult
+
+	switch r.Method {
+	case "GET":
+		result = c.LastResult()
+	case "POST":
+		reloaded := c.Reload("admin_api")
+		result = &reloaded
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result == nil {
+		json.NewEncoder(w).Encode(map[string]any{
+			"config_file": c.ConfigFile,
+			"agent_count": c.Registry.Count(),
+		})
+		return
+	}
+	if r.Method == "POST" && !result.Success {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
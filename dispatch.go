@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Zaman aşımı ayrı ayarlanabilen agent işlemleri
+type agentOperation string
+
+const (
+	opDispatch agentOperation = "dispatch"
+	opStatus   agentOperation = "status"
+	opStop     agentOperation = "stop"
+)
+
+// RetryPolicy'de kod listesi verilmediğinde tekrar denenen HTTP durum kodları
+var defaultRetryableStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// dispatchTask, görevi agent'ın replikalarından birine gönderir ve cevabı veren replikanın adresini döndürür.
+// Her denemede replika yeniden seçilir, böylece tekrar deneme düşen replika yerine sağlıklı olana gidebilir.
+// Tekrar deneme yalnızca idempotent true ise (istek bir Idempotency-Key taşıyorsa) uygulanır.
+// Dönen release, son seçilen replikanın in-flight sayacını azaltır ve hata olsa bile çağrılmalıdır; asenkron
+// kabul edilen görevlerde görev bitene kadar çağrılmaz, böylece least_in_flight çalışan görevleri de sayar.
+func (o *Orchestrator) dispatchTask(ctx context.Context, agent models.AgentDefinition, body []byte, header http.Header, idempotent bool) (*http.Response, string, func(), error) {
+	var replicaURL string
+	release := func() {}
+
+	resp, err := o.withRetry(ctx, agent, string(opDispatch), idempotent, func() (*http.Response, error) {
+		// Önceki denemenin replikası artık isteği taşımıyor
+		release()
+		release = func() {}
+
+		candidates := o.Breakers.Closed(agent, o.Registry.AvailableReplicas(agent))
+		if len(candidates) == 0 {
+			replicaURL = ""
+			return nil, ErrCircuitOpen
+		}
+
+		var replica models.AgentEndpoint
+		replica, release = o.Balancer.Pick(agent, candidates)
+		replicaURL = replica.URL
+		return o.send(ctx, agent, replica.URL, "POST", replica.URL, body, header)
+	})
+	return resp, replicaURL, release, err
+}
+
+// callReplica, görevi kabul etmiş replikaya durum ya da durdurma isteği gönderir.
+func (o *Orchestrator) callReplica(ctx context.Context, agent models.AgentDefinition, replica, method, targetURL string, idempotent bool) (*http.Response, error) {
+	return o.withRetry(ctx, agent, method+" "+targetURL, idempotent, func() (*http.Response, error) {
+		return o.send(ctx, agent, replica, method, targetURL, nil, nil)
+	})
+}
+
+// withTimeout, işlemin agent'a özel ya da genel zaman aşımını context'e ekler. Zaman aşımı tekrar
+// denemeler dahil işlemin tamamını kapsar, dönen cancel cevap gövdesi okunduktan sonra çağrılmalıdır.
+func (o *Orchestrator) withTimeout(ctx context.Context, agent models.AgentDefinition, op agentOperation) (context.Context, context.CancelFunc) {
+	var timeout time.Duration
+	switch op {
+	case opDispatch:
+		timeout = agent.DispatchTimeout.Std()
+		if timeout <= 0 {
+			timeout = o.Config.DispatchTimeout
+		}
+	case opStatus:
+		timeout = agent.StatusTimeout.Std()
+		if timeout <= 0 {
+			timeout = o.Config.StatusTimeout
+		}
+	case opStop:
+		timeout = agent.StopTimeout.Std()
+		if timeout <= 0 {
+			timeout = o.Config.StopTimeout
+		}
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// agentForTask, görevin agent tanımını döndürür. Agent görev başladıktan sonra registry'den silinmişse
+// durum ve durdurma çağrıları yine yapılabilsin diye yalnızca adı dolu, varsayılan ayarlı bir tanım döner.
+func (o *Orchestrator) agentForTask(taskInfo TaskInfo) models.AgentDefinition {
+	if agent, ok := o.Registry.Get(taskInfo.AgentName); ok {
+		return agent
+	}
+	return models.AgentDefinition{Name: taskInfo.AgentName}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// send, tek bir HTTP denemesi yapar ve sonucu replikanın circuit breaker'ına işler.
+// 5xx cevaplar ve bağlantı hataları breaker için hata sayılır; istemcinin iptal ettiği istekler sayılmaz.
+func (o *Orchestrator) send(ctx context.Context, agent models.AgentDefinition, replica, method, targetURL string, body []byte, header http.Header) (*http.Response, error) {
+	if err := o.Breakers.Allow(agent, replica); err != nil {
+		return nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, reader)
+	if err != nil {
+		o.Breakers.Record(agent, replica, false, false)
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if err := applyAgentAuth(req, agent.Auth, body); err != nil {
+		// Eksik bir gizli değer replikanın hatası değildir, breaker'a işlenmez
+		o.Breakers.Record(agent, replica, false, false)
+		return nil, fmt.Errorf("agent '%s' kimlik bilgileri eklenemedi: %w", agent.Name, err)
+	}
+
+	client, err := o.Transports.Client(agent)
+	if err != nil {
+		o.Breakers.Record(agent, replica, false, false)
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	// İstemcinin vazgeçtiği istekler agent'ın hatası sayılmaz, zaman aşımları ise sayılır
+	counted := !errors.Is(ctx.Err(), context.Canceled)
+	o.Breakers.Record(agent, replica, err == nil && resp.StatusCode < 500, counted)
+	return resp, err
+}
+
+// withRetry, do fonksiyonunu agent'ın RetryPolicy'sine göre tekrar dener. retryable false ise
+// politika ne olursa olsun tek deneme yapılır. Son denemenin cevabı ya da hatası olduğu gibi döner.
+func (o *Orchestrator) withRetry(ctx context.Context, agent models.AgentDefinition, operation string, retryable bool, do func() (*http.Response, error)) (*http.Response, error) {
+	policy := agent.Retry
+	maxAttempts := 1
+	if retryable && policy != nil && policy.MaxAttempts > 1 {
+		maxAttempts = policy.MaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := do()
+		if attempt >= maxAttempts || !shouldRetry(ctx, policy, resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := retryBackoff(policy, attempt)
+		log.Printf("Agent '%s' çağrısı başarısız (%s), %d/%d. deneme %s sonra tekrarlanacak", agent.Name, operation, attempt, maxAttempts, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func shouldRetry(ctx context.Context, policy *models.RetryPolicy, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// Bütün replikaların breaker'ı açıksa beklemek yerine hemen hata dönülür
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+
+	codes := policy.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryableStatusCodes
+	}
+	return slices.Contains(codes, resp.StatusCode)
+}
+
+// retryBackoff, üstel artan ve jitter ile saptırılan bekleme süresini hesaplar.
+func retryBackoff(policy *models.RetryPolicy, attempt int) time.Duration {
+	initial := policy.InitialBackoff.Std()
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	multiplier := policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if maxBackoff := policy.MaxBackoff.Std(); maxBackoff > 0 && delay > float64(maxBackoff) {
+		delay = float64(maxBackoff)
+	}
+
+	if jitter := math.Min(math.Max(policy.Jitter, 0), 1); jitter > 0 {
+		delay *= 1 + jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// enqueueTask, agent'ın sınırı dolu olduğu için kuyruğa alınan görevi "queued" durumuyla deftere yazar
+// ve sırası geldiğinde arka planda gönderir. İstemciye görev kimliği ve kuyruktaki sırası döner.
+func (o *Orchestrator) enqueueTask(ticket *queueTicket, agent models.AgentDefinition, args json.RawMessage, header http.Header, retry bool, caller string) (*taskStart, error) {
+	start := &taskStart{TaskID: ticket.taskID, cancel: func() {}}
+
+	if err := o.TaskRegistry.RegisterQueuedTask(ticket.taskID, agent, caller); err != nil {
+		o.Limiter.Stop(ticket.taskID)
+		return start, fmt.Errorf("%w: %v", errTaskRegistration, err)
+	}
+
+	position, _ := o.Limiter.Position(ticket.taskID)
+	go o.dispatchQueued(ticket, agent.Name, args, header, retry, caller)
+
+	start.Accepted = &models.TaskStartResponse{
+		TaskID:        ticket.taskID,
+		Status:        models.StatusQueued,
+		QueuePosition: position,
+	}
+	return start, nil
+}
+
+// dispatchQueued, kuyruktaki görevin sırasını bekler ve görevi agent'a gönderir. İstemci cevabı çoktan
+// aldığı için senkron cevaplar ve hatalar görevin sonucu olarak deftere yazılır.
+func (o *Orchestrator) dispatchQueued(ticket *queueTicket, agentName string, args json.RawMessage, header http.Header, retry bool, caller string) {
+	release, err := o.Limiter.Wait(ticket)
+	if errors.Is(err, ErrQueuedTaskStopped) {
+		// Durdurma isteği görevin sonucunu zaten yazdı
+		return
+	}
+
+	// Agent beklerken config reload ile değişmiş ya da silinmiş olabilir
+	agent, ok := o.Registry.Get(agentName)
+	if !ok {
+		agent = models.AgentDefinition{Name: agentName}
+		if err == nil {
+			release()
+			err = ErrAgentNotFound
+		}
+	}
+	if err != nil {
+		log.Printf("Hata: Kuyruktaki görev gönderilemedi (TaskID %s): %v", ticket.taskID, err)
+		o.finishQueuedTask(agent, "", caller, callErrorStatus(ticket.taskID, err))
+		return
+	}
+
+	ctx, cancel := o.withTimeout(context.Background(), agent, opDispatch)
+	start := &taskStart{TaskID: ticket.taskID, cancel: cancel, release: release}
+	defer start.Close()
+
+	log.Printf("Kuyruktaki görev gönderiliyor: TaskID %s (kuyrukta %s bekledi)", ticket.taskID, time.Since(ticket.queuedAt).Round(time.Millisecond))
+	err = o.sendTask(ctx, start, agent, args, header, retry, caller)
+
+	switch {
+	case err != nil:
+		log.Printf("Hata: Agent '%s' çağrılamadı: %v", agent.Name, err)
+		o.finishQueuedTask(agent, start.Replica, caller, callErrorStatus(ticket.taskID, err))
+
+	case start.Accepted != nil:
+		o.Events.Publish(ticket.taskID, models.TaskStatusResponse{TaskID: ticket.taskID, Status: start.Accepted.Status})
+
+	default:
+		status, _, err := syncEnvelopeStatus(ticket.taskID, start.Response)
+		if err != nil {
+			log.Printf("Hata: Agent '%s' cevabı okunamadı: %v", agent.Name, err)
+			status = callErrorStatus(ticket.taskID, err)
+		}
+		o.finishQueuedTask(agent, start.Replica, caller, status)
+	}
+}
+
+// queuedStatus, görev kuyrukta bekliyorsa durumunu sırasıyla birlikte döndürür.
+func (o *Orchestrator) queuedStatus(taskInfo TaskInfo) (models.TaskStatusResponse, bool) {
+	if taskInfo.LastStatus == nil || taskInfo.LastStatus.Status != models.StatusQueued {
+		return models.TaskStatusResponse{}, false
+	}
+
+	status := *taskInfo.LastStatus
+	// Görev o sırada gönderiliyor olabilir, bu durumda sıra bilgisi olmadan bekliyor görünür
+	status.QueuePosition, _ = o.Limiter.Position(taskInfo.TaskID)
+	return status, true
+}
+
+// stopQueuedTask, henüz gönderilmemiş görevi kuyruktan çıkarır ve başarısız olarak kaydeder.
+// Görev kuyrukta değilse false döner.
+func (o *Orchestrator) stopQueuedTask(taskInfo TaskInfo) (models.TaskStatusResponse, bool) {
+	if !o.Limiter.Stop(taskInfo.TaskID) {
+		return models.TaskStatusResponse{}, false
+	}
+
+	status := models.TaskStatusResponse{
+		TaskID: taskInfo.TaskID,
+		Status: models.StatusFailed,
+		Error:  "Task stopped before dispatch",
+	}
+	o.finishQueuedTask(o.agentForTask(taskInfo), "", taskInfo.Caller, status)
+	log.Printf("Kuyruktaki görev durduruldu: TaskID %s", taskInfo.TaskID)
+	return status, true
+}
+
+// ---------------------- HELPERS ----------------------
+
+// dispatchedTaskInfo, kuyruktan çıkarılamayan bir görev için defterdeki güncel kaydı okur. Görev kuyruktan
+// o sırada gönderiliyorsa agent bilgileri henüz yazılmamıştır ve ok false döner.
+func (o *Orchestrator) dispatchedTaskInfo(taskInfo TaskInfo) (TaskInfo, bool) {
+	if taskInfo.LastStatus == nil || taskInfo.LastStatus.Status != models.StatusQueued {
+		return taskInfo, true
+	}
+	current, ok := o.TaskRegistry.GetTaskInfo(taskInfo.TaskID)
+	if !ok || current.LastStatus == nil || current.LastStatus.Status == models.StatusQueued {
+		return taskInfo, false
+	}
+	return current, true
+}
+
+// finishQueuedTask, kuyruktan agent'ta bir görev oluşturmadan biten görevin sonucunu deftere yazar ve abonelere iletir.
+func (o *Orchestrator) finishQueuedTask(agent models.AgentDefinition, replica, caller string, status models.TaskStatusResponse) {
+	o.recordSyncTask(agent, replica, caller, status)
+	o.Events.Publish(status.TaskID, status)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// scriptedAgent, sırayla verilen durum kodlarıyla cevap veren bir test agent'ıdır; kodlar bitince son kodu tekrarlar.
+type scriptedAgent struct {
+	mu       sync.Mutex
+	statuses []int
+	calls    int
+}
+
+func (a *scriptedAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	status := a.statuses[min(a.calls, len(a.statuses)-1)]
+	a.calls++
+	a.mu.Unlock()
+
+	w.WriteHeader(status)
+	w.Write([]byte(`{}`))
+}
+
+func (a *scriptedAgent) Calls() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls
+}
+
+func TestDispatchTaskRetry(t *testing.T) {
+	policy := func(maxAttempts int, codes ...int) *models.RetryPolicy {
+		return &models.RetryPolicy{MaxAttempts: maxAttempts, InitialBackoff: models.Duration(time.Millisecond), RetryableStatusCodes: codes}
+	}
+
+	tests := []struct {
+		name       string
+		statuses   []int
+		policy     *models.RetryPolicy
+		idempotent bool
+		wantCalls  int
+		wantStatus int
+	}{
+		{"idempotent gönderim tekrar denenir", []int{503, 502, 200}, policy(3), true, 3, 200},
+		{"Idempotency-Key olmadan tekrar denenmez", []int{503, 200}, policy(3), false, 1, 503},
+		{"politika yoksa tek deneme", []int{503, 200}, nil, true, 1, 503},
+		{"deneme sayısı sınırı", []int{503}, policy(2), true, 2, 503},
+		{"tekrar denenmeyen kod", []int{500, 200}, policy(3), true, 1, 500},
+		{"istemci hatası tekrar denenmez", []int{400, 200}, policy(3), true, 1, 400},
+		{"politikadaki kodlar", []int{429, 200}, policy(3, 429), true, 2, 200},
+		{"politikadaki kodlar varsayılanların yerini alır", []int{503, 200}, policy(3, 429), true, 1, 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripted := &scriptedAgent{statuses: tt.statuses}
+			server := httptest.NewServer(scripted)
+			defer server.Close()
+
+			// Breaker kapalıdır, böylece yalnızca tekrar deneme politikası denenir
+			agent := models.AgentDefinition{Name: "echo", Endpoint: server.URL, Retry: tt.policy, CircuitBreaker: &models.CircuitBreakerPolicy{}}
+			o := newTestOrchestrator(t, agent)
+
+			resp, replica, release, err := o.dispatchTask(context.Background(), agent, []byte(`{}`), http.Header{}, tt.idempotent)
+			release()
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if scripted.Calls() != tt.wantCalls {
+				t.Errorf("agent %d kez çağrıldı, beklenen %d", scripted.Calls(), tt.wantCalls)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("cevap %d, beklenen %d", resp.StatusCode, tt.wantStatus)
+			}
+			if replica != server.URL {
+				t.Errorf("replika %s, beklenen %s", replica, server.URL)
+			}
+		})
+	}
+}
+
+// Tekrar deneme düşen replika yerine diğer replikaya gider
+func TestDispatchTaskRetryPicksReplica(t *testing.T) {
+	tests := []struct {
+		name          string
+		loadBalancing string
+	}{
+		{"round_robin", LBRoundRobin},
+		{"least_in_flight", LBLeastInFlight},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failing := &scriptedAgent{statuses: []int{http.StatusServiceUnavailable}}
+			healthy := &scriptedAgent{statuses: []int{http.StatusOK}}
+			failingServer, healthyServer := httptest.NewServer(failing), httptest.NewServer(healthy)
+			defer failingServer.Close()
+			defer healthyServer.Close()
+
+			agent := models.AgentDefinition{
+				Name:          "echo",
+				Endpoints:     []models.AgentEndpoint{{URL: failingServer.URL}, {URL: healthyServer.URL}},
+				LoadBalancing: tt.loadBalancing,
+				Retry:         &models.RetryPolicy{MaxAttempts: 2, InitialBackoff: models.Duration(time.Millisecond)},
+				// Tek hata breaker'ı açar, böylece ikinci deneme düşen replikayı seçemez
+				CircuitBreaker: &models.CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: models.Duration(time.Minute)},
+			}
+			o := newTestOrchestrator(t, agent)
+
+			// Hangi replikanın önce seçildiğinden bağımsız olarak iki gönderim de sağlıklı replikada biter
+			for i := range 2 {
+				resp, replica, release, err := o.dispatchTask(context.Background(), agent, []byte(`{}`), http.Header{}, true)
+				release()
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK || replica != healthyServer.URL {
+					t.Errorf("gönderim %d: %d %s, beklenen 200 %s", i, resp.StatusCode, replica, healthyServer.URL)
+				}
+			}
+			if failing.Calls() > 1 {
+				t.Errorf("düşen replika %d kez çağrıldı, beklenen en fazla 1", failing.Calls())
+			}
+		})
+	}
+}
+
+// Bütün replikaların breaker'ı açıksa beklemeden ErrCircuitOpen döner
+func TestDispatchTaskAllCircuitsOpen(t *testing.T) {
+	scripted := &scriptedAgent{statuses: []int{http.StatusOK}}
+	server := httptest.NewServer(scripted)
+	defer server.Close()
+
+	agent := models.AgentDefinition{
+		Name:           "echo",
+		Endpoint:       server.URL,
+		Retry:          &models.RetryPolicy{MaxAttempts: 3, InitialBackoff: models.Duration(time.Second)},
+		CircuitBreaker: &models.CircuitBreakerPolicy{FailureThreshold: 1, Cooldown: models.Duration(time.Minute)},
+	}
+	o := newTestOrchestrator(t, agent)
+	o.Breakers.Record(agent, server.URL, false, true)
+
+	started := time.Now()
+	_, replica, release, err := o.dispatchTask(context.Background(), agent, []byte(`{}`), http.Header{}, true)
+	release()
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("ErrCircuitOpen bekleniyordu, gelen: %v", err)
+	}
+	if replica != "" || scripted.Calls() != 0 {
+		t.Errorf("replika %q, agent %d kez çağrıldı", replica, scripted.Calls())
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("tekrar denemeden önce %s beklendi", elapsed)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	response := func(status int) *http.Response { return &http.Response{StatusCode: status} }
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		policy *models.RetryPolicy
+		resp   *http.Response
+		err    error
+		want   bool
+	}{
+		{"bağlantı hatası", context.Background(), &models.RetryPolicy{}, nil, errors.New("connection refused"), true},
+		{"breaker açık", context.Background(), &models.RetryPolicy{}, nil, ErrCircuitOpen, false},
+		{"context iptal edildi", canceled, &models.RetryPolicy{}, nil, errors.New("connection refused"), false},
+		{"varsayılan 502", context.Background(), &models.RetryPolicy{}, response(502), nil, true},
+		{"varsayılan 504", context.Background(), &models.RetryPolicy{}, response(504), nil, true},
+		{"varsayılan 500 değil", context.Background(), &models.RetryPolicy{}, response(500), nil, false},
+		{"başarılı cevap", context.Background(), &models.RetryPolicy{}, response(200), nil, false},
+		{"politikadaki kod", context.Background(), &models.RetryPolicy{RetryableStatusCodes: []int{500}}, response(500), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetry(tt.ctx, tt.policy, tt.resp, tt.err); got != tt.want {
+				t.Errorf("%t, beklenen %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   models.RetryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{"varsayılan ilk bekleme", models.RetryPolicy{}, 1, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"varsayılan çarpan 2", models.RetryPolicy{}, 3, 400 * time.Millisecond, 400 * time.Millisecond},
+		{"çarpan", models.RetryPolicy{InitialBackoff: models.Duration(time.Second), Multiplier: 3}, 2, 3 * time.Second, 3 * time.Second},
+		{"1'den küçük çarpan 2 sayılır", models.RetryPolicy{InitialBackoff: models.Duration(time.Second), Multiplier: 0.5}, 2, 2 * time.Second, 2 * time.Second},
+		{"üst sınır", models.RetryPolicy{InitialBackoff: models.Duration(time.Second), MaxBackoff: models.Duration(5 * time.Second)}, 10, 5 * time.Second, 5 * time.Second},
+		{"jitter aralığı", models.RetryPolicy{InitialBackoff: models.Duration(time.Second), Jitter: 0.5}, 1, 500 * time.Millisecond, 1500 * time.Millisecond},
+		{"1'den büyük jitter 1 sayılır", models.RetryPolicy{InitialBackoff: models.Duration(time.Second), Jitter: 5}, 1, 0, 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				if got := retryBackoff(&tt.policy, tt.attempt); got < tt.min || got > tt.max {
+					t.Fatalf("%s, beklenen %s ile %s arası", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
@@ -3,8 +3,11 @@ module github.com/uslanozan/Go-Smith
 go 1.24.0
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/robfig/cron/v3 v3.0.1
 	golang.org/x/oauth2 v0.32.0
 	google.golang.org/api v0.255.0
 	gorm.io/driver/mysql v1.6.0
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+type HealthStatus string
+
+const (
+	// Health endpoint'i tanımlanmamış ya da henüz yeterli kontrol yapılmamış agent'lar
+	HealthUnknown   HealthStatus = "unknown"
+	HealthHealthy   HealthStatus = "healthy"
+	HealthUnhealthy HealthStatus = "unhealthy"
+)
+
+// Sağlıksız agent'ların GetToolsSpec'te nasıl gösterileceği
+const (
+	UnhealthyToolsHide = "hide"
+	UnhealthyToolsMark = "mark"
+)
+
+// AgentHealth, bir agent'ın son health check sonuçlarını tutar.
+type AgentHealth struct {
+	Status               HealthStatus `json:"status"`
+	ConsecutiveSuccesses int          `json:"consecutive_successes"`
+	ConsecutiveFailures  int          `json:"consecutive_failures"`
+	LastCheck            *time.Time   `json:"last_check,omitempty"`
+	LastChange           *time.Time   `json:"last_change,omitempty"`
+	LastError            string       `json:"last_error,omitempty"`
+}
+
+// Available, agent'a görev gönderilebilir mi? Durumu bilinmeyen agent'lar erişilebilir kabul edilir.
+func (h AgentHealth) Available() bool {
+	return h.Status != HealthUnhealthy
+}
+
+// HealthChecker, health endpoint'i tanımlı agent'ları arka planda periyodik olarak yoklar.
+type HealthChecker struct {
+	Registry           *AgentRegistry
+	Interval           time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	Timeout            time.Duration
+	transports         *AgentTransports
+}
+
+func NewHealthChecker(registry *AgentRegistry, transports *AgentTransports, cfg *OrchestratorConfig) *HealthChecker {
+	return &HealthChecker{
+		Registry:           registry,
+		Interval:           cfg.HealthCheckInterval,
+		HealthyThreshold:   cfg.HealthyThreshold,
+		UnhealthyThreshold: cfg.UnhealthyThreshold,
+		Timeout:            cfg.HealthCheckTimeout,
+		transports:         transports,
+	}
+}
+
+// Start, prober'ı arka planda çalıştırır. İlk kontrol beklemeden hemen yapılır.
+func (h *HealthChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.Interval)
+		defer ticker.Stop()
+
+		for {
+			h.checkAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	log.Printf("Health checker başlatıldı (aralık: %s)", h.Interval)
+}
+
+func (h *HealthChecker) checkAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, agent := range h.Registry.List() {
+		if agent.HealthEndpointPath == "" {
+			continue
+		}
+
+		// Her replika ayrı yoklanır, böylece load balancer yalnızca düşen replikayı atlar
+		for _, replica := range agent.Replicas() {
+			wg.Add(1)
+			go func(agent models.AgentDefinition, replicaURL string) {
+				defer wg.Done()
+				err := h.probe(ctx, agent, replicaURL)
+				h.Registry.RecordHealth(agent.Name, replicaURL, err, h.HealthyThreshold, h.UnhealthyThreshold)
+			}(agent, replica.URL)
+		}
+	}
+	wg.Wait()
+}
+
+func (h *HealthChecker) probe(ctx context.Context, agent models.AgentDefinition, replicaURL string) error {
+	base, err := url.Parse(replicaURL)
+	if err != nil {
+		return err
+	}
+	healthURL := base.ResolveReference(&url.URL{Path: agent.HealthEndpointPath})
+
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", healthURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := applyAgentAuth(req, agent.Auth, nil); err != nil {
+		return err
+	}
+	client, err := h.transports.Client(agent)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health endpoint %s döndü", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// İstemcinin tekrar denemelerinde aynı gönderimi tanımak için kullandığı başlık
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Saklanan cevap tekrar döndürüldüğünde eklenen başlık
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// Idempotency-Key değerinin en fazla uzunluğu
+const maxIdempotencyKeyLength = 255
+
+// Saklanan cevapta tekrar döndürülen başlıklar
+var idempotencyReplayHeaders = []string{"Content-Type", "Retry-After"}
+
+// idempotencyOutcomeKey, Wrap'in handler'a verdiği idempotencyOutcome'un context anahtarıdır.
+type idempotencyOutcomeKey struct{}
+
+// idempotencyOutcome, handler'ın Wrap'e görevin agent'a hiç gönderilmediğini bildirmesini sağlar.
+type idempotencyOutcome struct {
+	notDispatched bool
+}
+
+type idempotencyEntry struct {
+	scope   string
+	element *list.Element
+	// İsteğin gövdesinin özeti; aynı anahtar farklı bir gövdeyle gelirse 422 döner
+	bodyHash string
+	// İlk istek bitince kapanır, o ana kadar gelen tekrarlar aynı cevabı bekler
+	done   chan struct{}
+	status int
+	header http.Header
+	body   []byte
+	taskID string
+}
+
+// IdempotencyStore, Idempotency-Key taşıyan run_task isteklerinin cevabını ttl süresince saklar. Aynı anahtarla
+// gelen tekrarlar görevi yeniden göndermeden ilk cevabı alır; ilk istek sürerken gelenler onu bekler.
+// Anahtarlar X-Caller-ID başına ayrıdır, böylece farklı istemcilerin anahtarları çakışmaz.
+// Kayıtlar yalnızca bellekte tutulur; Orchestrator yeniden başladığında daha önceki anahtarlar hatırlanmaz.
+// maxEntries aşılınca en uzun süredir kullanılmayan biten kayıtlar silinir.
+type IdempotencyStore struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*idempotencyEntry
+	// Kayıtların kullanım sırası, en son kullanılan başta
+	lru *list.List
+}
+
+func NewIdempotencyStore(ttl time.Duration, maxEntries int) *IdempotencyStore {
+	return &IdempotencyStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*idempotencyEntry),
+		lru:        list.New(),
+	}
+}
+
+// Wrap, handler'ı Idempotency-Key desteğiyle sarar. Anahtarsız istekler ya da ttl 0 ise handler doğrudan çalışır.
+func (s *IdempotencyStore) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method != "POST" || s.ttl <= 0 {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := r.Header.Get(CallerIDHeader) + "\x00" + key
+		bodyHash := hashRequestBody(body)
+
+		s.mu.Lock()
+		if entry, ok := s.entries[scope]; ok {
+			s.lru.MoveToFront(entry.element)
+			s.mu.Unlock()
+			if entry.bodyHash != bodyHash {
+				log.Printf("Hata: Idempotency-Key farklı bir istek gövdesiyle tekrar kullanıldı: %s", key)
+				http.Error(w, "Idempotency-Key was already used with a different request body", http.StatusUnprocessableEntity)
+				return
+			}
+
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+				return
+			}
+			log.Printf("Tekrarlanan istek, saklanan cevap döndürülüyor: Idempotency-Key %s (TaskID: %s)", key, entry.taskID)
+			entry.replay(w)
+			return
+		}
+
+		entry := &idempotencyEntry{scope: scope, bodyHash: bodyHash, done: make(chan struct{})}
+		s.entries[scope] = entry
+		entry.element = s.lru.PushFront(entry)
+		s.mu.Unlock()
+
+		// Handler panic yapsa da (net/http onu yakalar) kayıt tamamlanır, böylece bekleyen tekrarlar takılı kalmaz
+		capture := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		outcome := &idempotencyOutcome{}
+		completed := false
+		defer func() {
+			s.finish(entry, capture, outcome, completed)
+		}()
+		next(capture, r.WithContext(context.WithValue(r.Context(), idempotencyOutcomeKey{}, outcome)))
+		completed = true
+	}
+}
+
+// markNotDispatched, görevin agent'a hiç gönderilmediğini Wrap'e bildirir; böylece anahtar saklanmaz ve istemci
+// aynı anahtarla tekrar deneyebilir. Yalnızca breaker açıkken ve agent'ın kuyruğu dolu ya da bekleme süresi
+// dolmuşken geçerlidir; diğer hatalarda (ör. istek gönderildikten sonraki bağlantı hatası) görev agent'a
+// ulaşmış olabilir, tekrar göndermek görevi iki kez çalıştırabilir.
+func markNotDispatched(ctx context.Context, err error) {
+	if !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrAgentQueueFull) && !errors.Is(err, ErrAgentQueueTimeout) {
+		return
+	}
+	if outcome, ok := ctx.Value(idempotencyOutcomeKey{}).(*idempotencyOutcome); ok {
+		outcome.notDispatched = true
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// finish, ilk isteğin cevabını kaydeder ve bekleyen tekrarları serbest bırakır. Handler yarıda kaldıysa
+// bekleyenler 500 alır; görev gönderilmediyse ya da handler yarıda kaldıysa kayıt hemen silinir.
+func (s *IdempotencyStore) finish(entry *idempotencyEntry, capture *responseCapture, outcome *idempotencyOutcome, completed bool) {
+	if completed {
+		entry.status = capture.status
+		entry.body = capture.body.Bytes()
+		entry.header = make(http.Header)
+		for _, name := range idempotencyReplayHeaders {
+			if value := capture.Header().Get(name); value != "" {
+				entry.header.Set(name, value)
+			}
+		}
+		var started struct {
+			TaskID string `json:"task_id"`
+		}
+		if json.Unmarshal(entry.body, &started) == nil {
+			entry.taskID = started.TaskID
+		}
+	} else {
+		log.Printf("Hata: Idempotency-Key taşıyan istek yarıda kaldı, anahtar saklanmıyor")
+		entry.status = http.StatusInternalServerError
+		entry.header = http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+		entry.body = []byte("Internal server error\n")
+	}
+	close(entry.done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !completed || outcome.notDispatched {
+		s.removeLocked(entry)
+		return
+	}
+	time.AfterFunc(s.ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.removeLocked(entry)
+	})
+	s.evictLocked()
+}
+
+// removeLocked, kaydı siler; kayıt bu arada silinip aynı anahtarla yenisi eklendiyse yenisine dokunmaz.
+// s.mu tutulurken çağrılmalıdır.
+func (s *IdempotencyStore) removeLocked(entry *idempotencyEntry) {
+	if s.entries[entry.scope] != entry {
+		return
+	}
+	delete(s.entries, entry.scope)
+	s.lru.Remove(entry.element)
+}
+
+// evictLocked, kayıt sayısı maxEntries'in altına inene kadar en uzun süredir kullanılmayan biten kayıtları siler.
+// Sürmekte olan istekler silinmez, bekleyen tekrarları olabilir. s.mu tutulurken çağrılmalıdır.
+func (s *IdempotencyStore) evictLocked() {
+	if s.maxEntries <= 0 {
+		return
+	}
+	for element := s.lru.Back(); element != nil && len(s.entries) > s.maxEntries; {
+		previous := element.Prev()
+		entry := element.Value.(*idempotencyEntry)
+		select {
+		case <-entry.done:
+			s.removeLocked(entry)
+		default:
+		}
+		element = previous
+	}
+}
+
+// hashRequestBody, gövdeyi boşluklardan bağımsız olarak özetler; geçerli JSON değilse olduğu gibi özetlenir.
+func hashRequestBody(body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *idempotencyEntry) replay(w http.ResponseWriter) {
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// responseCapture, cevabı istemciye yazarken bir kopyasını da tutar.
+type responseCapture struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(p []byte) (int, error) {
+	c.wroteHeader = true
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHashRequestBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"aynı gövde", `{"agent_name":"echo"}`, `{"agent_name":"echo"}`, true},
+		{"boşluk farkı", `{"agent_name":"echo","arguments":{"n":1}}`, "{\n  \"agent_name\": \"echo\",\n  \"arguments\": { \"n\": 1 }\n}", true},
+		{"farklı değer", `{"agent_name":"echo"}`, `{"agent_name":"other"}`, false},
+		{"alan sırası farkı", `{"a":1,"b":2}`, `{"b":2,"a":1}`, false},
+		{"metin içindeki boşluk korunur", `{"text":"a b"}`, `{"text":"ab"}`, false},
+		{"JSON olmayan gövde olduğu gibi özetlenir", `not json`, `not json`, true},
+		{"JSON olmayan gövdede boşluk fark eder", `not json`, `not  json`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := hashRequestBody([]byte(tt.a)), hashRequestBody([]byte(tt.b))
+			if (a == b) != tt.equal {
+				t.Errorf("özetler eşit: %t, beklenen %t", a == b, tt.equal)
+			}
+		})
+	}
+}
+
+// idempotentRequest, testte sırayla gönderilen tek bir run_task isteğidir.
+type idempotentRequest struct {
+	key, caller, body string
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	const body = `{"agent_name":"echo"}`
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		maxEntries int
+		// Handler'ın cevap kodu ve gönderimin hatası
+		handlerStatus int
+		dispatchErr   error
+		requests      []idempotentRequest
+		wantCalls     int
+		// Son isteğin cevabı
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{
+			name: "aynı anahtar saklanan cevabı alır", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 1, wantStatus: http.StatusAccepted, wantReplayed: true,
+		},
+		{
+			name: "boşluk farkı aynı istek sayılır", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", `{ "agent_name": "echo" }`}},
+			wantCalls: 1, wantStatus: http.StatusAccepted, wantReplayed: true,
+		},
+		{
+			name: "farklı gövdeyle aynı anahtar 422", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", `{"agent_name":"other"}`}},
+			wantCalls: 1, wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name: "anahtarlar istemci başına ayrıdır", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "other", body}},
+			wantCalls: 2, wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "anahtarsız istekler her seferinde çalışır", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"", "gw", body}, {"", "gw", body}},
+			wantCalls: 2, wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "ttl 0 ise anahtar hatırlanmaz", ttl: 0, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 2, wantStatus: http.StatusAccepted,
+		},
+		{
+			name: "kuyruk dolu 429 saklanmaz", ttl: time.Minute, handlerStatus: http.StatusTooManyRequests, dispatchErr: ErrAgentQueueFull,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 2, wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "kuyruk bekleme süresi dolan 429 saklanmaz", ttl: time.Minute, handlerStatus: http.StatusTooManyRequests, dispatchErr: ErrAgentQueueTimeout,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 2, wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name: "breaker açıkken 503 saklanmaz", ttl: time.Minute, handlerStatus: http.StatusServiceUnavailable, dispatchErr: fmt.Errorf("dispatch: %w", ErrCircuitOpen),
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 2, wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "gönderildikten sonraki bağlantı hatası saklanır", ttl: time.Minute, handlerStatus: http.StatusServiceUnavailable, dispatchErr: errors.New("connection reset by peer"),
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 1, wantStatus: http.StatusServiceUnavailable, wantReplayed: true,
+		},
+		{
+			name: "agent'ın kendi 503'ü saklanır", ttl: time.Minute, handlerStatus: http.StatusServiceUnavailable,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 1, wantStatus: http.StatusServiceUnavailable, wantReplayed: true,
+		},
+		{
+			name: "hata cevapları da tekrar döner", ttl: time.Minute, handlerStatus: http.StatusBadRequest,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 1, wantStatus: http.StatusBadRequest, wantReplayed: true,
+		},
+		{
+			name: "çok uzun anahtar 400", ttl: time.Minute, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{strings.Repeat("k", maxIdempotencyKeyLength+1), "gw", body}},
+			wantCalls: 0, wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "sınır aşılınca en eski kayıt silinir", ttl: time.Minute, maxEntries: 1, handlerStatus: http.StatusAccepted,
+			requests:  []idempotentRequest{{"k1", "gw", body}, {"k2", "gw", body}, {"k1", "gw", body}},
+			wantCalls: 3, wantStatus: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			store := NewIdempotencyStore(tt.ttl, tt.maxEntries)
+			handler := store.Wrap(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if tt.dispatchErr != nil {
+					markNotDispatched(r.Context(), tt.dispatchErr)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.handlerStatus)
+				fmt.Fprintf(w, `{"task_id":"task-%d"}`, calls)
+			})
+
+			var first, last *httptest.ResponseRecorder
+			for _, req := range tt.requests {
+				r := httptest.NewRequest("POST", "/api/v1/run_task", strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(IdempotencyKeyHeader, req.key)
+				}
+				r.Header.Set(CallerIDHeader, req.caller)
+				last = httptest.NewRecorder()
+				handler(last, r)
+				if first == nil {
+					first = last
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler %d kez çalıştı, beklenen %d", calls, tt.wantCalls)
+			}
+			if last.Code != tt.wantStatus {
+				t.Errorf("son cevap %d, beklenen %d", last.Code, tt.wantStatus)
+			}
+			replayed := last.Header().Get(IdempotentReplayedHeader) == "true"
+			if replayed != tt.wantReplayed {
+				t.Errorf("Idempotent-Replayed %t, beklenen %t", replayed, tt.wantReplayed)
+			}
+			if replayed && (last.Body.String() != first.Body.String() || last.Header().Get("Content-Type") != "application/json") {
+				t.Errorf("tekrar edilen cevap farklı: %q %q", last.Body.String(), last.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	tests := []struct {
+		name string
+		// İkinci istek, ilk istek bitmeden vazgeçer
+		cancelSecond bool
+		wantStatus   int
+	}{
+		{"ilk isteğin cevabını bekler", false, http.StatusAccepted},
+		{"beklerken vazgeçen istek 409 alır", true, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started := make(chan struct{})
+			finish := make(chan struct{})
+			calls := 0
+			store := NewIdempotencyStore(time.Minute, 0)
+			handler := store.Wrap(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				close(started)
+				<-finish
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte(`{"task_id":"task-1"}`))
+			})
+
+			newRequest := func(ctx context.Context) *http.Request {
+				r := httptest.NewRequest("POST", "/api/v1/run_task", strings.NewReader(`{}`)).WithContext(ctx)
+				r.Header.Set(IdempotencyKeyHeader, "k1")
+				return r
+			}
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				handler(httptest.NewRecorder(), newRequest(context.Background()))
+			}()
+			<-started
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelSecond {
+				cancel()
+			}
+			second := httptest.NewRecorder()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				handler(second, newRequest(ctx))
+			}()
+
+			if tt.cancelSecond {
+				// Vazgeçen istek, ilk istek bitmeden cevap alır
+				<-done
+				close(finish)
+			} else {
+				// İkinci istek ilk istek bitene kadar cevap almamalı
+				select {
+				case <-done:
+					t.Fatal("ikinci istek ilk istek bitmeden cevaplandı")
+				case <-time.After(50 * time.Millisecond):
+				}
+				close(finish)
+				<-done
+			}
+			wg.Wait()
+
+			if calls != 1 {
+				t.Errorf("handler %d kez çalıştı, beklenen 1", calls)
+			}
+			if second.Code != tt.wantStatus {
+				t.Errorf("ikinci cevap %d, beklenen %d", second.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// Handler panic yaparsa bekleyen tekrarlar serbest kalır ve anahtar saklanmaz
+func TestIdempotencyHandlerPanic(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	calls := 0
+	store := NewIdempotencyStore(time.Minute, 0)
+	handler := store.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			close(started)
+			<-finish
+			panic("handler bozuldu")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("POST", "/api/v1/run_task", strings.NewReader(`{}`))
+		r.Header.Set(IdempotencyKeyHeader, "k1")
+		return r
+	}
+
+	panicked := make(chan any, 1)
+	go func() {
+		// net/http'nin yaptığı gibi panic yakalanır
+		defer func() { panicked <- recover() }()
+		handler(httptest.NewRecorder(), newRequest())
+	}()
+	<-started
+
+	waiting := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(waiting, newRequest())
+	}()
+	// Tekrarın ilk isteği beklemeye başlaması için kısa bir süre tanınır
+	time.Sleep(50 * time.Millisecond)
+	close(finish)
+
+	if <-panicked == nil {
+		t.Fatal("panic yukarı aktarılmadı")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("bekleyen tekrar serbest kalmadı")
+	}
+	if waiting.Code != http.StatusInternalServerError {
+		t.Errorf("bekleyen tekrar %d aldı, beklenen %d", waiting.Code, http.StatusInternalServerError)
+	}
+
+	retry := httptest.NewRecorder()
+	handler(retry, newRequest())
+	if calls != 2 || retry.Code != http.StatusAccepted {
+		t.Errorf("yeniden deneme %d aldı, handler %d kez çalıştı; beklenen %d ve 2", retry.Code, calls, http.StatusAccepted)
+	}
+}
@@ -1,34 +1,174 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/uslanozan/Go-Smith/models"
 )
 
+// Kapanışta süren isteklerin (SSE akışları ve long-poll'lar dahil) bitmesi için beklenen en uzun süre
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Uyarı: .env dosyası bulunamadı, environment değişkenleri kullanılacak.")
+	}
+
+	cfg, err := NewOrchestratorConfig()
+	if err != nil {
+		log.Fatalf("Config yüklenemedi: %v", err)
+	}
+
 	// 1. Agent Kayıt Defterini oluştur
 	registry := NewAgentRegistry()
+	registry.SetUnhealthyToolsMode(cfg.UnhealthyToolsMode)
+
+	// Agent auth'u yalnızca bu dizindeki dosyaları okuyabilir, agent'lar yüklenmeden önce ayarlanmalı
+	if err := SetAgentSecretsDir(cfg.AgentSecretsDir); err != nil {
+		log.Fatalf("Config yüklenemedi: %v", err)
+	}
 
-	// 2. Agent'ları koddan değil, config dosyasından yükle
-	//todo: Gelecekte buradaki config'i backendden alacak
-	if err := LoadAgentsFromConfig(registry, "config/agents.json"); err != nil {
+	// 2. Agent'ları koddan değil, config dosyasından yükle. Çalışma anında /api/v1/agents ile de eklenebilir
+	if err := LoadAgentsFromConfig(registry, cfg.AgentConfigFile); err != nil {
 		log.Fatalf("Agent konfigürasyonu yüklenemedi: %v", err)
 	}
 
-	taskRegistry := NewTaskRegistry()
+	// Config dosyası değiştiğinde ya da SIGHUP geldiğinde agent'lar yeniden başlatmadan yüklenir
+	reloader := NewConfigReloader(registry, cfg.AgentConfigFile)
+	if err := reloader.Watch(context.Background()); err != nil {
+		log.Printf("Uyarı: Config dosyası izlenemiyor, yalnızca SIGHUP ile reload yapılabilir: %v", err)
+	}
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			reloader.Reload("sighup")
+		}
+	}()
+
+	// Health endpoint'i tanımlı agent'lar arka planda yoklanır, düşenler tool listesinden çıkar
+	// tls ayarı olan agent'lar kendi sertifikalarıyla, diğerleri ortak client ile çağrılır
+	transports := NewAgentTransports()
+	NewHealthChecker(registry, transports, cfg).Start(context.Background())
+
+	// 3. Görev defterini kalıcı depoyla oluştur, böylece yeniden başlatmada görevler kaybolmaz
+	taskStore, err := NewTaskStore(cfg)
+	if err != nil {
+		log.Fatalf("Task store açılamadı: %v", err)
+	}
+
+	taskRegistry := NewTaskRegistry(taskStore, cfg.TaskRetention)
+	taskRegistry.StartSweeper(context.Background(), cfg.TaskGCInterval)
+
+	// 4. Orchestrator'ı oluştur
+	orchestrator := NewOrchestrator(registry, taskRegistry, transports, cfg)
 
-	// 3. Orchestrator'ı oluştur
-	orchestrator := NewOrchestrator(registry, taskRegistry)
+	// 5. HTTP sunucu ayarları
+	// Her endpoint bir işlem yetkisi ister; kimlik doğrulama kapalıysa bütün istekler geçer
+	auth, err := NewAuthenticator(cfg)
+	if err != nil {
+		log.Fatalf("Kimlik doğrulama ayarları okunamadı: %v", err)
+	}
 
-	// 4. HTTP sunucu ayarları
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/v1/tools", orchestrator.HandleGetTools)
-	mux.HandleFunc("/api/v1/run_task", orchestrator.HandleTask)
-	mux.HandleFunc("/api/v1/task_status/", orchestrator.HandleTaskStatus)
-	mux.HandleFunc("/api/v1/task_stop/", orchestrator.HandleTaskStop)
+	mux.HandleFunc("/api/v1/tools", auth.Require(models.OperationTools, orchestrator.HandleGetTools))
+	// Gateway'in tekrar denemeleri aynı Idempotency-Key ile gelirse görev tekrar gönderilmez
+	idempotency := NewIdempotencyStore(cfg.IdempotencyTTL, cfg.IdempotencyMaxEntries)
+	mux.HandleFunc("/api/v1/run_task", auth.Require(models.OperationRunTask, idempotency.Wrap(orchestrator.HandleTask)))
+	mux.HandleFunc("/api/v1/run_tasks", auth.Require(models.OperationRunTask, orchestrator.HandleBatchTask))
+	mux.HandleFunc(TaskStatusPath, auth.Require(models.OperationTaskStatus, orchestrator.HandleTaskStatus))
+	mux.HandleFunc(TaskStopPath, auth.Require(models.OperationTaskStop, orchestrator.HandleTaskStop))
+	mux.HandleFunc("/api/v1/tasks", auth.Require(models.OperationTaskStatus, orchestrator.HandleListTasks))
+	// Callback'ler agent'tan gelir ve kendi imzalı token'larıyla doğrulanır
+	mux.HandleFunc("/api/v1/tasks/{id}/callback", orchestrator.HandleTaskCallback)
+	mux.HandleFunc("/api/v1/tasks/{id}/events", auth.Require(models.OperationTaskStatus, orchestrator.HandleTaskEvents))
+	mux.HandleFunc("/api/v1/admin/config", auth.Require(models.OperationAdmin, reloader.HandleConfigReload))
+
+	// Çok adımlı workflow'lar bellekte tutulur, biten görevlerle aynı süre saklanır
+	workflows := NewWorkflowManager(orchestrator, cfg.TaskRetention.TerminalTTL)
+	mux.HandleFunc("/api/v1/workflows", auth.RequireByMethod(models.OperationTaskStatus, models.OperationRunTask, workflows.HandleWorkflows))
+	mux.HandleFunc("/api/v1/workflows/{id}", auth.Require(models.OperationTaskStatus, workflows.HandleWorkflow))
+	mux.HandleFunc("/api/v1/workflows/{id}/cancel", auth.Require(models.OperationTaskStop, workflows.HandleWorkflowCancel))
+
+	// run_at ve schedule verilen görevler dosyada saklanır, zamanı geldiğinde run_task yolundan gönderilir
+	scheduler, err := NewScheduler(orchestrator, auth, cfg.ScheduleStorePath, cfg.ScheduleCatchUp, cfg.TaskRetention.TerminalTTL)
+	if err != nil {
+		log.Fatalf("Zamanlamalar yüklenemedi: %v", err)
+	}
+	orchestrator.Scheduler = scheduler
+	scheduler.Start(context.Background())
+	mux.HandleFunc("/api/v1/schedules", auth.Require(models.OperationTaskStatus, scheduler.HandleSchedules))
+	mux.HandleFunc("/api/v1/schedules/{id}", auth.RequireByMethod(models.OperationTaskStatus, models.OperationTaskStop, scheduler.HandleSchedule))
+	mux.HandleFunc("/api/v1/schedules/{id}/pause", auth.Require(models.OperationTaskStop, scheduler.HandleSchedulePause(true)))
+	mux.HandleFunc("/api/v1/schedules/{id}/resume", auth.Require(models.OperationTaskStop, scheduler.HandleSchedulePause(false)))
+
+	// Görev temizliği ve diğer metrikler
+	mux.HandleFunc("/debug/vars", auth.Require(models.OperationAdmin, expvar.Handler().ServeHTTP))
+
+	agentAPI := NewAgentAPI(registry, cfg.AgentConfigFile, cfg.AgentAPIPersist)
+	mux.HandleFunc("/api/v1/agents", auth.Require(models.OperationAdmin, agentAPI.HandleAgents))
+	mux.HandleFunc("/api/v1/agents/{name}", auth.Require(models.OperationAdmin, agentAPI.HandleAgent))
+
+	// 6. Sunucuyu başlat. TLS listener açıksa API iki adreste de sunulur; ORCHESTRATOR_LISTEN_ADDR kapalıysa yalnızca TLS ile
+	var servers []*http.Server
+	serverErrors := make(chan error, 2)
+	if cfg.TLSListenAddress != "" {
+		tlsConfig, err := NewServerTLSConfig(cfg)
+		if err != nil {
+			log.Fatalf("TLS ayarları okunamadı: %v", err)
+		}
+		server := &http.Server{Addr: cfg.TLSListenAddress, Handler: mux, TLSConfig: tlsConfig}
+		servers = append(servers, server)
+		go func() {
+			log.Printf("TLS listener başlatıldı: %s (istemci sertifikası zorunlu: %t)", cfg.TLSListenAddress, cfg.TLSClientCAFile != "")
+			serverErrors <- fmt.Errorf("TLS sunucusu: %w", server.ListenAndServeTLS("", ""))
+		}()
+	}
+
+	if cfg.ListenAddress == "" {
+		log.Println("Düz HTTP listener kapalı, API yalnızca TLS ile sunuluyor.")
+	} else {
+		server := &http.Server{Addr: cfg.ListenAddress, Handler: mux}
+		servers = append(servers, server)
+		go func() {
+			serverErrors <- fmt.Errorf("HTTP sunucusu: %w", server.ListenAndServe())
+		}()
+	}
+
+	// SIGINT ya da SIGTERM geldiğinde yeni istekler kesilir, süren istekler beklenir ve task store kapatılır
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
+
+	exitCode := 0
+	select {
+	case sig := <-shutdown:
+		log.Printf("%s alındı, Orchestrator kapatılıyor", sig)
+	case err := <-serverErrors:
+		log.Printf("Sunucu başlatılamadı: %v", err)
+		exitCode = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("Uyarı: %s sunucusu zamanında kapanmadı: %v", server.Addr, err)
+		}
+	}
+	cancel()
 
-	// 5. Sunucuyu başlat
-	if err := http.ListenAndServe(":8080", mux); err != nil {
-		log.Fatalf("Sunucu başlatılamadı: %v", err)
+	if err := taskStore.Close(); err != nil {
+		log.Printf("Hata: Task store kapatılamadı: %v", err)
+		exitCode = 1
 	}
+	os.Exit(exitCode)
 }
@@ -2,6 +2,9 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 )
 
 type AgentDefinition struct {
@@ -11,6 +14,156 @@ type AgentDefinition struct {
 	Endpoint           string          `json:"endpoint"`
 	StatusEndpointPath string          `json:"status_endpoint_path,omitempty"`
 	StopEndpointPath   string          `json:"stop_endpoint_path,omitempty"`
+	// Tanımlanırsa Orchestrator bu yola periyodik GET atarak agent'ın ayakta olup olmadığını kontrol eder
+	HealthEndpointPath string `json:"health_endpoint_path,omitempty"`
+	// Aynı agent'ın birden fazla replikası varsa Endpoint yerine kullanılır
+	Endpoints []AgentEndpoint `json:"endpoints,omitempty"`
+	// round_robin (varsayılan), least_in_flight ya da weighted
+	LoadBalancing string `json:"load_balancing,omitempty"`
+	// Tanımlanmazsa her istek tek denemeyle yapılır
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	// Tanımlanmazsa Orchestrator'ın genel circuit breaker ayarları kullanılır
+	CircuitBreaker *CircuitBreakerPolicy `json:"circuit_breaker,omitempty"`
+	// İşlem bazlı zaman aşımları, tanımlanmazsa Orchestrator'ın genel varsayılanları kullanılır
+	DispatchTimeout Duration `json:"dispatch_timeout,omitempty"`
+	StatusTimeout   Duration `json:"status_timeout,omitempty"`
+	StopTimeout     Duration `json:"stop_timeout,omitempty"`
+	// true ise senkron cevaplar Orchestrator görev kimliği taşıyan bir TaskStatusResponse ile sarılır
+	SyncEnvelope bool `json:"sync_envelope,omitempty"`
+	// Agent'ta aynı anda çalışabilecek görev sayısı; 0 ise sınır yoktur. Asenkron görevler bitene kadar yer tutar
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// Sınır doluyken sırada bekleyebilecek gönderim sayısı; kuyruk da doluysa istek 429 ile reddedilir
+	MaxQueue int `json:"max_queue,omitempty"`
+	// Tanımlanırsa dispatch, durum, durdurma ve health isteklerine agent'ın beklediği kimlik bilgileri eklenir
+	Auth *AgentAuth `json:"auth,omitempty"`
+	// Tanımlanırsa https endpoint'lere bu CA, istemci sertifikası ve sürüm ayarlarıyla bağlanılır
+	TLS *AgentTLS `json:"tls,omitempty"`
+}
+
+// AgentTLS, agent'a yapılan TLS bağlantılarının ayarlarıdır. Dosyalar değiştiğinde yeniden başlatmadan yüklenir.
+type AgentTLS struct {
+	// Agent'ın sunucu sertifikasını doğrulayan CA'lar; verilmezse sistemin CA'ları kullanılır
+	CAFile string `json:"ca_file,omitempty"`
+	// mTLS için Orchestrator'ın agent'a sunduğu istemci sertifikası ve anahtarı
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+	// Sertifika doğrulamasında endpoint'in host'u yerine kullanılacak ad
+	ServerName string `json:"server_name,omitempty"`
+	// "1.2" (varsayılan) ya da "1.3"
+	MinVersion string `json:"min_version,omitempty"`
+}
+
+// AgentAuth, Orchestrator'ın agent'a kendini tanıtma yollarıdır; birlikte kullanılabilirler, yalnızca
+// bearer ve basic ikisi de Authorization başlığını kullandığı için aynı anda verilemez.
+type AgentAuth struct {
+	Bearer  *SecretRef           `json:"bearer,omitempty"`
+	Basic   *BasicAuth           `json:"basic,omitempty"`
+	Headers map[string]SecretRef `json:"headers,omitempty"`
+	HMAC    *HMACAuth            `json:"hmac,omitempty"`
+}
+
+type BasicAuth struct {
+	Username string    `json:"username"`
+	Password SecretRef `json:"password"`
+}
+
+// HMACAuth, isteği "<timestamp>.<method>.<path>.<body>" üzerinden HMAC-SHA256 ile imzalar.
+// Agent zaman damgasını kontrol ederek eski isteklerin tekrar oynatılmasını reddedebilir.
+type HMACAuth struct {
+	Secret SecretRef `json:"secret"`
+	// Varsayılanları X-GoSmith-Signature ve X-GoSmith-Timestamp
+	SignatureHeader string `json:"signature_header,omitempty"`
+	TimestampHeader string `json:"timestamp_header,omitempty"`
+}
+
+// SecretRef, gizli bir değerin environment değişkeninden ya da dosyadan okunacağını belirtir.
+// Gizli değerin kendisi agents.json'a yazılamaz.
+type SecretRef struct {
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+func (s *SecretRef) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if _, ok := raw.(map[string]any); !ok {
+		return errors.New(`gizli değerler doğrudan yazılamaz, {"env": "..."} ya da {"file": "..."} ile referans verilmeli`)
+	}
+
+	type plain SecretRef
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// RetryPolicy, geçici hatalarda agent çağrısının nasıl tekrar deneneceğini belirler.
+// Tekrar deneme yalnızca durum sorguları gibi idempotent işlemlerde ya da Idempotency-Key
+// taşıyan görev gönderimlerinde uygulanır.
+type RetryPolicy struct {
+	MaxAttempts    int      `json:"max_attempts,omitempty"`
+	InitialBackoff Duration `json:"initial_backoff,omitempty"`
+	MaxBackoff     Duration `json:"max_backoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty"`
+	// 0 ile 1 arasında, bekleme süresinin ne kadarının rastgele saptırılacağı
+	Jitter               float64 `json:"jitter,omitempty"`
+	RetryableStatusCodes []int   `json:"retryable_status_codes,omitempty"`
+}
+
+// CircuitBreakerPolicy, ardışık hatalardan sonra agent'a istek atmayı ne kadar süre keseceğimizi belirler.
+type CircuitBreakerPolicy struct {
+	FailureThreshold int      `json:"failure_threshold"`
+	Cooldown         Duration `json:"cooldown"`
+}
+
+// Duration, JSON'da "500ms", "2s" gibi string olarak yazılan bir time.Duration'dır.
+// Sayı olarak verilirse milisaniye kabul edilir.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	switch value := raw.(type) {
+	case float64:
+		*d = Duration(time.Duration(value * float64(time.Millisecond)))
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("geçersiz süre: %s", string(data))
+	}
+	return nil
+}
+
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// AgentEndpoint, bir agent replikasının görev endpoint'idir. Weight yalnızca weighted stratejisinde kullanılır.
+type AgentEndpoint struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+// Replicas, agent'ın görev gönderilebilecek tüm endpoint'lerini döndürür.
+// Endpoints tanımlı değilse tek replika olarak Endpoint kullanılır.
+func (d AgentDefinition) Replicas() []AgentEndpoint {
+	if len(d.Endpoints) > 0 {
+		return d.Endpoints
+	}
+	if d.Endpoint == "" {
+		return nil
+	}
+	return []AgentEndpoint{{URL: d.Endpoint, Weight: 1}}
 }
 
 type ToolSpec struct {
@@ -0,0 +1,39 @@
+package models
+
+// AuthOperation, bir kimliğin Orchestrator API'sinde yapabileceği işlemlerden biridir.
+type AuthOperation string
+
+const (
+	// GET /api/v1/tools
+	OperationTools AuthOperation = "tools"
+	// run_task, run_tasks, workflow başlatma ve zamanlama oluşturma
+	OperationRunTask AuthOperation = "run_task"
+	// task_status, görev listesi, görev olayları, workflow ve zamanlamaları okuma
+	OperationTaskStatus AuthOperation = "task_status"
+	// task_stop, workflow iptali, zamanlamaları duraklatma ve silme
+	OperationTaskStop AuthOperation = "task_stop"
+	// Agent yönetimi, config reload ve metrikler
+	OperationAdmin AuthOperation = "admin"
+	// Bütün işlemler; agents listesinde de bütün agent'lar anlamına gelir
+	AuthWildcard = "*"
+)
+
+var AuthOperations = []AuthOperation{OperationTools, OperationRunTask, OperationTaskStatus, OperationTaskStop, OperationAdmin}
+
+// AuthConfig, AUTH_CONFIG_FILE ile okunan statik API anahtarlarıdır.
+type AuthConfig struct {
+	APIKeys []APIKeyCredential `json:"api_keys"`
+}
+
+// APIKeyCredential, tek bir API anahtarıdır. Anahtarın kendisi değil SHA-256 özeti saklanır,
+// böylece dosya sızsa da anahtar ele geçmez.
+type APIKeyCredential struct {
+	// Görevlere caller olarak yazılan kimlik
+	Name string `json:"name"`
+	// Anahtarın hex kodlu SHA-256 özeti (ör. `printf %s "$KEY" | sha256sum`)
+	KeySHA256 string `json:"key_sha256"`
+	// Kullanılabilecek agent'lar; "*" bütün agent'lar
+	Agents []string `json:"agents"`
+	// Yapılabilecek işlemler; "*" bütün işlemler
+	Operations []AuthOperation `json:"operations"`
+}
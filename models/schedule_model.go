@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type ScheduleState string
+
+const (
+	ScheduleActive ScheduleState = "active"
+	SchedulePaused ScheduleState = "paused"
+	// Tek seferlik (run_at) zamanlama çalıştı ya da catch-up politikası gereği atlandı
+	ScheduleCompleted ScheduleState = "completed"
+)
+
+// Zamanlanmış bir görevdir. run_task isteğinde run_at ya da schedule verildiğinde oluşturulur.
+type ScheduleResponse struct {
+	ScheduleID string `json:"schedule_id"`
+	// Her çalıştırmada run_task'a gönderilen istek; run_at ve schedule alanları boştur
+	Task   OrchestratorTaskRequest `json:"task"`
+	RunAt  *time.Time              `json:"run_at,omitempty"`
+	Cron   string                  `json:"schedule,omitempty"`
+	Caller string                  `json:"caller,omitempty"`
+	State  ScheduleState           `json:"state"`
+
+	CreatedAt time.Time  `json:"created_at"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	RunCount  int        `json:"run_count"`
+	// Son çalıştırmanın run_task cevabı: HTTP durum kodu, oluşan görevin kimliği ve hata varsa gövdesi
+	LastHTTPStatus int    `json:"last_http_status,omitempty"`
+	LastTaskID     string `json:"last_task_id,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/invopop/jsonschema"
 )
@@ -10,6 +11,77 @@ import (
 type OrchestratorTaskRequest struct {
 	AgentName string          `json:"agent_name"`
 	Arguments json.RawMessage `json:"arguments"`
+	// Verilirse agent'ın sync_envelope ayarını bu istek için ezer
+	Envelope *bool `json:"envelope,omitempty"`
+	// Agent'ın kuyruğunda sıranın belirlenmesinde kullanılır; verilmezse "normal"
+	Priority TaskPriority `json:"priority,omitempty"`
+	// Verilirse görev hemen değil bu zamanda bir kez çalıştırılır
+	RunAt *time.Time `json:"run_at,omitempty"`
+	// Verilirse görev cron ifadesine göre tekrar tekrar çalıştırılır (ör. "0 9 * * *", "CRON_TZ=Europe/Istanbul 0 9 * * *")
+	Schedule string `json:"schedule,omitempty"`
+}
+
+type TaskPriority string
+
+const (
+	PriorityHigh   TaskPriority = "high"
+	PriorityNormal TaskPriority = "normal"
+	PriorityLow    TaskPriority = "low"
+)
+
+// TaskPriorities, öncelikleri yüksekten düşüğe sıralar.
+var TaskPriorities = []TaskPriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+// Valid, önceliğin tanımlı değerlerden biri olup olmadığını söyler.
+func (p TaskPriority) Valid() bool {
+	switch p {
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return true
+	}
+	return false
+}
+
+func (TaskPriority) JSONSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: "string",
+		Enum: []any{
+			PriorityHigh,
+			PriorityNormal,
+			PriorityLow,
+		},
+	}
+}
+
+// Argümanlar agent şemasına uymadığında Orchestrator'ın döndürdüğü 400 cevabıdır.
+type ValidationErrorResponse struct {
+	Error      string            `json:"error"`
+	AgentName  string            `json:"agent_name"`
+	Violations []SchemaViolation `json:"violations"`
+}
+
+// Tek bir şema ihlali, Pointer argümanlar içindeki hatalı alanı JSON pointer (RFC 6901) olarak gösterir.
+type SchemaViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// POST /api/v1/run_tasks cevabıdır. Results, istekteki görevlerle aynı sıradadır.
+type BatchTaskResponse struct {
+	Results []BatchTaskResult `json:"results"`
+}
+
+// Toplu gönderimde tek bir görevin sonucu. Asenkron kabul edilen görevler 202 ve başlangıç durumuyla,
+// senkron görevler zarflanmış sonuçlarıyla, hatalı görevler kendi HTTP durum kodu ve hatasıyla döner.
+type BatchTaskResult struct {
+	HTTPStatus   int               `json:"http_status"`
+	TaskID       string            `json:"task_id,omitempty"`
+	Status       TaskStatus        `json:"status,omitempty"`
+	Result       json.RawMessage   `json:"result,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorDetails *TaskErrorDetails `json:"error_details,omitempty"`
+	Violations   []SchemaViolation `json:"violations,omitempty"`
+	// Görev agent'ın kuyruğunda bekliyorsa sırası
+	QueuePosition int `json:"queue_position,omitempty"`
 }
 
 // --------- ASENKRON GÖREVLER İÇİN ---------
@@ -17,6 +89,8 @@ type OrchestratorTaskRequest struct {
 type TaskStatus string
 
 const (
+	// Agent'ın eşzamanlılık sınırı dolu olduğu için görev Orchestrator'ın kuyruğunda bekliyor
+	StatusQueued    TaskStatus = "queued"
 	StatusPending   TaskStatus = "pending"
 	StatusRunning   TaskStatus = "running"
 	StatusCompleted TaskStatus = "completed"
@@ -25,8 +99,10 @@ const (
 
 // Task başlatılır
 type TaskStartResponse struct {
-	TaskID string     `json:"task_id"` //! Merkezi bir yerden dağıtılmadığı için (DB gibi) int ve AI yapmak sıkıntı
+	TaskID string     `json:"task_id"` // Agent kendi yerel kimliğini döner, Orchestrator bunu istemciye global bir UUID ile eşleyerek iletir
 	Status TaskStatus `json:"status"`
+	// Görev kuyruğa alındıysa sırası; 1 sıradaki ilk görevdir
+	QueuePosition int `json:"queue_position,omitempty"`
 }
 
 // Bir görevin /task_status/:id endpoint'inden sorgulandığında döndürülecek "Durum Raporu"dur.
@@ -35,12 +111,68 @@ type TaskStatusResponse struct {
 	Status TaskStatus      `json:"status"`
 	Result json.RawMessage `json:"result,omitempty"`
 	Error  string          `json:"error,omitempty"`
+	// Uzun süren görevlerde agent'ın isteğe bağlı ilerleme bilgisi (ör. {"percent": 40})
+	Progress json.RawMessage `json:"progress,omitempty"`
+	// Biten görevlerde boştur; istemcinin bir sonraki sorgudan önce beklemesi önerilen süre (milisaniye)
+	NextPollAfterMs int64 `json:"next_poll_after_ms,omitempty"`
+	// Senkron çağrı zarfla sarıldığında başarısızlığın yapılandırılmış ayrıntısı
+	ErrorDetails *TaskErrorDetails `json:"error_details,omitempty"`
+	// Görev Orchestrator'ın kuyruğunda bekliyorsa sırası
+	QueuePosition int `json:"queue_position,omitempty"`
+}
+
+// GET /api/v1/tasks listesindeki tek bir görevdir. Agent tarafındaki kimlik ve adresler istemciye gösterilmez.
+type TaskSummary struct {
+	TaskID    string     `json:"task_id"`
+	AgentName string     `json:"agent_name"`
+	Status    TaskStatus `json:"status,omitempty"`
+	Caller    string     `json:"caller,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Orchestrator'ın görevle ilgili son bilgi aldığı zaman
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+// Zarfla sarılmış senkron çağrılarda hata kodları
+const (
+	// Agent 2xx dışında bir cevap döndü
+	ErrorCodeAgentError = "agent_error"
+	// Agent zaman aşımına kadar cevap vermedi
+	ErrorCodeAgentTimeout = "agent_timeout"
+	// Agent'a ulaşılamadı ya da circuit breaker açık
+	ErrorCodeAgentUnavailable = "agent_unavailable"
+	// Agent'ın eşzamanlılık sınırı ve kuyruğu dolu
+	ErrorCodeAgentBusy = "agent_busy"
+	// Agent'ın senkron cevabı Orchestrator'ın saklayabileceğinden büyük
+	ErrorCodeResponseTooLarge = "response_too_large"
+)
+
+type TaskErrorDetails struct {
+	Code string `json:"code"`
+	// Agent'ın döndürdüğü ya da Orchestrator'ın istemciye döndürdüğü HTTP durum kodu
+	HTTPStatus int `json:"http_status"`
+	// Agent'ın hata cevabının gövdesi; JSON değilse metin olarak taşınır
+	AgentBody json.RawMessage `json:"agent_body,omitempty"`
+}
+
+// Valid, durumun tanımlı değerlerden biri olup olmadığını söyler.
+func (s TaskStatus) Valid() bool {
+	switch s {
+	case StatusQueued, StatusPending, StatusRunning, StatusCompleted, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// Terminal, görevin bittiğini ve durumunun artık değişmeyeceğini söyler.
+func (s TaskStatus) Terminal() bool {
+	return s == StatusCompleted || s == StatusFailed
 }
 
 func (TaskStatus) JSONSchema() *jsonschema.Schema {
 	return &jsonschema.Schema{
 		Type: "string",
 		Enum: []any{
+			StatusQueued,
 			StatusPending,
 			StatusRunning,
 			StatusCompleted,
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// POST /api/v1/workflows isteğidir. Adımlar bağımlılıklarına göre sıralanır, bağımsız adımlar paralel çalışır.
+type WorkflowRequest struct {
+	Name  string         `json:"name,omitempty"`
+	Steps []WorkflowStep `json:"steps"`
+}
+
+// Tek bir workflow adımı. Arguments içindeki "{{steps.<id>.result.<yol>}}" şablonları, adım başlamadan önce
+// önceki adımların sonuçlarıyla doldurulur. Şablon bir metnin tamamıysa sonucun JSON tipi korunur.
+type WorkflowStep struct {
+	ID        string          `json:"id"`
+	AgentName string          `json:"agent_name"`
+	DependsOn []string        `json:"depends_on,omitempty"`
+	Arguments json.RawMessage `json:"arguments"`
+	Priority  TaskPriority    `json:"priority,omitempty"`
+}
+
+type WorkflowState string
+
+const (
+	WorkflowPending   WorkflowState = "pending"
+	WorkflowRunning   WorkflowState = "running"
+	WorkflowCompleted WorkflowState = "completed"
+	WorkflowFailed    WorkflowState = "failed"
+	WorkflowCancelled WorkflowState = "cancelled"
+	// Bağımlı olduğu adımlardan biri başarısız olduğu için çalıştırılmayan adım
+	WorkflowSkipped WorkflowState = "skipped"
+)
+
+// Terminal, workflow'un ya da adımın bittiğini söyler.
+func (s WorkflowState) Terminal() bool {
+	return s == WorkflowCompleted || s == WorkflowFailed || s == WorkflowCancelled || s == WorkflowSkipped
+}
+
+// Workflow'un ve adımlarının anlık durumu.
+type WorkflowStatusResponse struct {
+	WorkflowID string               `json:"workflow_id"`
+	Name       string               `json:"name,omitempty"`
+	Status     WorkflowState        `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	CreatedAt  time.Time            `json:"created_at"`
+	FinishedAt *time.Time           `json:"finished_at,omitempty"`
+	Steps      []WorkflowStepStatus `json:"steps"`
+}
+
+type WorkflowStepStatus struct {
+	ID        string        `json:"id"`
+	AgentName string        `json:"agent_name"`
+	Status    WorkflowState `json:"status"`
+	// Adımın Orchestrator görev kimliği, /api/v1/task_status ile de sorgulanabilir
+	TaskID     string          `json:"task_id,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
@@ -1,33 +1,100 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/uslanozan/Go-Smith/models"
 )
 
+// Orchestrator'ın dışarıya açtığı görev endpoint'lerinin yol önekleri
+const (
+	TaskStatusPath = "/api/v1/task_status/"
+	TaskStopPath   = "/api/v1/task_stop/"
+)
+
+// Görevi başlatan istemcinin kimliği, görev listesinde filtrelemek için göreve kaydedilir
+const CallerIDHeader = "X-Caller-ID"
+
+var (
+	// Agent'ın 202 cevabı anlaşılamadı
+	errAgentStartResponse = errors.New("agent start response could not be parsed")
+	// Agent'ın kabul ettiği görev deftere yazılamadı
+	errTaskRegistration = errors.New("task registration failed")
+)
+
+// taskStart, startTask'ın sonucudur.
+type taskStart struct {
+	TaskID  string
+	Replica string
+	// Agent görevi asenkron kabul ettiyse doludur, TaskID alanı Orchestrator kimliğidir
+	Accepted *models.TaskStartResponse
+	// Agent'ın senkron cevabı, Accepted boşsa ve hata yoksa doludur
+	Response *http.Response
+	cancel   context.CancelFunc
+	// Agent'ın eşzamanlılık sınırındaki yeri; asenkron kabul edilen görevlerde görev bitene kadar tutulur
+	release func()
+}
+
+// Close, senkron cevabın gövdesini kapatır ve gönderimin context'ini serbest bırakır.
+func (s *taskStart) Close() {
+	if s.Response != nil {
+		s.Response.Body.Close()
+	}
+	s.cancel()
+	if s.release != nil {
+		s.release()
+	}
+}
+
 // Orchestrator registry ve diğer servislere istek atmak için bir HTTP client'ı tutar.
 type Orchestrator struct {
 	Registry     *AgentRegistry
 	TaskRegistry *TaskRegistry
-	HttpClient   *http.Client
+	Balancer     *LoadBalancer
+	Breakers     *CircuitBreakers
+	Callbacks    *CallbackSigner
+	// Görev deftere yazılmadan önce gelen callback'ler
+	HeldCallbacks *CallbackBuffer
+	Events        *TaskEventHub
+	StatusCache   *StatusCache
+	Limiter       *ConcurrencyLimiter
+	// main'de Orchestrator oluşturulduktan sonra atanır
+	Scheduler *Scheduler
+	Config    *OrchestratorConfig
+	// Agent'lara yapılan çağrıların agent'ın tls ayarına göre kurulmuş HTTP client'ları
+	Transports *AgentTransports
 }
 
 // Constructor
-func NewOrchestrator(registry *AgentRegistry, taskRegistry *TaskRegistry) *Orchestrator {
-	return &Orchestrator{
+func NewOrchestrator(registry *AgentRegistry, taskRegistry *TaskRegistry, transports *AgentTransports, cfg *OrchestratorConfig) *Orchestrator {
+	o := &Orchestrator{
 		Registry:     registry,
 		TaskRegistry: taskRegistry,
-		HttpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+		Balancer:     NewLoadBalancer(),
+		Breakers: NewCircuitBreakers(models.CircuitBreakerPolicy{
+			FailureThreshold: cfg.CircuitBreakerFailureThreshold,
+			Cooldown:         models.Duration(cfg.CircuitBreakerCooldown),
+		}),
+		Callbacks:     NewCallbackSigner(cfg.CallbackSecret),
+		HeldCallbacks: NewCallbackBuffer(),
+		StatusCache:   NewStatusCache(cfg.TaskStatusCacheTTL),
+		Limiter:       NewConcurrencyLimiter(cfg.AgentQueueTimeout, cfg.QueuePriorityWeights),
+		Config:        cfg,
+		Transports:    transports,
 	}
+	o.Events = NewTaskEventHub(o.pollTaskStatus, o.markTaskLost, cfg.TaskEventsPollInterval)
+	return o
 }
 
 // LLM'den gelen task'i agent'lara yönlendirir
@@ -45,51 +112,162 @@ func (o *Orchestrator) HandleTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	agent, ok := o.Registry.Get(task.AgentName)
-	if !ok {
-		log.Printf("Hata: Bilinmeyen agent istendi: %s", task.AgentName)
-		http.Error(w, "Agent not found", http.StatusNotFound)
+	agent, rejection := o.validateTask(ctx, task)
+	if rejection != nil {
+		if len(rejection.Violations) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(rejection.HTTPStatus)
+			json.NewEncoder(w).Encode(models.ValidationErrorResponse{
+				Error:      rejection.Message,
+				AgentName:  agent.Name,
+				Violations: rejection.Violations,
+			})
+			return
+		}
+		http.Error(w, rejection.Message, rejection.HTTPStatus)
 		return
 	}
 
-	log.Printf("Görev alındı: Agent '%s', Endpoint: '%s'", agent.Name, agent.Endpoint)
-	agentReq, err := http.NewRequestWithContext(ctx, "POST", agent.Endpoint, bytes.NewBuffer(task.Arguments))
-	agentReq.Header.Set("Content-Type", "application/json")
+	// Senkron cevapların zarflanması agent'ta açılabilir, istek bunu her iki yönde ezebilir
+	envelope := agent.SyncEnvelope
+	if task.Envelope != nil {
+		envelope = *task.Envelope
+	}
+	caller := r.Header.Get(CallerIDHeader)
 
-	agentResp, err := o.HttpClient.Do(agentReq)
-	if err != nil {
-		log.Printf("Hata: Agent '%s' çağrılamadı: %v", agent.Name, err)
-		http.Error(w, "Failed to call agent service", http.StatusServiceUnavailable)
+	// run_at ya da schedule verilen görev hemen gönderilmez, zamanı geldiğinde Scheduler bu yoldan tekrar geçirir
+	if task.RunAt != nil || task.Schedule != "" {
+		o.scheduleTask(w, task, caller, principalFrom(ctx))
 		return
 	}
-	defer agentResp.Body.Close()
 
-	if agentResp.StatusCode == http.StatusAccepted {
-		var startResp models.TaskStartResponse
+	start, err := o.startTask(ctx, agent, task.Arguments, r.Header.Get(IdempotencyKeyHeader), caller, task.Priority)
+	defer start.Close()
 
-		if err := json.NewDecoder(agentResp.Body).Decode(&startResp); err != nil {
-			log.Printf("Hata: Agent'ın asenkron cevabı anlaşılamadı: %v", err)
-			http.Error(w, "Agent response parsing error", http.StatusInternalServerError)
-			return
-		}
+	switch {
+	case errors.Is(err, errAgentStartResponse):
+		log.Printf("Hata: Agent'ın asenkron cevabı anlaşılamadı: %v", err)
+		http.Error(w, "Agent response parsing error", http.StatusInternalServerError)
 
-		if err := o.TaskRegistry.RegisterTask(startResp.TaskID, agent); err != nil {
-			log.Printf("Hata: TaskRegistry'ye kayıt yapılamadı: %v", err)
-			http.Error(w, "Task registration error", http.StatusInternalServerError)
+	case errors.Is(err, errTaskRegistration):
+		log.Printf("Hata: TaskRegistry'ye kayıt yapılamadı: %v", err)
+		http.Error(w, "Task registration error", http.StatusInternalServerError)
+
+	case err != nil:
+		log.Printf("Hata: Agent '%s' çağrılamadı: %v", agent.Name, err)
+		markNotDispatched(ctx, err)
+		if envelope {
+			o.writeCallErrorEnvelope(w, start.TaskID, agent, start.Replica, caller, err)
 			return
 		}
+		o.writeAgentCallError(w, agent, start.Replica, err, "Failed to call agent service")
 
-		log.Printf("Agent '%s' görevi kabul etti, TaskID: %s", agent.Name, startResp.TaskID)
+	case start.Accepted != nil:
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusAccepted)
-		json.NewEncoder(w).Encode(startResp)
+		json.NewEncoder(w).Encode(start.Accepted)
+
+	case envelope:
+		log.Printf("Agent '%s' senkron yanıt verdi: %s (zarflandı, TaskID: %s)", agent.Name, start.Response.Status, start.TaskID)
+		o.writeSyncEnvelope(w, start.TaskID, agent, start.Replica, caller, start.Response)
 
-	} else { // Hata durumu
-		log.Printf("Agent '%s' senkron yanıt verdi: %s", agent.Name, agentResp.Status)
+	default: // Senkron cevap ya da hata olduğu gibi aktarılır
+		log.Printf("Agent '%s' senkron yanıt verdi: %s", agent.Name, start.Response.Status)
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(agentResp.StatusCode)
-		io.Copy(w, agentResp.Body)
+		w.WriteHeader(start.Response.StatusCode)
+		io.Copy(w, start.Response.Body)
+	}
+}
+
+// startTask, argümanları doğrulanmış görevi agent'a gönderir. Agent görevi asenkron kabul ederse (202)
+// görev deftere yazılır ve Accepted dolar, aksi halde agent'ın senkron cevabı Response'ta döner.
+// Agent'ın eşzamanlılık sınırı doluysa görev kuyruğa alınır, arka planda gönderilir ve Accepted "queued" durumunu taşır.
+// Hata olsa bile dönen taskStart görev kimliği ve replika bilgisini taşır ve Close çağrılmalıdır.
+func (o *Orchestrator) startTask(ctx context.Context, agent models.AgentDefinition, args json.RawMessage, idempotencyKey, caller string, priority models.TaskPriority) (*taskStart, error) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	// Idempotency-Key taşıyan gönderimler agent'a iletilir ve geçici hatalarda tekrar denenebilir
+	if idempotencyKey != "" {
+		header.Set(IdempotencyKeyHeader, idempotencyKey)
+	}
+
+	// Kimlik gönderimden önce üretilir, böylece agent'a görevin callback adresi verilebilir
+	taskID := NewTaskID()
+	o.setCallbackHeaders(header, taskID)
+
+	if priority == "" {
+		priority = models.PriorityNormal
+	}
+	release, ticket, err := o.Limiter.Reserve(agent, priority, taskID)
+	if err != nil {
+		return &taskStart{TaskID: taskID, cancel: func() {}}, err
+	}
+	if ticket != nil {
+		return o.enqueueTask(ticket, agent, args, header, idempotencyKey != "", caller)
+	}
+
+	ctx, cancel := o.withTimeout(ctx, agent, opDispatch)
+	start := &taskStart{TaskID: taskID, cancel: cancel, release: release}
+	return start, o.sendTask(ctx, start, agent, args, header, idempotencyKey != "", caller)
+}
+
+// sendTask, görevi agent'a gönderir ve sonucu start'a yazar. Asenkron kabul edilen görev deftere kaydedilir;
+// eşzamanlılık sınırındaki yeri ve replikanın in-flight sayısı görev bitene kadar tutulur.
+func (o *Orchestrator) sendTask(ctx context.Context, start *taskStart, agent models.AgentDefinition, args json.RawMessage, header http.Header, retry bool, caller string) error {
+	// Görev, sağlıklı replikalar arasından agent'ın stratejisine göre seçilene gönderilir
+	log.Printf("Görev alındı: Agent '%s'", agent.Name)
+	// Agent 202 cevabından önce callback gönderebilir, görev deftere yazılana kadar gelenler bekletilir
+	o.HeldCallbacks.Expect(start.TaskID)
+	defer o.HeldCallbacks.Done(start.TaskID)
+	agentResp, replicaURL, releaseReplica, err := o.dispatchTask(ctx, agent, args, header, retry)
+	start.Replica = replicaURL
+	// Replikanın in-flight sayısı, görev asenkron kabul edilip bitişi beklenmeye başlanmadıysa hemen bırakılır
+	defer func() {
+		if releaseReplica != nil {
+			releaseReplica()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	if agentResp.StatusCode != http.StatusAccepted {
+		start.Response = agentResp
+		return nil
+	}
+	defer agentResp.Body.Close()
+
+	var startResp models.TaskStartResponse
+	if err := json.NewDecoder(agentResp.Body).Decode(&startResp); err != nil {
+		return fmt.Errorf("%w: %v", errAgentStartResponse, err)
+	}
+
+	// İstemci yalnızca Orchestrator'ın ürettiği kimliği görür, agent'ın kimliği defterde saklanır
+	if err := o.TaskRegistry.RegisterTask(start.TaskID, startResp, agent, replicaURL, caller); err != nil {
+		return fmt.Errorf("%w: %v", errTaskRegistration, err)
 	}
+
+	log.Printf("Agent '%s' (%s) görevi kabul etti, TaskID: %s (Agent TaskID: %s)", agent.Name, replicaURL, start.TaskID, startResp.TaskID)
+	// Agent 202 cevabından önce callback gönderdiyse bekletilen durum şimdi uygulanır
+	o.HeldCallbacks.Done(start.TaskID)
+	o.applyHeldCallback(start.TaskID)
+	startResp.TaskID = start.TaskID
+	start.Accepted = &startResp
+
+	// Asenkron görev agent'ta çalışmaya devam ettiği için eşzamanlılık yeri ve replikanın in-flight sayısı
+	// görev bitene kadar bırakılmaz. Bunları kullanmayan agent'ların görevlerinin bitişi izlenmez.
+	if !tracksRunningTasks(agent) {
+		return nil
+	}
+	slot, replica := start.release, releaseReplica
+	start.release, releaseReplica = nil, nil
+	go o.releaseWhenFinished(start.TaskID, func() {
+		if slot != nil {
+			slot()
+		}
+		replica()
+	})
+	return nil
 }
 
 func (o *Orchestrator) HandleTaskStatus(w http.ResponseWriter, r *http.Request) {
@@ -101,41 +279,61 @@ func (o *Orchestrator) HandleTaskStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	taskID := strings.TrimPrefix(r.URL.Path, "/task_status/")
+	taskID := strings.TrimPrefix(r.URL.Path, TaskStatusPath)
 	if taskID == "" {
 		http.Error(w, "Task ID eksik", http.StatusBadRequest)
 		return
 	}
 	log.Printf("Durum sorgusu alındı: TaskID: %s", taskID)
 
+	// ?wait=30s verilirse istek, durum değişene ya da süre dolana kadar bekletilir
+	wait, err := o.parseWait(r)
+	if err != nil {
+		http.Error(w, "Invalid wait parameter", http.StatusBadRequest)
+		return
+	}
+
 	taskInfo, ok := o.TaskRegistry.GetTaskInfo(taskID)
 	if !ok {
 		log.Printf("Hata: Bilinmeyen TaskID: %s", taskID)
 		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
+	if !principalFrom(ctx).AllowsAgent(taskInfo.AgentName) {
+		http.Error(w, "Agent not allowed for this credential", http.StatusForbidden)
+		return
+	}
 
-	fullStatusURL := taskInfo.AgentStatusBaseURL + taskID
+	// Görevin bittiği callback'ten ya da önceki bir sorgudan biliniyorsa agent'a tekrar sorulmaz
+	if status, ok := o.finishedStatus(taskInfo); ok {
+		o.writeTaskStatus(w, status)
+		return
+	}
 
-	agentReq, err := http.NewRequestWithContext(ctx, "GET", fullStatusURL, nil)
-	if err != nil {
-		log.Printf("Hata: Agent durum sorgu isteği oluşturulamadı: %v", err)
-		http.Error(w, "Request creation failed", http.StatusInternalServerError)
+	if wait > 0 {
+		if status, ok := o.Events.WaitForChange(ctx, taskID, wait); ok {
+			o.writeTaskStatus(w, status)
+			return
+		}
+	}
+
+	// Kuyruktaki görev henüz agent'a gönderilmedi, sırası Orchestrator'dan döner
+	if status, ok := o.queuedStatus(taskInfo); ok {
+		o.writeTaskStatus(w, status)
 		return
 	}
 
-	agentResp, err := o.HttpClient.Do(agentReq)
+	result, err := o.fetchAgentStatus(ctx, taskInfo)
 	if err != nil {
 		log.Printf("Hata: Agent '%s' durum sorgulanamadı: %v", taskInfo.AgentName, err)
-		http.Error(w, "Agent status check failed", http.StatusServiceUnavailable)
+		o.writeAgentCallError(w, o.agentForTask(taskInfo), taskInfo.Replica, err, "Agent status check failed")
 		return
 	}
-	defer agentResp.Body.Close()
 
-	log.Printf("Agent '%s' durum yanıtı verdi: %s", taskInfo.AgentName, agentResp.Status)
+	body := o.addPollHint(w.Header(), result.Body, result.RetryAfter)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(agentResp.StatusCode)
-	io.Copy(w, agentResp.Body)
+	w.WriteHeader(result.StatusCode)
+	w.Write(body)
 }
 
 // GetToolsSpec'i çağırır ve LLM'in araçları görmesini sağlar
@@ -145,7 +343,8 @@ func (o *Orchestrator) HandleGetTools(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tools := o.Registry.GetToolsSpec()
+	// Kimlik doğrulama açıksa yalnızca kimliğin kullanabileceği agent'lar listelenir
+	tools := o.Registry.GetToolsSpec(principalFrom(r.Context()))
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tools)
 }
@@ -157,24 +356,309 @@ func (o *Orchestrator) HandleTaskStop(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	taskID := strings.TrimPrefix(r.URL.Path, "/task_stop/")
+	taskID := strings.TrimPrefix(r.URL.Path, TaskStopPath)
 
 	taskInfo, ok := o.TaskRegistry.GetTaskInfo(taskID)
 	if !ok {
 		http.Error(w, "Task not found in registry", http.StatusNotFound)
 		return
 	}
+	if !principalFrom(r.Context()).AllowsAgent(taskInfo.AgentName) {
+		http.Error(w, "Agent not allowed for this credential", http.StatusForbidden)
+		return
+	}
 
-	fullStopURL := taskInfo.AgentStopBaseURL + taskID
+	// Henüz gönderilmemiş görev agent'a gitmeden kuyruktan çıkarılır
+	if status, ok := o.stopQueuedTask(taskInfo); ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+		return
+	}
+	if taskInfo, ok = o.dispatchedTaskInfo(taskInfo); !ok {
+		http.Error(w, "Task is being dispatched, try again", http.StatusConflict)
+		return
+	}
+	// Senkron ya da bitmiş görevlerin agent'ta durdurulacak bir karşılığı yoktur
+	if taskFinished(taskInfo) {
+		http.Error(w, "Task already finished", http.StatusConflict)
+		return
+	}
+
+	fullStopURL := taskInfo.AgentStopBaseURL + url.PathEscape(taskInfo.AgentTaskID)
 
-	agentReq, _ := http.NewRequest("POST", fullStopURL, nil)
-	agentResp, err := o.HttpClient.Do(agentReq)
+	agent := o.agentForTask(taskInfo)
+	ctx, cancel := o.withTimeout(r.Context(), agent, opStop)
+	defer cancel()
+
+	agentResp, err := o.callReplica(ctx, agent, taskInfo.Replica, "POST", fullStopURL, false)
 	if err != nil {
-		http.Error(w, "Failed to reach agent", http.StatusServiceUnavailable)
+		o.writeAgentCallError(w, agent, taskInfo.Replica, err, "Failed to reach agent")
 		return
 	}
 	defer agentResp.Body.Close()
 
+	o.writeAgentResponse(w, agentResp, taskInfo)
+}
+
+// stopTask, görevi çalıştıran replikaya durdurma isteği gönderir ve cevabı yok sayar.
+func (o *Orchestrator) stopTask(ctx context.Context, taskInfo TaskInfo) error {
+	if _, ok := o.stopQueuedTask(taskInfo); ok {
+		return nil
+	}
+	taskInfo, ok := o.dispatchedTaskInfo(taskInfo)
+	if !ok {
+		return errors.New("görev agent'a gönderiliyor, durdurulamadı")
+	}
+	if taskFinished(taskInfo) {
+		return ErrTaskFinished
+	}
+
+	agent := o.agentForTask(taskInfo)
+	ctx, cancel := o.withTimeout(ctx, agent, opStop)
+	defer cancel()
+
+	fullStopURL := taskInfo.AgentStopBaseURL + url.PathEscape(taskInfo.AgentTaskID)
+	agentResp, err := o.callReplica(ctx, agent, taskInfo.Replica, "POST", fullStopURL, false)
+	if err != nil {
+		return err
+	}
+	defer agentResp.Body.Close()
+
+	if agentResp.StatusCode < 200 || agentResp.StatusCode > 299 {
+		return fmt.Errorf("agent durdurma endpoint'i %s döndü", agentResp.Status)
+	}
+	return nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+// taskRejection, bir görevin agent'a gönderilmeden önce reddedilme nedenidir.
+type taskRejection struct {
+	HTTPStatus int
+	Message    string
+	Violations []models.SchemaViolation
+}
+
+// validateTask, run_task, run_tasks ve zamanlamaların ortak kontrollerini yapar: öncelik, kimliğin agent
+// yetkisi, agent'ın varlığı ve argümanların şemaya uygunluğu. Kimlik ctx'ten okunur.
+func (o *Orchestrator) validateTask(ctx context.Context, task models.OrchestratorTaskRequest) (models.AgentDefinition, *taskRejection) {
+	if task.Priority != "" && !task.Priority.Valid() {
+		return models.AgentDefinition{}, &taskRejection{HTTPStatus: http.StatusBadRequest, Message: "Invalid priority"}
+	}
+
+	if !principalFrom(ctx).AllowsAgent(task.AgentName) {
+		log.Printf("Hata: İzin verilmeyen agent istendi: %s", task.AgentName)
+		return models.AgentDefinition{}, &taskRejection{HTTPStatus: http.StatusForbidden, Message: "Agent not allowed for this credential"}
+	}
+
+	agent, ok := o.Registry.Get(task.AgentName)
+	if !ok {
+		log.Printf("Hata: Bilinmeyen agent istendi: %s", task.AgentName)
+		return agent, &taskRejection{HTTPStatus: http.StatusNotFound, Message: "Agent not found"}
+	}
+
+	// Argümanlar agent'a gitmeden önce şemaya göre doğrulanır
+	violations, err := o.Registry.ValidateArguments(agent.Name, task.Arguments)
+	if err != nil {
+		log.Printf("Hata: Agent '%s' argümanları doğrulanamadı: %v", agent.Name, err)
+		return agent, &taskRejection{HTTPStatus: http.StatusBadRequest, Message: "Invalid arguments JSON"}
+	}
+	if len(violations) > 0 {
+		log.Printf("Agent '%s' için geçersiz argümanlar: %d şema ihlali", agent.Name, len(violations))
+		return agent, &taskRejection{HTTPStatus: http.StatusBadRequest, Message: "Arguments do not match agent schema", Violations: violations}
+	}
+	return agent, nil
+}
+
+// tracksRunningTasks, agent'ın çalışan asenkron görevlerinin bitişinin izlenmesi gerekip gerekmediğini söyler:
+// max_concurrency yerleri ve birden fazla replika arasında least_in_flight, görevler bitene kadar sayılır.
+func tracksRunningTasks(agent models.AgentDefinition) bool {
+	return agent.MaxConcurrency > 0 || (agent.LoadBalancing == LBLeastInFlight && len(agent.Replicas()) > 1)
+}
+
+// taskFinished, görevin senkron olduğunu ya da son bilinen durumunun terminal olduğunu söyler.
+func taskFinished(taskInfo TaskInfo) bool {
+	return taskInfo.Sync || (taskInfo.LastStatus != nil && taskInfo.LastStatus.Status.Terminal())
+}
+
+// writeAgentCallError, agent'a ulaşılamadığında istemciye dönülecek hatayı yazar.
+// Zaman aşımı 504 olarak ayrıca bildirilir; breaker açıksa cooldown'un bitmesine kalan süre Retry-After olarak döner.
+func (o *Orchestrator) writeAgentCallError(w http.ResponseWriter, agent models.AgentDefinition, replica string, err error, message string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, "Agent timed out", http.StatusGatewayTimeout)
+		return
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		setRetryAfter(w.Header(), o.Breakers.RetryAfter(agent, replica))
+		http.Error(w, "Agent temporarily unavailable (circuit open)", http.StatusServiceUnavailable)
+		return
+	}
+	if errors.Is(err, ErrAgentQueueFull) || errors.Is(err, ErrAgentQueueTimeout) {
+		setRetryAfter(w.Header(), o.Limiter.RetryAfter(agent.Name))
+		http.Error(w, "Agent is busy, try again later", http.StatusTooManyRequests)
+		return
+	}
+	http.Error(w, message, http.StatusServiceUnavailable)
+}
+
+// writeAgentResponse, agent cevabını istemciye aktarır. Cevaptaki "task_id" alanı agent'ın yerel
+// kimliğinden Orchestrator kimliğine çevrilir, böylece istemci agent kimliklerini hiç görmez.
+func (o *Orchestrator) writeAgentResponse(w http.ResponseWriter, agentResp *http.Response, taskInfo TaskInfo) {
+	body, err := io.ReadAll(agentResp.Body)
+	if err != nil {
+		log.Printf("Hata: Agent '%s' cevabı okunamadı: %v", taskInfo.AgentName, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			http.Error(w, "Agent timed out", http.StatusGatewayTimeout)
+			return
+		}
+		http.Error(w, "Agent response read error", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(agentResp.StatusCode)
-	io.Copy(w, agentResp.Body)
+	w.Write(rewriteTaskID(body, taskInfo.TaskID))
+}
+
+// fetchAgentStatus, görevin durumunu agent'a sorar. Aynı göreve eşzamanlı gelen sorgular ve
+// TASK_STATUS_CACHE_TTL içinde tekrarlanan sorgular tek bir agent çağrısının cevabını paylaşır.
+func (o *Orchestrator) fetchAgentStatus(ctx context.Context, taskInfo TaskInfo) (agentStatusResult, error) {
+	return o.StatusCache.Do(ctx, taskInfo.TaskID, func() (agentStatusResult, error) {
+		agent := o.agentForTask(taskInfo)
+		// Paylaşılan çağrı, onu başlatan istemci vazgeçse de diğer bekleyenler için sürer
+		ctx, cancel := o.withTimeout(context.WithoutCancel(ctx), agent, opStatus)
+		defer cancel()
+
+		// Durum sorgusu idempotent olduğu için agent'ın retry politikasına göre tekrar denenebilir
+		fullStatusURL := taskInfo.AgentStatusBaseURL + url.PathEscape(taskInfo.AgentTaskID)
+		agentResp, err := o.callReplica(ctx, agent, taskInfo.Replica, "GET", fullStatusURL, true)
+		if err != nil {
+			return agentStatusResult{}, err
+		}
+		defer agentResp.Body.Close()
+
+		body, err := io.ReadAll(agentResp.Body)
+		if err != nil {
+			return agentStatusResult{}, err
+		}
+		log.Printf("Agent '%s' durum yanıtı verdi: %s", taskInfo.AgentName, agentResp.Status)
+
+		body = rewriteTaskID(body, taskInfo.TaskID)
+		o.recordTaskStatus(taskInfo.TaskID, agentResp.StatusCode, body)
+		return agentStatusResult{
+			StatusCode: agentResp.StatusCode,
+			Body:       body,
+			RetryAfter: agentResp.Header.Get("Retry-After"),
+		}, nil
+	})
+}
+
+// finishedStatus, görevin bittiği biliniyorsa saklanan sonucu döndürür. TASK_RESULT_TTL tanımlıysa
+// bu süreden eski sonuçlar artık sunulmaz ve durum yeniden agent'a sorulur. Agent'a sorulamayan
+// senkron görevlerin sonucu görev defterde kaldığı sürece sunulur.
+func (o *Orchestrator) finishedStatus(taskInfo TaskInfo) (models.TaskStatusResponse, bool) {
+	if taskInfo.LastStatus == nil || !taskInfo.LastStatus.Status.Terminal() {
+		return models.TaskStatusResponse{}, false
+	}
+	if ttl := o.Config.TaskResultTTL; ttl > 0 && !taskInfo.Sync && taskInfo.LastUpdate != nil && time.Since(*taskInfo.LastUpdate) > ttl {
+		return models.TaskStatusResponse{}, false
+	}
+	return *taskInfo.LastStatus, true
+}
+
+// recordTaskStatus, agent'ın başarılı durum cevabını görevin son bilinen durumu olarak kaydeder.
+func (o *Orchestrator) recordTaskStatus(taskID string, statusCode int, body []byte) {
+	if statusCode < 200 || statusCode > 299 {
+		return
+	}
+	var status models.TaskStatusResponse
+	if err := json.Unmarshal(body, &status); err != nil || !status.Status.Valid() {
+		return
+	}
+	if err := o.TaskRegistry.UpdateStatus(taskID, status); err != nil && !errors.Is(err, ErrTaskFinished) {
+		log.Printf("Uyarı: Görevin son durumu kaydedilemedi (TaskID %s): %v", taskID, err)
+	}
+}
+
+// writeTaskStatus, Orchestrator'ın bildiği bir görev durumunu bekleme önerisiyle birlikte yazar.
+func (o *Orchestrator) writeTaskStatus(w http.ResponseWriter, status models.TaskStatusResponse) {
+	status.NextPollAfterMs = 0
+	if !status.Status.Terminal() {
+		hint := o.Config.TaskEventsPollInterval
+		status.NextPollAfterMs = hint.Milliseconds()
+		setRetryAfter(w.Header(), hint)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// addPollHint, bitmemiş görevlerin durum cevabına next_poll_after_ms alanını ve Retry-After başlığını ekler.
+// Öneri sırasıyla agent'ın gövdesindeki next_poll_after_ms'ten, agent'ın Retry-After başlığından ya da
+// Orchestrator'ın yoklama aralığından alınır. Gövde bir görev durumu değilse olduğu gibi döner.
+func (o *Orchestrator) addPollHint(header http.Header, body []byte, agentRetryAfter string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	var status models.TaskStatus
+	if err := json.Unmarshal(fields["status"], &status); err != nil || !status.Valid() || status.Terminal() {
+		return body
+	}
+
+	var agentHintMs int64
+	if raw, ok := fields["next_poll_after_ms"]; ok && json.Unmarshal(raw, &agentHintMs) == nil && agentHintMs > 0 {
+		setRetryAfter(header, time.Duration(agentHintMs)*time.Millisecond)
+		return body
+	}
+
+	hint := o.Config.TaskEventsPollInterval
+	if seconds, err := strconv.Atoi(agentRetryAfter); err == nil && seconds > 0 {
+		hint = time.Duration(seconds) * time.Second
+	}
+	setRetryAfter(header, hint)
+
+	fields["next_poll_after_ms"], _ = json.Marshal(hint.Milliseconds())
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// parseWait, ?wait= parametresini okur ve TASK_STATUS_MAX_WAIT ile sınırlar. Parametre yoksa 0 döner.
+func (o *Orchestrator) parseWait(r *http.Request) (time.Duration, error) {
+	raw := r.URL.Query().Get("wait")
+	if raw == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("geçersiz wait değeri: %s", raw)
+	}
+	return min(wait, o.Config.TaskStatusMaxWait), nil
+}
+
+// setRetryAfter, süreyi saniyeye yukarı yuvarlayarak Retry-After başlığına yazar; en az 1 saniyedir.
+func setRetryAfter(header http.Header, d time.Duration) {
+	header.Set("Retry-After", strconv.Itoa(max(int(math.Ceil(d.Seconds())), 1)))
+}
+
+// rewriteTaskID, JSON nesnesi olan bir gövdede "task_id" alanı varsa değerini değiştirir.
+// Gövde JSON nesnesi değilse ya da alan yoksa olduğu gibi döner.
+func rewriteTaskID(body []byte, taskID string) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	if _, ok := fields["task_id"]; !ok {
+		return body
+	}
+
+	fields["task_id"], _ = json.Marshal(taskID)
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return rewritten
 }
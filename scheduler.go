@@ -0,0 +1,566 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/robfig/cron/v3"
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Kapalı kalınan sürede kaçırılan çalıştırmalar için catch-up politikaları
+const (
+	// Kaçırılan çalıştırmalar atlanır, zamanlama bir sonraki zamanından devam eder
+	CatchUpSkip = "skip"
+	// Kaçırılan çalıştırmalar için yalnızca bir kez çalıştırılır
+	CatchUpOnce = "once"
+	// Kaçırılan her çalıştırma sırayla yapılır (en fazla maxCatchUpRuns)
+	CatchUpAll = "all"
+)
+
+// "all" politikasında uzun bir kesintiden sonra agent'ın boğulmaması için üst sınır
+const maxCatchUpRuns = 100
+
+// Zamanlayıcının vadesi gelen zamanlamaları kontrol etme aralığı
+const schedulerTickInterval = time.Second
+
+// Son çalıştırmanın hata gövdesinden saklanan en fazla bayt
+const maxScheduleErrorSize = 512
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	errInvalidSchedule  = errors.New("geçersiz zamanlama")
+)
+
+// scheduleEntry, zamanlamanın kendisi ve cron ifadesinin ayrıştırılmış halidir.
+type scheduleEntry struct {
+	models.ScheduleResponse
+	cron cron.Schedule
+	// Zamanlamayı oluşturan kimlik; her çalıştırmada güncel hali Authenticator'dan alınır
+	owner *Principal
+	// Zamanlama şu anda çalıştırılıyor; bir önceki çalıştırma bitmeden yenisi başlatılmaz
+	running bool
+}
+
+// savedSchedule, zamanlamanın dosyadaki halidir. Oluşturan kimlik API cevaplarında gösterilmez.
+type savedSchedule struct {
+	models.ScheduleResponse
+	Owner *Principal `json:"owner,omitempty"`
+}
+
+// Scheduler, run_at ve schedule verilen görevleri zamanı geldiğinde run_task ile aynı kontrollerden geçirerek
+// çalıştırır. Her çalıştırma zamanlamayı oluşturan kimliğin güncel yetkileriyle yapılır, böylece silinen bir
+// API anahtarının zamanlamaları da durur. Zamanlamalar bir JSON dosyasında saklanır, böylece yeniden
+// başlatmada kaybolmaz. Kapalı kalınan sürede kaçırılan çalıştırmalar açılışta catch-up politikasına göre yapılır.
+type Scheduler struct {
+	auth      *Authenticator
+	path      string
+	catchUp   string
+	retention time.Duration
+	// Görevi başlatan fonksiyon; Orchestrator.submitTask
+	submit func(ctx context.Context, task models.OrchestratorTaskRequest, caller string) models.BatchTaskResult
+
+	mu        sync.Mutex
+	schedules map[string]*scheduleEntry
+}
+
+// NewScheduler, kayıtlı zamanlamaları dosyadan yükler ve kaçırılan çalıştırmalara catch-up politikasını uygular.
+func NewScheduler(o *Orchestrator, auth *Authenticator, path, catchUp string, retention time.Duration) (*Scheduler, error) {
+	s := &Scheduler{
+		auth:      auth,
+		path:      path,
+		catchUp:   catchUp,
+		retention: retention,
+		submit:    o.submitTask,
+		schedules: make(map[string]*scheduleEntry),
+	}
+	if err := s.load(time.Now()); err != nil {
+		return nil, err
+	}
+	log.Printf("Zamanlamalar yüklendi: %s (%d zamanlama, catch-up: %s)", path, len(s.schedules), catchUp)
+	return s, nil
+}
+
+// Start, vadesi gelen zamanlamaları arka planda çalıştırır.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(schedulerTickInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.runDue(now)
+			}
+		}
+	}()
+}
+
+// Create, doğrulanmış bir run_task isteğinden zamanlama oluşturur. principal, isteği yapan kimliktir.
+func (s *Scheduler) Create(task models.OrchestratorTaskRequest, caller string, principal *Principal) (models.ScheduleResponse, error) {
+	if task.RunAt != nil && task.Schedule != "" {
+		return models.ScheduleResponse{}, fmt.Errorf("%w: run_at ve schedule birlikte verilemez", errInvalidSchedule)
+	}
+
+	now := time.Now()
+	entry := &scheduleEntry{ScheduleResponse: models.ScheduleResponse{
+		ScheduleID: NewTaskID(),
+		RunAt:      task.RunAt,
+		Cron:       task.Schedule,
+		Caller:     caller,
+		State:      models.ScheduleActive,
+		CreatedAt:  now,
+	}, owner: principal}
+	task.RunAt, task.Schedule = nil, ""
+	entry.Task = task
+
+	if entry.Cron != "" {
+		parsed, err := cron.ParseStandard(entry.Cron)
+		if err != nil {
+			return models.ScheduleResponse{}, fmt.Errorf("%w: cron ifadesi: %v", errInvalidSchedule, err)
+		}
+		entry.cron = parsed
+		next := parsed.Next(now)
+		entry.NextRun = &next
+	} else {
+		runAt := *entry.RunAt
+		entry.NextRun = &runAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules[entry.ScheduleID] = entry
+	if err := s.saveLocked(); err != nil {
+		delete(s.schedules, entry.ScheduleID)
+		return models.ScheduleResponse{}, err
+	}
+	log.Printf("Zamanlama oluşturuldu: %s -> Agent '%s' (ilk çalıştırma: %s)", entry.ScheduleID, task.AgentName, entry.NextRun.Format(time.RFC3339))
+	return entry.ScheduleResponse, nil
+}
+
+// List, zamanlamaları oluşturulma zamanına göre sıralı döndürür.
+func (s *Scheduler) List() []models.ScheduleResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]models.ScheduleResponse, 0, len(s.schedules))
+	for _, entry := range s.schedules {
+		list = append(list, entry.ScheduleResponse)
+	}
+	slices.SortFunc(list, func(a, b models.ScheduleResponse) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return list
+}
+
+func (s *Scheduler) Get(scheduleID string) (models.ScheduleResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.schedules[scheduleID]
+	if !ok {
+		return models.ScheduleResponse{}, false
+	}
+	return entry.ScheduleResponse, true
+}
+
+func (s *Scheduler) Delete(scheduleID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.schedules[scheduleID]
+	if !ok {
+		return ErrScheduleNotFound
+	}
+	delete(s.schedules, scheduleID)
+	if err := s.saveLocked(); err != nil {
+		s.schedules[scheduleID] = entry
+		return err
+	}
+	log.Printf("Zamanlama silindi: %s", scheduleID)
+	return nil
+}
+
+// SetPaused, zamanlamayı duraklatır ya da devam ettirir. Duraklatılmışken kaçırılan çalıştırmalar yapılmaz,
+// devam edince zamanlama bir sonraki zamanından sürer.
+func (s *Scheduler) SetPaused(scheduleID string, paused bool) (models.ScheduleResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.schedules[scheduleID]
+	if !ok {
+		return models.ScheduleResponse{}, ErrScheduleNotFound
+	}
+	if entry.State == models.ScheduleCompleted {
+		return entry.ScheduleResponse, nil
+	}
+
+	previous := entry.ScheduleResponse
+	if paused {
+		entry.State = models.SchedulePaused
+	} else if entry.State == models.SchedulePaused {
+		entry.State = models.ScheduleActive
+		if entry.cron != nil {
+			next := entry.cron.Next(time.Now())
+			entry.NextRun = &next
+		}
+	}
+	if err := s.saveLocked(); err != nil {
+		entry.ScheduleResponse = previous
+		return models.ScheduleResponse{}, err
+	}
+	log.Printf("Zamanlama %s: %s", entry.State, scheduleID)
+	return entry.ScheduleResponse, nil
+}
+
+// HandleSchedules, /api/v1/schedules üzerinden zamanlamaları listeler.
+func (s *Scheduler) HandleSchedules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	principal := principalFrom(r.Context())
+	schedules := make([]models.ScheduleResponse, 0)
+	for _, schedule := range s.List() {
+		if principal.AllowsAgent(schedule.Task.AgentName) {
+			schedules = append(schedules, schedule)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// HandleSchedule, /api/v1/schedules/{id} üzerinde GET ve DELETE işlemlerini yürütür.
+func (s *Scheduler) HandleSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := r.PathValue("id")
+	if !s.allowed(r, scheduleID) {
+		http.Error(w, "Agent not allowed for this credential", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		schedule, ok := s.Get(scheduleID)
+		if !ok {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedule)
+
+	case "DELETE":
+		if err := s.Delete(scheduleID); err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Only GET and DELETE methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleSchedulePause, /api/v1/schedules/{id}/pause ve /resume üzerinden zamanlamayı duraklatır ya da devam ettirir.
+func (s *Scheduler) HandleSchedulePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !s.allowed(r, r.PathValue("id")) {
+			http.Error(w, "Agent not allowed for this credential", http.StatusForbidden)
+			return
+		}
+
+		schedule, err := s.SetPaused(r.PathValue("id"), paused)
+		if err != nil {
+			writeScheduleError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(schedule)
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// scheduleTask, run_at ya da schedule verilen run_task isteği için zamanlama oluşturur ve 201 döner.
+func (o *Orchestrator) scheduleTask(w http.ResponseWriter, task models.OrchestratorTaskRequest, caller string, principal *Principal) {
+	if o.Scheduler == nil {
+		http.Error(w, "Scheduling is not enabled", http.StatusBadRequest)
+		return
+	}
+	if task.RunAt != nil && task.Schedule != "" {
+		http.Error(w, "run_at and schedule cannot be used together", http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := o.Scheduler.Create(task, caller, principal)
+	switch {
+	case errors.Is(err, errInvalidSchedule):
+		log.Printf("Hata: Zamanlama oluşturulamadı: %v", err)
+		http.Error(w, "Invalid schedule expression", http.StatusBadRequest)
+		return
+	case err != nil:
+		writeScheduleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// runDue, vadesi gelen zamanlamaları çalıştırır ve saklama süresi dolan tamamlanmış zamanlamaları siler.
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for id, entry := range s.schedules {
+		if entry.State == models.ScheduleCompleted && !entry.running && s.retention > 0 {
+			finishedAt := entry.CreatedAt
+			if entry.LastRun != nil {
+				finishedAt = *entry.LastRun
+			}
+			if now.Sub(finishedAt) > s.retention {
+				delete(s.schedules, id)
+				changed = true
+			}
+			continue
+		}
+		if entry.State != models.ScheduleActive || entry.running || entry.NextRun == nil || entry.NextRun.After(now) {
+			continue
+		}
+
+		entry.running = true
+		s.advanceLocked(entry, now)
+		changed = true
+		go s.run(entry, 1)
+	}
+
+	if changed {
+		if err := s.saveLocked(); err != nil {
+			log.Printf("Hata: Zamanlamalar kaydedilemedi: %v", err)
+		}
+	}
+}
+
+// run, zamanlanmış görevi times kez sırayla başlatır ve son sonucu zamanlamaya yazar.
+func (s *Scheduler) run(entry *scheduleEntry, times int) {
+	s.mu.Lock()
+	task, caller, owner, scheduleID := entry.Task, entry.Caller, entry.owner, entry.ScheduleID
+	s.mu.Unlock()
+
+	for range times {
+		log.Printf("Zamanlanmış görev çalıştırılıyor: %s -> Agent '%s'", scheduleID, task.AgentName)
+		result, err := s.start(task, caller, owner)
+
+		s.mu.Lock()
+		now := time.Now()
+		entry.LastRun = &now
+		entry.RunCount++
+		entry.LastHTTPStatus = result.HTTPStatus
+		entry.LastTaskID = result.TaskID
+		entry.LastError = ""
+		if result.HTTPStatus >= 400 {
+			entry.LastError = truncateUTF8(result.Error, maxScheduleErrorSize)
+			log.Printf("Hata: Zamanlanmış görev başarısız: %s (%d)", scheduleID, result.HTTPStatus)
+		}
+		// Süresi dolan JWT'yle oluşturulan tekrarlı zamanlama, boş yere çalıştırılmaya devam etmesin diye duraklatılır
+		expired := errors.Is(err, ErrCredentialExpired)
+		if expired && entry.State == models.ScheduleActive {
+			entry.State = models.SchedulePaused
+			log.Printf("Zamanlama duraklatıldı, oluşturan token'ın süresi doldu: %s", scheduleID)
+		}
+		s.mu.Unlock()
+		if expired {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.running = false
+	if err := s.saveLocked(); err != nil {
+		log.Printf("Hata: Zamanlamalar kaydedilemedi: %v", err)
+	}
+}
+
+// start, görevi zamanlamayı oluşturan kimliğin güncel yetkileriyle başlatır. Kimlik artık geçerli değilse
+// ya da görev başlatma yetkisi kalmadıysa görev gönderilmez; kimliğin geçersiz olma nedeni hata olarak döner.
+func (s *Scheduler) start(task models.OrchestratorTaskRequest, caller string, owner *Principal) (models.BatchTaskResult, error) {
+	principal, err := s.auth.Current(owner)
+	if errors.Is(err, ErrCredentialExpired) {
+		log.Printf("Hata: Zamanlamanın token'ının süresi doldu (%s)", caller)
+		return batchError(http.StatusForbidden, "Schedule creator's token has expired"), err
+	}
+	if err != nil {
+		log.Printf("Hata: Zamanlamanın kimliği artık geçerli değil (%s): %v", caller, err)
+		return batchError(http.StatusForbidden, "Schedule creator's credential is no longer valid"), err
+	}
+	if !principal.Allows(models.OperationRunTask) {
+		log.Printf("Hata: '%s' kimliğinin '%s' yetkisi yok (zamanlama)", principal.Name, models.OperationRunTask)
+		return batchError(http.StatusForbidden, "Operation not allowed for this credential"), nil
+	}
+
+	ctx := context.WithValue(context.Background(), principalKey{}, principal)
+	return s.submit(ctx, task, caller), nil
+}
+
+// advanceLocked, zamanlamanın bir sonraki çalıştırma zamanını now'dan sonrasına taşır. Tek seferlik
+// zamanlamalar tamamlanır. s.mu tutulurken çağrılmalıdır.
+func (s *Scheduler) advanceLocked(entry *scheduleEntry, now time.Time) {
+	if entry.cron == nil {
+		entry.State = models.ScheduleCompleted
+		entry.NextRun = nil
+		return
+	}
+	next := entry.cron.Next(now)
+	entry.NextRun = &next
+}
+
+// missedRuns, zamanlamanın now'a kadar kaçırdığı çalıştırma sayısını maxCatchUpRuns ile sınırlı olarak sayar.
+func missedRuns(entry *scheduleEntry, now time.Time) int {
+	if entry.NextRun == nil || entry.NextRun.After(now) {
+		return 0
+	}
+	if entry.cron == nil {
+		return 1
+	}
+
+	missed := 0
+	for at := *entry.NextRun; !at.After(now) && missed < maxCatchUpRuns; at = entry.cron.Next(at) {
+		missed++
+	}
+	return missed
+}
+
+// load, zamanlamaları dosyadan okur ve now'a kadar kaçırılan çalıştırmalara catch-up politikasını uygular.
+// Başlatılan catch-up çalıştırmaları, dosya yazılana kadar s.mu'yu bekler.
+func (s *Scheduler) load(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var saved []savedSchedule
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("zamanlama dosyası okunamadı: %v", err)
+	}
+
+	for _, item := range saved {
+		schedule := item.ScheduleResponse
+		entry := &scheduleEntry{ScheduleResponse: schedule, owner: item.Owner}
+		if schedule.Cron != "" {
+			if entry.cron, err = cron.ParseStandard(schedule.Cron); err != nil {
+				return fmt.Errorf("zamanlama %s: geçersiz cron ifadesi: %v", schedule.ScheduleID, err)
+			}
+		}
+		s.schedules[schedule.ScheduleID] = entry
+
+		if entry.State != models.ScheduleActive {
+			continue
+		}
+		missed := missedRuns(entry, now)
+		if missed == 0 {
+			continue
+		}
+
+		runs := 0
+		switch s.catchUp {
+		case CatchUpOnce:
+			runs = 1
+		case CatchUpAll:
+			runs = missed
+		}
+		log.Printf("Zamanlama %s: kapalıyken %d çalıştırma kaçırıldı, %d tanesi yapılacak (catch-up: %s)", schedule.ScheduleID, missed, runs, s.catchUp)
+
+		s.advanceLocked(entry, now)
+		if runs == 0 {
+			if entry.cron == nil {
+				entry.LastError = "Skipped by catch-up policy"
+			}
+			continue
+		}
+		entry.running = true
+		go s.run(entry, runs)
+	}
+	return s.saveLocked()
+}
+
+// saveLocked, zamanlamaları geçici bir dosyaya yazıp atomik olarak taşır. s.mu tutulurken çağrılmalıdır.
+func (s *Scheduler) saveLocked() error {
+	list := make([]savedSchedule, 0, len(s.schedules))
+	for _, entry := range s.schedules {
+		list = append(list, savedSchedule{ScheduleResponse: entry.ScheduleResponse, Owner: entry.owner})
+	}
+	slices.SortFunc(list, func(a, b savedSchedule) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	out, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(out, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.path)
+}
+
+// allowed, isteği yapan kimliğin zamanlamanın agent'ını kullanıp kullanamayacağını söyler. Bilinmeyen
+// zamanlamalar için true döner, böylece handler 404 verir.
+func (s *Scheduler) allowed(r *http.Request, scheduleID string) bool {
+	schedule, ok := s.Get(scheduleID)
+	return !ok || principalFrom(r.Context()).AllowsAgent(schedule.Task.AgentName)
+}
+
+func writeScheduleError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrScheduleNotFound) {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Hata: Zamanlama güncellenemedi: %v", err)
+	http.Error(w, "Failed to persist schedule", http.StatusInternalServerError)
+}
+
+// truncateUTF8, metni en fazla max bayta kısaltır. Kesim çok baytlı bir karakterin ortasına denk gelirse
+// karakterin başına geri çekilir, böylece sonuç geçerli UTF-8 kalır.
+func truncateUTF8(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	end := max
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end]
+}
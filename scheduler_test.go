@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/robfig/cron/v3"
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Testlerde "şimdi" olarak kullanılan sabit zaman
+var schedulerNow = time.Date(2026, 10, 16, 12, 30, 0, 0, time.UTC)
+
+func at(hour, minute int) *time.Time {
+	t := time.Date(2026, 10, 16, hour, minute, 0, 0, time.UTC)
+	return &t
+}
+
+func cronEntry(t *testing.T, expr string, next *time.Time) *scheduleEntry {
+	t.Helper()
+	parsed, err := cron.ParseStandard(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &scheduleEntry{ScheduleResponse: models.ScheduleResponse{Cron: expr, NextRun: next}, cron: parsed}
+}
+
+func TestMissedRuns(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *scheduleEntry
+		want  int
+	}{
+		{"zamanı gelmemiş tek seferlik", &scheduleEntry{ScheduleResponse: models.ScheduleResponse{NextRun: at(13, 0)}}, 0},
+		{"zamanı geçmiş tek seferlik", &scheduleEntry{ScheduleResponse: models.ScheduleResponse{NextRun: at(9, 0)}}, 1},
+		{"tamamlanmış tek seferlik", &scheduleEntry{}, 0},
+		{"zamanı gelmemiş cron", cronEntry(t, "0 * * * *", at(13, 0)), 0},
+		{"tam zamanında cron", cronEntry(t, "30 * * * *", at(12, 30)), 1},
+		{"saatlik cron, üç çalıştırma kaçırılmış", cronEntry(t, "0 * * * *", at(10, 0)), 3},
+		{"dakikalık cron, üst sınıra takılır", cronEntry(t, "@every 1m", at(0, 0)), maxCatchUpRuns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missedRuns(tt.entry, schedulerNow); got != tt.want {
+				t.Errorf("missedRuns = %d, beklenen %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// submitRecorder, Scheduler'ın başlattığı görevleri agent adına göre sayar.
+type submitRecorder struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *submitRecorder) submit(ctx context.Context, task models.OrchestratorTaskRequest, caller string) models.BatchTaskResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[task.AgentName]++
+	return models.BatchTaskResult{HTTPStatus: http.StatusAccepted, TaskID: fmt.Sprintf("%s-%d", task.AgentName, r.calls[task.AgentName])}
+}
+
+func (r *submitRecorder) count(agentName string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls[agentName]
+}
+
+// newTestScheduler, zamanlamaları geçici bir dosyaya yazar ve Scheduler'ı bu dosyadan now zamanında yükler.
+func newTestScheduler(t *testing.T, auth *Authenticator, catchUp string, schedules []savedSchedule) (*Scheduler, *submitRecorder) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	data, err := json.Marshal(schedules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &submitRecorder{calls: make(map[string]int)}
+	s := &Scheduler{
+		auth:      auth,
+		path:      path,
+		catchUp:   catchUp,
+		submit:    recorder.submit,
+		schedules: make(map[string]*scheduleEntry),
+	}
+	if err := s.load(schedulerNow); err != nil {
+		t.Fatal(err)
+	}
+	return s, recorder
+}
+
+// waitIdle, catch-up çalıştırmaları bitene kadar bekler ve zamanlamanın son halini döndürür.
+func waitIdle(t *testing.T, s *Scheduler, scheduleID string) models.ScheduleResponse {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		entry := s.schedules[scheduleID]
+		running, schedule := entry.running, entry.ScheduleResponse
+		s.mu.Unlock()
+
+		if !running {
+			return schedule
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("zamanlama %s çalıştırması bitmedi", scheduleID)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func savedFixture(id, agentName, expr string, runAt, next *time.Time, state models.ScheduleState) savedSchedule {
+	return savedSchedule{ScheduleResponse: models.ScheduleResponse{
+		ScheduleID: id,
+		Task:       models.OrchestratorTaskRequest{AgentName: agentName},
+		RunAt:      runAt,
+		Cron:       expr,
+		State:      state,
+		CreatedAt:  *at(0, 0),
+		NextRun:    next,
+	}}
+}
+
+func TestLoadCatchUp(t *testing.T) {
+	fixtures := []savedSchedule{
+		// 10:00, 11:00 ve 12:00 kaçırıldı
+		savedFixture("hourly", "hourly_agent", "0 * * * *", nil, at(10, 0), models.ScheduleActive),
+		savedFixture("oneshot", "oneshot_agent", "", at(11, 0), at(11, 0), models.ScheduleActive),
+		// 12 saatte 720 çalıştırma kaçırıldı
+		savedFixture("minutely", "minutely_agent", "@every 1m", nil, at(0, 30), models.ScheduleActive),
+		savedFixture("paused", "paused_agent", "0 * * * *", nil, at(10, 0), models.SchedulePaused),
+		savedFixture("future", "future_agent", "0 * * * *", nil, at(13, 0), models.ScheduleActive),
+	}
+
+	tests := []struct {
+		catchUp string
+		// Agent adına göre beklenen çalıştırma sayıları
+		want map[string]int
+	}{
+		{CatchUpSkip, map[string]int{"hourly_agent": 0, "oneshot_agent": 0, "minutely_agent": 0}},
+		{CatchUpOnce, map[string]int{"hourly_agent": 1, "oneshot_agent": 1, "minutely_agent": 1}},
+		{CatchUpAll, map[string]int{"hourly_agent": 3, "oneshot_agent": 1, "minutely_agent": maxCatchUpRuns}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.catchUp, func(t *testing.T) {
+			s, recorder := newTestScheduler(t, &Authenticator{}, tt.catchUp, fixtures)
+
+			for _, fixture := range fixtures {
+				schedule := waitIdle(t, s, fixture.ScheduleID)
+				want := tt.want[fixture.Task.AgentName]
+				if got := recorder.count(fixture.Task.AgentName); got != want {
+					t.Errorf("%s: %d çalıştırma, beklenen %d", fixture.ScheduleID, got, want)
+				}
+				if schedule.RunCount != want {
+					t.Errorf("%s: run_count %d, beklenen %d", fixture.ScheduleID, schedule.RunCount, want)
+				}
+				if want > 0 && (schedule.LastHTTPStatus != http.StatusAccepted || schedule.LastTaskID != fmt.Sprintf("%s-%d", fixture.Task.AgentName, want)) {
+					t.Errorf("%s: son sonuç yazılmadı: %d %q", fixture.ScheduleID, schedule.LastHTTPStatus, schedule.LastTaskID)
+				}
+			}
+
+			// Cron zamanlamaları now'dan sonraki ilk zamana ilerler, tek seferlik zamanlama tamamlanır
+			for id, next := range map[string]*time.Time{"hourly": at(13, 0), "minutely": at(12, 31), "paused": at(10, 0), "future": at(13, 0)} {
+				if schedule, _ := s.Get(id); schedule.NextRun == nil || !schedule.NextRun.Equal(*next) {
+					t.Errorf("%s: next_run %v, beklenen %v", id, schedule.NextRun, next)
+				}
+			}
+			oneshot, _ := s.Get("oneshot")
+			if oneshot.State != models.ScheduleCompleted || oneshot.NextRun != nil {
+				t.Errorf("tek seferlik zamanlama tamamlanmadı: %s %v", oneshot.State, oneshot.NextRun)
+			}
+			wantError := ""
+			if tt.catchUp == CatchUpSkip {
+				wantError = "Skipped by catch-up policy"
+			}
+			if oneshot.LastError != wantError {
+				t.Errorf("tek seferlik zamanlamanın hatası %q, beklenen %q", oneshot.LastError, wantError)
+			}
+
+			// Catch-up sonrası hal dosyaya yazılır, böylece tekrar başlatmada aynı çalıştırmalar yapılmaz
+			var saved []savedSchedule
+			data, _ := os.ReadFile(s.path)
+			if err := json.Unmarshal(data, &saved); err != nil {
+				t.Fatal(err)
+			}
+			for _, schedule := range saved {
+				if schedule.ScheduleID == "hourly" && !schedule.NextRun.Equal(*at(13, 0)) {
+					t.Errorf("dosyadaki next_run %v, beklenen 13:00", schedule.NextRun)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduledRunUsesCreatorCredential(t *testing.T) {
+	auth := &Authenticator{apiKeys: map[string]*Principal{
+		keyHash("runner-key"): {Name: "runner", Kind: PrincipalAPIKey, Agents: []string{"*"}, Operations: []models.AuthOperation{models.OperationRunTask}},
+		keyHash("reader-key"): {Name: "reader", Kind: PrincipalAPIKey, Agents: []string{"*"}, Operations: []models.AuthOperation{models.OperationTaskStatus}},
+	}}
+	// JWT kimlikleri yalnızca JWT kabulü açıkken geçerlidir
+	auth.parser = jwt.NewParser()
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	jwtOwner := func(expiresAt *time.Time) *Principal {
+		return &Principal{Name: "llm-gateway", Kind: PrincipalJWT, Agents: []string{"*"}, Operations: []models.AuthOperation{models.OperationRunTask}, ExpiresAt: expiresAt}
+	}
+
+	tests := []struct {
+		name       string
+		owner      *Principal
+		cron       string
+		wantStatus int
+		wantCalls  int
+		wantState  models.ScheduleState
+	}{
+		{"geçerli anahtar", &Principal{Name: "runner", Kind: PrincipalAPIKey}, "", http.StatusAccepted, 1, models.ScheduleCompleted},
+		{"silinmiş anahtar", &Principal{Name: "revoked", Kind: PrincipalAPIKey}, "", http.StatusForbidden, 0, models.ScheduleCompleted},
+		{"run_task yetkisi kalmamış anahtar", &Principal{Name: "reader", Kind: PrincipalAPIKey}, "", http.StatusForbidden, 0, models.ScheduleCompleted},
+		{"kimliksiz zamanlama", nil, "", http.StatusForbidden, 0, models.ScheduleCompleted},
+		{"süresi dolmamış JWT", jwtOwner(&future), "0 * * * *", http.StatusAccepted, 1, models.ScheduleActive},
+		{"süresi dolmuş JWT tekrarlı zamanlamayı duraklatır", jwtOwner(&past), "0 * * * *", http.StatusForbidden, 0, models.SchedulePaused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runAt *time.Time
+			if tt.cron == "" {
+				runAt = at(12, 0)
+			}
+			fixture := savedFixture("owned", "echo", tt.cron, runAt, at(12, 0), models.ScheduleActive)
+			fixture.Owner = tt.owner
+			s, recorder := newTestScheduler(t, auth, CatchUpOnce, []savedSchedule{fixture})
+
+			schedule := waitIdle(t, s, "owned")
+			if schedule.LastHTTPStatus != tt.wantStatus {
+				t.Errorf("last_http_status %d, beklenen %d (%s)", schedule.LastHTTPStatus, tt.wantStatus, schedule.LastError)
+			}
+			if got := recorder.count("echo"); got != tt.wantCalls {
+				t.Errorf("%d çalıştırma, beklenen %d", got, tt.wantCalls)
+			}
+			if schedule.State != tt.wantState {
+				t.Errorf("durum %s, beklenen %s", schedule.State, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		max  int
+		want string
+	}{
+		{"kısa metin olduğu gibi kalır", "hata", 10, "hata"},
+		{"tam sınırda", "hata", 4, "hata"},
+		{"ASCII bayttan kesilir", "agent hatası", 5, "agent"},
+		// "ğ" ve "ı" ikişer bayttır; 9. bayt "ı"nın ortasına düşer
+		{"çok baytlı karakter bölünmez", "bağlantı", 9, "bağlant"},
+		{"ilk karakter sığmazsa boş", "ğ", 1, ""},
+		{"dört baytlı karakter", "ok🙂", 4, "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateUTF8(tt.text, tt.max)
+			if got != tt.want {
+				t.Errorf("%q, beklenen %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > tt.max {
+				t.Errorf("geçersiz kesim: %q", got)
+			}
+		})
+	}
+}
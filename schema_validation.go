@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/uslanozan/Go-Smith/models"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// compileAgentSchema, agent tanımındaki JSON şemasını bir kez derler.
+// Şeması olmayan agent'lar için nil döner, bu durumda argümanlar doğrulanmadan iletilir.
+func compileAgentSchema(def models.AgentDefinition) (*gojsonschema.Schema, error) {
+	if len(def.Schema) == 0 || string(def.Schema) == "null" {
+		return nil, nil
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(def.Schema))
+	if err != nil {
+		return nil, fmt.Errorf("agent '%s' şeması derlenemedi: %w", def.Name, err)
+	}
+	return schema, nil
+}
+
+// validateArguments, argümanları derlenmiş şemaya göre doğrular ve bütün ihlalleri JSON pointer'larıyla döndürür.
+func validateArguments(schema *gojsonschema.Schema, args json.RawMessage) ([]models.SchemaViolation, error) {
+	if schema == nil {
+		return nil, nil
+	}
+
+	// Argüman hiç gönderilmediyse "null" olarak doğrulanır, böylece şema tipi hatası raporlanır
+	if len(args) == 0 {
+		args = json.RawMessage("null")
+	}
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(args))
+	if err != nil {
+		return nil, err
+	}
+	if result.Valid() {
+		return nil, nil
+	}
+
+	violations := make([]models.SchemaViolation, 0, len(result.Errors()))
+	for _, resErr := range result.Errors() {
+		violations = append(violations, models.SchemaViolation{
+			Pointer: violationPointer(resErr),
+			Message: resErr.Description(),
+		})
+	}
+	return violations, nil
+}
+
+// Bağlamın parçalarını ayırmak için kullanılan, alan adlarında bulunması beklenmeyen ayraç
+const contextTokenSeparator = "\x00"
+
+// violationPointer, gojsonschema'nın "(root).a.b" biçimindeki bağlamını "/a/b" JSON pointer'ına çevirir.
+// Alan adlarındaki "~" ve "/" RFC 6901'e göre "~0" ve "~1" olarak yazılır. Eksik ya da izin verilmeyen
+// alanlarda pointer, hatanın ait olduğu alanı gösterecek şekilde uzatılır.
+func violationPointer(resErr gojsonschema.ResultError) string {
+	// İlk parça her zaman "(root)" bağlamıdır
+	tokens := strings.Split(resErr.Context().String(contextTokenSeparator), contextTokenSeparator)[1:]
+
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/" + escapePointerToken(token))
+	}
+
+	switch resErr.(type) {
+	case *gojsonschema.RequiredError, *gojsonschema.AdditionalPropertyNotAllowedError:
+		if property, ok := resErr.Details()["property"].(string); ok {
+			pointer.WriteString("/" + escapePointerToken(property))
+		}
+	}
+	return pointer.String()
+}
+
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
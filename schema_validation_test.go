@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// mustCompileSchema, test şemasını derler.
+func mustCompileSchema(t *testing.T, schema string) *gojsonschema.Schema {
+	t.Helper()
+	compiled, err := compileAgentSchema(models.AgentDefinition{Name: "test", Schema: json.RawMessage(schema)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
+
+func violationPointers(violations []models.SchemaViolation) []string {
+	pointers := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		pointers = append(pointers, violation.Pointer)
+	}
+	slices.Sort(pointers)
+	return pointers
+}
+
+func TestValidateArguments(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"channel": {"type": "string"},
+			"count": {"type": "integer", "minimum": 1},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["channel"],
+		"additionalProperties": false
+	}`
+
+	tests := []struct {
+		name    string
+		schema  string
+		args    string
+		want    []string
+		wantErr bool
+	}{
+		{name: "geçerli argümanlar", schema: schema, args: `{"channel": "general", "count": 2}`, want: []string{}},
+		{name: "şemasız agent doğrulanmaz", args: `{"anything": true}`, want: []string{}},
+		{name: "eksik zorunlu alan", schema: schema, args: `{}`, want: []string{"/channel"}},
+		{name: "izin verilmeyen alan", schema: schema, args: `{"channel": "general", "extra": 1}`, want: []string{"/extra"}},
+		{name: "yanlış tip ve sınır", schema: schema, args: `{"channel": 5, "count": 0}`, want: []string{"/channel", "/count"}},
+		{name: "dizi elemanı", schema: schema, args: `{"channel": "general", "tags": ["ok", 3]}`, want: []string{"/tags/1"}},
+		{name: "argümansız istek null sayılır", schema: schema, args: ``, want: []string{""}},
+		{name: "bozuk JSON", schema: schema, args: `{"channel":`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var compiled *gojsonschema.Schema
+			if tt.schema != "" {
+				compiled = mustCompileSchema(t, tt.schema)
+			}
+
+			violations, err := validateArguments(compiled, json.RawMessage(tt.args))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("hata bekleniyordu")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := violationPointers(violations); !slices.Equal(got, tt.want) {
+				t.Errorf("pointer'lar %q, beklenen %q", got, tt.want)
+			}
+			for _, violation := range violations {
+				if violation.Message == "" {
+					t.Errorf("%s ihlalinin mesajı boş", violation.Pointer)
+				}
+			}
+		})
+	}
+}
+
+func TestViolationPointer(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		args   string
+		want   string
+	}{
+		{
+			name:   "iç içe alan",
+			schema: `{"properties": {"a": {"properties": {"b": {"type": "string"}}}}}`,
+			args:   `{"a": {"b": 1}}`,
+			want:   "/a/b",
+		},
+		{
+			name:   "alan adındaki / ~1 olur",
+			schema: `{"properties": {"a/b": {"type": "string"}}}`,
+			args:   `{"a/b": 1}`,
+			want:   "/a~1b",
+		},
+		{
+			name:   "alan adındaki ~ ~0 olur",
+			schema: `{"properties": {"m~n": {"properties": {"x": {"type": "string"}}}}}`,
+			args:   `{"m~n": {"x": 1}}`,
+			want:   "/m~0n/x",
+		},
+		{
+			name:   "eksik alanın adı kaçışlanır",
+			schema: `{"properties": {"p.q": {"required": ["~/r"]}}}`,
+			args:   `{"p.q": {}}`,
+			want:   "/p.q/~0~1r",
+		},
+		{
+			name:   "izin verilmeyen alanın adı kaçışlanır",
+			schema: `{"additionalProperties": false}`,
+			args:   `{"x/y": 1}`,
+			want:   "/x~1y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := mustCompileSchema(t, tt.schema).Validate(gojsonschema.NewStringLoader(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Errors()) != 1 {
+				t.Fatalf("tek ihlal bekleniyordu, gelen: %v", result.Errors())
+			}
+			if got := violationPointer(result.Errors()[0]); got != tt.want {
+				t.Errorf("%q, beklenen %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// agentStatusResult, agent'ın durum endpoint'inden okunmuş ve task_id'si Orchestrator kimliğine çevrilmiş cevaptır.
+type agentStatusResult struct {
+	StatusCode int
+	Body       []byte
+	RetryAfter string
+}
+
+type statusCacheEntry struct {
+	// Agent çağrısı bitince kapanır, o ana kadar gelen sorgular aynı sonucu bekler
+	done   chan struct{}
+	result agentStatusResult
+	err    error
+}
+
+// StatusCache, devam eden görevler için agent'ın durum cevabını kısa bir süre saklar. Aynı göreve
+// eşzamanlı gelen sorgular tek bir agent çağrısında birleştirilir, ttl süresince gelenler de bu cevabı alır.
+type StatusCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*statusCacheEntry
+}
+
+func NewStatusCache(ttl time.Duration) *StatusCache {
+	return &StatusCache{
+		ttl:     ttl,
+		entries: make(map[string]*statusCacheEntry),
+	}
+}
+
+// Do, görevin saklanan ya da devam eden çağrısının sonucunu döndürür; yoksa fetch'i çalıştırır.
+// Hatalar saklanmaz, yalnızca o anda bekleyen sorgularla paylaşılır.
+func (c *StatusCache) Do(ctx context.Context, taskID string, fetch func() (agentStatusResult, error)) (agentStatusResult, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[taskID]; ok {
+		c.mu.Unlock()
+		select {
+		case <-entry.done:
+			return entry.result, entry.err
+		case <-ctx.Done():
+			return agentStatusResult{}, ctx.Err()
+		}
+	}
+
+	entry := &statusCacheEntry{done: make(chan struct{})}
+	c.entries[taskID] = entry
+	c.mu.Unlock()
+
+	entry.result, entry.err = fetch()
+	close(entry.done)
+
+	remove := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.entries[taskID] == entry {
+			delete(c.entries, taskID)
+		}
+	}
+	if entry.err == nil && c.ttl > 0 {
+		time.AfterFunc(c.ttl, remove)
+	} else {
+		remove()
+	}
+	return entry.result, entry.err
+}
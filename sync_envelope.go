@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Zarflanan senkron cevapların gövdesinin okunmasında uygulanan üst sınır
+const maxSyncBodySize = 10 << 20
+
+// writeSyncEnvelope, agent'ın senkron cevabını bir TaskStatusResponse ile sarar ve görevi deftere biten görev
+// olarak yazar. 2xx cevaplar "completed" ve 200 olarak, diğerleri agent'ın durum koduyla "failed" olarak döner.
+func (o *Orchestrator) writeSyncEnvelope(w http.ResponseWriter, taskID string, agent models.AgentDefinition, replica, caller string, agentResp *http.Response) {
+	status, httpStatus, err := syncEnvelopeStatus(taskID, agentResp)
+	if err != nil {
+		log.Printf("Hata: Agent '%s' cevabı okunamadı: %v", agent.Name, err)
+		o.writeCallErrorEnvelope(w, taskID, agent, replica, caller, err)
+		return
+	}
+
+	o.recordSyncTask(agent, replica, caller, status)
+	writeEnvelope(w, httpStatus, status)
+}
+
+// syncEnvelopeStatus, agent'ın senkron cevabını biten bir görev durumuna çevirir ve istemciye dönülecek HTTP kodunu verir.
+func syncEnvelopeStatus(taskID string, agentResp *http.Response) (models.TaskStatusResponse, int, error) {
+	body, err := io.ReadAll(io.LimitReader(agentResp.Body, maxSyncBodySize+1))
+	if err != nil {
+		return models.TaskStatusResponse{}, 0, err
+	}
+
+	status := models.TaskStatusResponse{TaskID: taskID}
+	// Kesilmiş bir cevap başarılı sonuç gibi saklanmasın diye sınırı aşan cevaplar başarısız sayılır
+	if len(body) > maxSyncBodySize {
+		status.Status = models.StatusFailed
+		status.Error = "Agent response exceeds the size limit"
+		status.ErrorDetails = &models.TaskErrorDetails{
+			Code:       models.ErrorCodeResponseTooLarge,
+			HTTPStatus: http.StatusBadGateway,
+		}
+		return status, http.StatusBadGateway, nil
+	}
+
+	if agentResp.StatusCode >= 200 && agentResp.StatusCode <= 299 {
+		status.Status = models.StatusCompleted
+		status.Result = jsonOrString(body)
+		return status, http.StatusOK, nil
+	}
+
+	status.Status = models.StatusFailed
+	status.Error = "Agent returned " + agentResp.Status
+	status.ErrorDetails = &models.TaskErrorDetails{
+		Code:       models.ErrorCodeAgentError,
+		HTTPStatus: agentResp.StatusCode,
+		AgentBody:  jsonOrString(body),
+	}
+	return status, agentResp.StatusCode, nil
+}
+
+// writeCallErrorEnvelope, agent'a ulaşılamadığında writeAgentCallError'ın zarflı karşılığıdır.
+// Durum kodları ve Retry-After başlığı zarfsız cevaplarla aynıdır.
+func (o *Orchestrator) writeCallErrorEnvelope(w http.ResponseWriter, taskID string, agent models.AgentDefinition, replica, caller string, err error) {
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		setRetryAfter(w.Header(), o.Breakers.RetryAfter(agent, replica))
+	case errors.Is(err, ErrAgentQueueFull), errors.Is(err, ErrAgentQueueTimeout):
+		setRetryAfter(w.Header(), o.Limiter.RetryAfter(agent.Name))
+	}
+
+	status := callErrorStatus(taskID, err)
+	o.recordSyncTask(agent, replica, caller, status)
+	writeEnvelope(w, status.ErrorDetails.HTTPStatus, status)
+}
+
+// callErrorStatus, agent çağrısının hatasını başarısız bir görev durumuna çevirir.
+func callErrorStatus(taskID string, err error) models.TaskStatusResponse {
+	status := models.TaskStatusResponse{
+		TaskID:       taskID,
+		Status:       models.StatusFailed,
+		ErrorDetails: &models.TaskErrorDetails{},
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		status.Error = "Agent timed out"
+		status.ErrorDetails.Code = models.ErrorCodeAgentTimeout
+		status.ErrorDetails.HTTPStatus = http.StatusGatewayTimeout
+	case errors.Is(err, ErrCircuitOpen):
+		status.Error = "Agent temporarily unavailable (circuit open)"
+		status.ErrorDetails.Code = models.ErrorCodeAgentUnavailable
+		status.ErrorDetails.HTTPStatus = http.StatusServiceUnavailable
+	case errors.Is(err, ErrAgentQueueFull), errors.Is(err, ErrAgentQueueTimeout):
+		status.Error = "Agent is busy, try again later"
+		status.ErrorDetails.Code = models.ErrorCodeAgentBusy
+		status.ErrorDetails.HTTPStatus = http.StatusTooManyRequests
+	default:
+		status.Error = "Failed to call agent service"
+		status.ErrorDetails.Code = models.ErrorCodeAgentUnavailable
+		status.ErrorDetails.HTTPStatus = http.StatusServiceUnavailable
+	}
+	return status
+}
+
+// ---------------------- HELPERS ----------------------
+
+// recordSyncTask, zarflanan çağrıyı görev listesinde ve task_status'ta görünmesi için deftere yazar.
+// Kayıt hatası istemciye dönülmez, çünkü agent çağrısı zaten tamamlanmıştır.
+func (o *Orchestrator) recordSyncTask(agent models.AgentDefinition, replica, caller string, status models.TaskStatusResponse) {
+	if err := o.TaskRegistry.RegisterSyncTask(agent, replica, caller, status); err != nil {
+		log.Printf("Hata: Senkron görev deftere yazılamadı (TaskID %s): %v", status.TaskID, err)
+	}
+}
+
+func writeEnvelope(w http.ResponseWriter, httpStatus int, status models.TaskStatusResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(status)
+}
+
+// jsonOrString, gövde geçerli JSON ise olduğu gibi, değilse JSON metni olarak döndürür. Boş gövde için nil döner.
+func jsonOrString(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return body
+	}
+	encoded, _ := json.Marshal(string(body))
+	return encoded
+}
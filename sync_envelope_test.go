@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+func TestSyncEnvelopeStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		agentStatus    int
+		body           []byte
+		wantHTTPStatus int
+		wantStatus     models.TaskStatus
+		wantResult     string
+		wantCode       string
+	}{
+		{"200 JSON", http.StatusOK, []byte(`{"ok":true}`), http.StatusOK, models.StatusCompleted, `{"ok":true}`, ""},
+		{"201 de başarılı", http.StatusCreated, []byte(`[1,2]`), http.StatusOK, models.StatusCompleted, `[1,2]`, ""},
+		{"JSON olmayan gövde metin olur", http.StatusOK, []byte(`merhaba`), http.StatusOK, models.StatusCompleted, `"merhaba"`, ""},
+		{"boş gövde", http.StatusNoContent, nil, http.StatusOK, models.StatusCompleted, ``, ""},
+		{"agent hatası kendi koduyla döner", http.StatusBadRequest, []byte(`{"error":"bad"}`), http.StatusBadRequest, models.StatusFailed, ``, models.ErrorCodeAgentError},
+		{"agent'ın 500'ü", http.StatusInternalServerError, []byte(`boom`), http.StatusInternalServerError, models.StatusFailed, ``, models.ErrorCodeAgentError},
+		{"sınırdaki gövde kabul edilir", http.StatusOK, bytes.Repeat([]byte("a"), maxSyncBodySize), http.StatusOK, models.StatusCompleted, "", ""},
+		{"sınırı aşan gövde 502", http.StatusOK, bytes.Repeat([]byte("a"), maxSyncBodySize+1), http.StatusBadGateway, models.StatusFailed, ``, models.ErrorCodeResponseTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.agentStatus,
+				Status:     fmt.Sprintf("%d %s", tt.agentStatus, http.StatusText(tt.agentStatus)),
+				Body:       io.NopCloser(bytes.NewReader(tt.body)),
+			}
+			status, httpStatus, err := syncEnvelopeStatus("task-1", resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if httpStatus != tt.wantHTTPStatus || status.Status != tt.wantStatus || status.TaskID != "task-1" {
+				t.Errorf("%d %s %s, beklenen %d %s task-1", httpStatus, status.Status, status.TaskID, tt.wantHTTPStatus, tt.wantStatus)
+			}
+			if tt.wantResult != "" && string(status.Result) != tt.wantResult {
+				t.Errorf("result %s, beklenen %s", status.Result, tt.wantResult)
+			}
+			if tt.wantCode == "" {
+				if status.ErrorDetails != nil {
+					t.Errorf("beklenmeyen error_details: %+v", status.ErrorDetails)
+				}
+				return
+			}
+			if status.ErrorDetails == nil || status.ErrorDetails.Code != tt.wantCode || status.ErrorDetails.HTTPStatus != tt.wantHTTPStatus {
+				t.Fatalf("error_details %+v, beklenen %s %d", status.ErrorDetails, tt.wantCode, tt.wantHTTPStatus)
+			}
+			if status.Result != nil || status.Error == "" {
+				t.Errorf("başarısız görevde result %s, error %q", status.Result, status.Error)
+			}
+			if tt.wantCode == models.ErrorCodeAgentError && !bytes.Equal(status.ErrorDetails.AgentBody, jsonOrString(tt.body)) {
+				t.Errorf("agent_body %s, beklenen %s", status.ErrorDetails.AgentBody, jsonOrString(tt.body))
+			}
+		})
+	}
+}
+
+func TestCallErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantCode       string
+		wantHTTPStatus int
+	}{
+		{"zaman aşımı", fmt.Errorf("post: %w", context.DeadlineExceeded), models.ErrorCodeAgentTimeout, http.StatusGatewayTimeout},
+		{"breaker açık", ErrCircuitOpen, models.ErrorCodeAgentUnavailable, http.StatusServiceUnavailable},
+		{"kuyruk dolu", ErrAgentQueueFull, models.ErrorCodeAgentBusy, http.StatusTooManyRequests},
+		{"kuyruk bekleme süresi doldu", ErrAgentQueueTimeout, models.ErrorCodeAgentBusy, http.StatusTooManyRequests},
+		{"bağlantı hatası", errors.New("connection refused"), models.ErrorCodeAgentUnavailable, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := callErrorStatus("task-1", tt.err)
+			if status.TaskID != "task-1" || status.Status != models.StatusFailed || status.Error == "" {
+				t.Errorf("durum %+v", status)
+			}
+			if status.ErrorDetails.Code != tt.wantCode || status.ErrorDetails.HTTPStatus != tt.wantHTTPStatus {
+				t.Errorf("%s %d, beklenen %s %d", status.ErrorDetails.Code, status.ErrorDetails.HTTPStatus, tt.wantCode, tt.wantHTTPStatus)
+			}
+		})
+	}
+}
+
+// Zarf agent'ta açılabilir, istekteki envelope alanı agent'ın ayarını her iki yönde ezer
+func TestHandleTaskEnvelopeSelection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"answer":42}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		agentEnvelope bool
+		request       string
+		wantEnvelope  bool
+	}{
+		{"varsayılan zarfsız", false, `{"agent_name": "echo"}`, false},
+		{"agent'ta açık", true, `{"agent_name": "echo"}`, true},
+		{"istek açar", false, `{"agent_name": "echo", "envelope": true}`, true},
+		{"istek kapatır", true, `{"agent_name": "echo", "envelope": false}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newTestOrchestrator(t, models.AgentDefinition{Name: "echo", Endpoint: server.URL, SyncEnvelope: tt.agentEnvelope})
+
+			recorder := httptest.NewRecorder()
+			o.HandleTask(recorder, httptest.NewRequest("POST", "/api/v1/run_task", strings.NewReader(tt.request)))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("%d, beklenen %d", recorder.Code, http.StatusOK)
+			}
+
+			if !tt.wantEnvelope {
+				if got := strings.TrimSpace(recorder.Body.String()); got != `{"answer":42}` {
+					t.Errorf("gövde %s, agent'ın cevabı olduğu gibi bekleniyordu", got)
+				}
+				if o.TaskRegistry.store.Len() != 0 {
+					t.Error("zarfsız senkron çağrı deftere yazıldı")
+				}
+				return
+			}
+
+			var status models.TaskStatusResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Status != models.StatusCompleted || string(status.Result) != `{"answer":42}` || status.TaskID == "" {
+				t.Errorf("zarf %+v", status)
+			}
+			// Zarflanan çağrı task_status'ta sorgulanabilsin diye deftere yazılır
+			info, ok := o.TaskRegistry.GetTaskInfo(status.TaskID)
+			if !ok || !info.Sync || info.LastStatus.Status != models.StatusCompleted {
+				t.Errorf("defterdeki kayıt %+v", info)
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// waitForTask'ın her bekleme turunun süresi
+const taskFinishWaitInterval = 30 * time.Second
+
+// Agent'ın görevi art arda kaç kez tanımaması (404) durumunda görevin kaybolduğu kabul edilir. Tek bir 404,
+// agent'ın yeniden başlaması ya da replikalar arası gecikme gibi geçici nedenlerle de gelebilir.
+const taskLostThreshold = 3
+
+// errTaskMissingOnAgent, agent'ın durum endpoint'inin görevi tanımadığını (404) bildirir
+var errTaskMissingOnAgent = errors.New("görev agent'ta bulunamadı")
+
+// SSE bağlantısının proxy'ler tarafından kapatılmaması için gönderilen boş yorum satırlarının aralığı
+const sseKeepAliveInterval = 15 * time.Second
+
+// TaskEvent, bir görevin abonelerine iletilen tek bir güncellemedir. Type, durum değiştiyse "status",
+// yalnızca result ya da progress değiştiyse "progress" olur.
+type TaskEvent struct {
+	Type   string
+	Status models.TaskStatusResponse
+}
+
+// taskWatch, tek bir görevin abonelerini ve bilinen son durumunu tutar.
+type taskWatch struct {
+	subscribers map[chan TaskEvent]struct{}
+	last        *models.TaskStatusResponse
+	// Abone kalmadığında agent'ı yoklayan goroutine'i durdurur
+	stopPoller context.CancelFunc
+}
+
+// TaskEventHub, görev durum değişikliklerini abonelere dağıtır. Bir göreve kaç abone olursa olsun
+// agent'ı tek bir poller yoklar; agent callback gönderiyorsa güncellemeler doğrudan Publish ile gelir.
+type TaskEventHub struct {
+	mu      sync.Mutex
+	watches map[string]*taskWatch
+	// Yalnızca görevin bitişini bekleyenler; bunlar için agent yoklanmaz
+	finishWaiters map[string]map[chan struct{}]struct{}
+
+	poll func(ctx context.Context, taskID string) (models.TaskStatusResponse, error)
+	// poll art arda taskLostThreshold kez errTaskMissingOnAgent döndüğünde görevin son durumunu kaydeder
+	lost         func(taskID string) models.TaskStatusResponse
+	pollInterval time.Duration
+}
+
+func NewTaskEventHub(poll func(ctx context.Context, taskID string) (models.TaskStatusResponse, error), lost func(taskID string) models.TaskStatusResponse, pollInterval time.Duration) *TaskEventHub {
+	return &TaskEventHub{
+		watches:       make(map[string]*taskWatch),
+		finishWaiters: make(map[string]map[chan struct{}]struct{}),
+		poll:          poll,
+		lost:          lost,
+		pollInterval:  pollInterval,
+	}
+}
+
+// Subscribe, göreve abone olur. Bilinen son durum varsa kanala hemen yazılır. Kanal her zaman en güncel
+// olayı taşır, yavaş okuyan abone ara olayları kaçırabilir ama son durumu kaçırmaz. Görev bittiğinde
+// kanal kapanır. Dönen unsubscribe fonksiyonu abonelik bitince çağrılmalıdır.
+func (h *TaskEventHub) Subscribe(taskID string) (<-chan TaskEvent, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan TaskEvent, 1)
+
+	watch, ok := h.watches[taskID]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		watch = &taskWatch{
+			subscribers: make(map[chan TaskEvent]struct{}),
+			stopPoller:  cancel,
+		}
+		h.watches[taskID] = watch
+		go h.runPoller(ctx, taskID)
+	}
+	watch.subscribers[ch] = struct{}{}
+
+	if watch.last != nil {
+		offerEvent(ch, TaskEvent{Type: "status", Status: *watch.last})
+	}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// Görev bitmişse watch zaten kaldırılmış ve kanal kapatılmıştır
+		if h.watches[taskID] != watch {
+			return
+		}
+		if _, ok := watch.subscribers[ch]; !ok {
+			return
+		}
+		delete(watch.subscribers, ch)
+		close(ch)
+		if len(watch.subscribers) == 0 {
+			watch.stopPoller()
+			delete(h.watches, taskID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// NotifyFinished, görevin bittiği Publish ile bildirildiğinde kapanan bir kanal döndürür. Subscribe'dan farklı
+// olarak poller başlatmaz; görevin bitişini callback'ler ve diğer abonelerin yoklamaları bildirir.
+// Dönen stop fonksiyonu bekleme bitince çağrılmalıdır.
+func (h *TaskEventHub) NotifyFinished(taskID string) (<-chan struct{}, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan struct{})
+	if h.finishWaiters[taskID] == nil {
+		h.finishWaiters[taskID] = make(map[chan struct{}]struct{})
+	}
+	h.finishWaiters[taskID][ch] = struct{}{}
+
+	stop := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		waiters, ok := h.finishWaiters[taskID]
+		if !ok {
+			return
+		}
+		delete(waiters, ch)
+		if len(waiters) == 0 {
+			delete(h.finishWaiters, taskID)
+		}
+	}
+	return ch, stop
+}
+
+// Publish, görevin yeni durumunu abonelere iletir. Durum öncekiyle aynıysa olay üretilmez.
+// Görev bittiyse abonelerin kanalları kapatılır ve poller durdurulur.
+func (h *TaskEventHub) Publish(taskID string, status models.TaskStatusResponse) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if status.Status.Terminal() {
+		for ch := range h.finishWaiters[taskID] {
+			close(ch)
+		}
+		delete(h.finishWaiters, taskID)
+	}
+
+	watch, ok := h.watches[taskID]
+	if !ok {
+		return
+	}
+
+	eventType := "status"
+	if watch.last != nil {
+		if sameTaskStatus(*watch.last, status) {
+			return
+		}
+		if watch.last.Status == status.Status {
+			eventType = "progress"
+		}
+	}
+	watch.last = &status
+
+	for ch := range watch.subscribers {
+		offerEvent(ch, TaskEvent{Type: eventType, Status: status})
+	}
+
+	if status.Status.Terminal() {
+		for ch := range watch.subscribers {
+			close(ch)
+		}
+		watch.stopPoller()
+		delete(h.watches, taskID)
+	}
+}
+
+// WaitForChange, görevin bilinen durumu değişene, görev bitene ya da wait süresi dolana kadar bekler.
+// Bilinen durum, aboneliğin ilk olayıdır (hub'da yoksa poller'ın ilk yoklaması). Süre dolarsa
+// bilinen son durum döner; hiç durum öğrenilemediyse ok false olur.
+func (h *TaskEventHub) WaitForChange(ctx context.Context, taskID string, wait time.Duration) (models.TaskStatusResponse, bool) {
+	events, unsubscribe := h.Subscribe(taskID)
+	defer unsubscribe()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	var current *models.TaskStatusResponse
+	for {
+		select {
+		case <-ctx.Done():
+			return models.TaskStatusResponse{}, false
+		case <-timer.C:
+			if current == nil {
+				return models.TaskStatusResponse{}, false
+			}
+			return *current, true
+		case event, ok := <-events:
+			if !ok {
+				if current == nil {
+					return models.TaskStatusResponse{}, false
+				}
+				return *current, true
+			}
+			if current != nil || event.Status.Status.Terminal() {
+				return event.Status, true
+			}
+			current = &event.Status
+		}
+	}
+}
+
+// HandleTaskEvents, /api/v1/tasks/{id}/events üzerinden görevin durum değişikliklerini Server-Sent Events
+// olarak yayınlar. Görev bittiğinde son olay gönderilir ve akış kapanır.
+func (o *Orchestrator) HandleTaskEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	taskID := r.PathValue("id")
+	taskInfo, ok := o.TaskRegistry.GetTaskInfo(taskID)
+	if !ok {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	if !principalFrom(r.Context()).AllowsAgent(taskInfo.AgentName) {
+		http.Error(w, "Agent not allowed for this credential", http.StatusForbidden)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// nginx gibi proxy'lerin akışı tamponlamaması için
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Görev zaten bitmişse agent'a sorulmadan son durum gönderilir
+	if status, ok := o.finishedStatus(taskInfo); ok {
+		writeSSEEvent(w, TaskEvent{Type: "status", Status: status})
+		flusher.Flush()
+		return
+	}
+
+	events, unsubscribe := o.Events.Subscribe(taskID)
+	defer unsubscribe()
+	log.Printf("SSE aboneliği açıldı: TaskID %s", taskID)
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("SSE aboneliği istemci tarafından kapatıldı: TaskID %s", taskID)
+			return
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				log.Printf("SSE akışı tamamlandı: TaskID %s", taskID)
+				return
+			}
+			writeSSEEvent(w, event)
+			flusher.Flush()
+		}
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// runPoller, görevin abonesi olduğu sürece agent'ı periyodik olarak yoklar. İlk yoklama beklemeden yapılır.
+// Agent görevi art arda taskLostThreshold kez tanımazsa görev kaybolmuş sayılır.
+func (h *TaskEventHub) runPoller(ctx context.Context, taskID string) {
+	ticker := time.NewTicker(h.pollInterval)
+	defer ticker.Stop()
+
+	misses := 0
+	for {
+		status, err := h.poll(ctx, taskID)
+		if errors.Is(err, errTaskMissingOnAgent) {
+			misses++
+		} else {
+			misses = 0
+		}
+
+		switch {
+		case err == nil:
+			h.Publish(taskID, status)
+		case misses >= taskLostThreshold:
+			h.Publish(taskID, h.lost(taskID))
+		case ctx.Err() == nil:
+			log.Printf("Uyarı: Görev durumu yoklanamadı (TaskID %s): %v", taskID, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollTaskStatus, görevin güncel durumunu agent'tan okur. Bittiği bilinen görevler için agent'a gidilmez.
+func (o *Orchestrator) pollTaskStatus(ctx context.Context, taskID string) (models.TaskStatusResponse, error) {
+	taskInfo, ok := o.TaskRegistry.GetTaskInfo(taskID)
+	if !ok {
+		return models.TaskStatusResponse{}, ErrTaskNotFound
+	}
+	if status, ok := o.finishedStatus(taskInfo); ok {
+		return status, nil
+	}
+	if status, ok := o.queuedStatus(taskInfo); ok {
+		return status, nil
+	}
+
+	result, err := o.fetchAgentStatus(ctx, taskInfo)
+	if err != nil {
+		return models.TaskStatusResponse{}, err
+	}
+	if result.StatusCode == http.StatusNotFound {
+		return models.TaskStatusResponse{}, errTaskMissingOnAgent
+	}
+	if result.StatusCode < 200 || result.StatusCode > 299 {
+		return models.TaskStatusResponse{}, fmt.Errorf("agent durum endpoint'i %d döndü", result.StatusCode)
+	}
+
+	var status models.TaskStatusResponse
+	if err := json.Unmarshal(result.Body, &status); err != nil {
+		return models.TaskStatusResponse{}, err
+	}
+	if !status.Status.Valid() {
+		return models.TaskStatusResponse{}, errors.New("agent geçersiz görev durumu döndü: " + string(status.Status))
+	}
+	// Agent cevabında task_id göndermese de olaylar Orchestrator kimliğini taşır.
+	// Bekleme önerisi olay değil, yalnızca durum sorgusu cevabının parçasıdır
+	status.TaskID = taskID
+	status.NextPollAfterMs = 0
+	return status, nil
+}
+
+// markTaskLost, agent'ın art arda tanımadığı (ör. agent yeniden başladığı için kaybolan) görevi başarısız olarak
+// kaydeder. Böylece görevi bekleyenler, yoklayıcı ve tuttuğu max_concurrency yeri serbest kalır. Yalnızca
+// abonesi olan görevlerin poller'ı çağırır; yer tutan görevlerin arka plan kontrolü görevi başarısız saymaz.
+func (o *Orchestrator) markTaskLost(taskID string) models.TaskStatusResponse {
+	status := models.TaskStatusResponse{
+		TaskID: taskID,
+		Status: models.StatusFailed,
+		Error:  "Task not found on agent",
+	}
+	if err := o.TaskRegistry.UpdateStatus(taskID, status); err != nil && !errors.Is(err, ErrTaskFinished) {
+		log.Printf("Uyarı: Kaybolan görev kaydedilemedi (TaskID %s): %v", taskID, err)
+	}
+	log.Printf("Uyarı: Agent görevi tanımıyor, görev başarısız sayıldı: TaskID %s", taskID)
+	return status
+}
+
+// waitForTask, asenkron görev bitene kadar hub üzerinden bekler. Görev defterden silinirse ErrTaskNotFound döner.
+func (o *Orchestrator) waitForTask(ctx context.Context, taskID string) (models.TaskStatusResponse, error) {
+	for {
+		info, ok := o.TaskRegistry.GetTaskInfo(taskID)
+		if !ok {
+			return models.TaskStatusResponse{}, ErrTaskNotFound
+		}
+		// Sonuç TASK_RESULT_TTL nedeniyle artık sunulmasa da görevin bittiği bilinir
+		if isTerminalTask(info) {
+			return *info.LastStatus, nil
+		}
+
+		status, ok := o.Events.WaitForChange(ctx, taskID, taskFinishWaitInterval)
+		if ctx.Err() != nil {
+			return models.TaskStatusResponse{}, ctx.Err()
+		}
+		if ok && status.Status.Terminal() {
+			return status, nil
+		}
+	}
+}
+
+// offerEvent, kanaldaki okunmamış olayı yenisiyle değiştirir; böylece yayıncı yavaş aboneyi beklemez.
+// Kanala yalnızca hub kilidi altında yazıldığı için döngü en fazla iki turda biter.
+func offerEvent(ch chan TaskEvent, event TaskEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func sameTaskStatus(a, b models.TaskStatusResponse) bool {
+	left, errLeft := json.Marshal(a)
+	right, errRight := json.Marshal(b)
+	return errLeft == nil && errRight == nil && bytes.Equal(left, right)
+}
+
+func writeSSEEvent(w io.Writer, event TaskEvent) {
+	data, _ := json.Marshal(event.Status)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
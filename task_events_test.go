@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Testlerde hub'ın agent'ı yoklama aralığı
+const testPollInterval = 10 * time.Millisecond
+
+// fakePoll, sırayla verilen sonuçları dönen bir yoklama fonksiyonudur; sonuçlar bitince sonuncuyu tekrarlar.
+// Boş durumlu sonuç, yoklama hatası anlamına gelir.
+type fakePoll struct {
+	mu      sync.Mutex
+	results []pollResult
+	calls   int
+	lost    int
+}
+
+type pollResult struct {
+	status models.TaskStatus
+	err    error
+}
+
+func (p *fakePoll) poll(ctx context.Context, taskID string) (models.TaskStatusResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := p.results[min(p.calls, len(p.results)-1)]
+	p.calls++
+	if result.err != nil {
+		return models.TaskStatusResponse{}, result.err
+	}
+	return models.TaskStatusResponse{TaskID: taskID, Status: result.status}, nil
+}
+
+func (p *fakePoll) markLost(taskID string) models.TaskStatusResponse {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lost++
+	return models.TaskStatusResponse{TaskID: taskID, Status: models.StatusFailed, Error: "Task not found on agent"}
+}
+
+func (p *fakePoll) counts() (calls, lost int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls, p.lost
+}
+
+func newTestHub(results ...pollResult) (*TaskEventHub, *fakePoll) {
+	p := &fakePoll{results: results}
+	return NewTaskEventHub(p.poll, p.markLost, testPollInterval), p
+}
+
+// collectEvents, kanal kapanana ya da süre dolana kadar gelen olayların durumlarını toplar.
+func collectEvents(t *testing.T, events <-chan TaskEvent, timeout time.Duration) ([]models.TaskStatus, bool) {
+	t.Helper()
+	var statuses []models.TaskStatus
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return statuses, true
+			}
+			statuses = append(statuses, event.Status.Status)
+		case <-deadline:
+			return statuses, false
+		}
+	}
+}
+
+// Bir göreve kaç abone olursa olsun agent'ı tek bir poller yoklar
+func TestTaskEventHubSinglePoller(t *testing.T) {
+	tests := []struct {
+		name        string
+		subscribers int
+	}{
+		{"tek abone", 1},
+		{"üç abone", 3},
+		{"yirmi abone", 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, p := newTestHub(pollResult{status: models.StatusRunning})
+
+			var unsubscribes []func()
+			for range tt.subscribers {
+				events, unsubscribe := hub.Subscribe("task-1")
+				unsubscribes = append(unsubscribes, unsubscribe)
+				if _, ok := <-events; !ok {
+					t.Fatal("abone ilk durumu almadı")
+				}
+			}
+
+			time.Sleep(5 * testPollInterval)
+			calls, _ := p.counts()
+			// Tek poller 50ms'de en fazla 6-7 kez yoklar; abone başına poller olsaydı çok daha fazla olurdu
+			if calls > 8 {
+				t.Errorf("%d abone için agent %d kez yoklandı", tt.subscribers, calls)
+			}
+
+			// Son abone ayrılınca poller durur
+			for _, unsubscribe := range unsubscribes {
+				unsubscribe()
+			}
+			time.Sleep(2 * testPollInterval)
+			stopped, _ := p.counts()
+			time.Sleep(3 * testPollInterval)
+			if after, _ := p.counts(); after != stopped {
+				t.Errorf("aboneler ayrıldıktan sonra %d yoklama daha yapıldı", after-stopped)
+			}
+		})
+	}
+}
+
+func TestTaskEventHubTerminal(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []pollResult
+		// Görevin bitişi poller yerine callback'le (Publish) bildirilir
+		publish models.TaskStatus
+		want    []models.TaskStatus
+	}{
+		{
+			name:    "yoklama görevin bittiğini görür",
+			results: []pollResult{{status: models.StatusRunning}, {status: models.StatusCompleted}},
+			want:    []models.TaskStatus{models.StatusRunning, models.StatusCompleted},
+		},
+		{
+			name:    "başarısız görev de akışı kapatır",
+			results: []pollResult{{status: models.StatusPending}, {status: models.StatusFailed}},
+			want:    []models.TaskStatus{models.StatusPending, models.StatusFailed},
+		},
+		{
+			name:    "callback görevi bitirir",
+			results: []pollResult{{status: models.StatusRunning}},
+			publish: models.StatusCompleted,
+			want:    []models.TaskStatus{models.StatusRunning, models.StatusCompleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, p := newTestHub(tt.results...)
+			events, unsubscribe := hub.Subscribe("task-1")
+			defer unsubscribe()
+
+			if tt.publish != "" {
+				// Poller'ın ilk yoklaması beklenir, böylece olaylar sırayla gelir
+				if event := <-events; event.Status.Status != tt.want[0] {
+					t.Fatalf("ilk olay %s, beklenen %s", event.Status.Status, tt.want[0])
+				}
+				hub.Publish("task-1", models.TaskStatusResponse{TaskID: "task-1", Status: tt.publish})
+				tt.want = tt.want[1:]
+			}
+
+			got, closed := collectEvents(t, events, time.Second)
+			if !closed {
+				t.Fatal("görev bittiği halde kanal kapanmadı")
+			}
+			if strings.Join(statusNames(got), ",") != strings.Join(statusNames(tt.want), ",") {
+				t.Errorf("olaylar %v, beklenen %v", got, tt.want)
+			}
+
+			// Görev bitince poller durur
+			stopped, _ := p.counts()
+			time.Sleep(3 * testPollInterval)
+			if after, _ := p.counts(); after != stopped {
+				t.Errorf("görev bittikten sonra %d yoklama daha yapıldı", after-stopped)
+			}
+		})
+	}
+}
+
+func TestTaskEventHubLost(t *testing.T) {
+	missing := pollResult{err: errTaskMissingOnAgent}
+	running := pollResult{status: models.StatusRunning}
+	failing := pollResult{err: errors.New("connection refused")}
+
+	tests := []struct {
+		name     string
+		results  []pollResult
+		wantLost bool
+	}{
+		{"art arda eşik kadar 404", []pollResult{missing, missing, missing}, true},
+		{"önce çalışıyor sonra kayboldu", []pollResult{running, missing, missing, missing}, true},
+		{"araya giren cevap sayacı sıfırlar", []pollResult{missing, missing, running, missing, missing, running}, false},
+		{"bağlantı hataları kayıp sayılmaz", []pollResult{failing}, false},
+		{"bağlantı hatası sayacı sıfırlar", []pollResult{missing, missing, failing, missing, missing, failing}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, p := newTestHub(tt.results...)
+			events, unsubscribe := hub.Subscribe("task-1")
+			defer unsubscribe()
+
+			got, closed := collectEvents(t, events, time.Duration(taskLostThreshold+len(tt.results)+3)*testPollInterval)
+			_, lost := p.counts()
+			if tt.wantLost != (lost == 1) || closed != tt.wantLost {
+				t.Fatalf("kayıp sayıldı: %d kez, akış kapandı: %t; beklenen %t", lost, closed, tt.wantLost)
+			}
+			if tt.wantLost && got[len(got)-1] != models.StatusFailed {
+				t.Errorf("son olay %s, beklenen failed", got[len(got)-1])
+			}
+		})
+	}
+}
+
+func TestWaitForChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []pollResult
+		// Bekleme sırasında Publish ile gelen durum
+		publish models.TaskStatus
+		wait    time.Duration
+		want    models.TaskStatus
+		wantOK  bool
+		// Beklemenin süreyi doldurması bekleniyor mu
+		wantTimeout bool
+	}{
+		{
+			name:    "yoklamayla gelen değişiklik",
+			results: []pollResult{{status: models.StatusPending}, {status: models.StatusRunning}},
+			wait:    time.Second, want: models.StatusRunning, wantOK: true,
+		},
+		{
+			name:    "callback'le gelen değişiklik",
+			results: []pollResult{{status: models.StatusRunning}},
+			publish: models.StatusCompleted,
+			wait:    time.Second, want: models.StatusCompleted, wantOK: true,
+		},
+		{
+			name:    "bitmiş görev beklemeden döner",
+			results: []pollResult{{status: models.StatusCompleted}},
+			wait:    time.Second, want: models.StatusCompleted, wantOK: true,
+		},
+		{
+			name:    "süre dolunca bilinen durum döner",
+			results: []pollResult{{status: models.StatusRunning}},
+			wait:    5 * testPollInterval, want: models.StatusRunning, wantOK: true, wantTimeout: true,
+		},
+		{
+			name:    "hiç durum öğrenilemezse ok false",
+			results: []pollResult{{err: errors.New("connection refused")}},
+			wait:    5 * testPollInterval, wantOK: false, wantTimeout: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, _ := newTestHub(tt.results...)
+			if tt.publish != "" {
+				go func() {
+					time.Sleep(3 * testPollInterval)
+					hub.Publish("task-1", models.TaskStatusResponse{TaskID: "task-1", Status: tt.publish})
+				}()
+			}
+
+			started := time.Now()
+			status, ok := hub.WaitForChange(context.Background(), "task-1", tt.wait)
+			elapsed := time.Since(started)
+
+			if ok != tt.wantOK || (ok && status.Status != tt.want) {
+				t.Errorf("%s %t, beklenen %s %t", status.Status, ok, tt.want, tt.wantOK)
+			}
+			if timedOut := elapsed >= tt.wait; timedOut != tt.wantTimeout {
+				t.Errorf("%s beklendi, süre %s", elapsed, tt.wait)
+			}
+		})
+	}
+}
+
+func TestWaitForChangeCanceled(t *testing.T) {
+	hub, _ := newTestHub(pollResult{status: models.StatusRunning})
+	ctx, cancel := context.WithTimeout(context.Background(), 3*testPollInterval)
+	defer cancel()
+
+	if _, ok := hub.WaitForChange(ctx, "task-1", time.Minute); ok {
+		t.Error("iptal edilen bekleme ok döndü")
+	}
+}
+
+// SSE akışı, agent'ın durum endpoint'i yoklanarak beslenir ve görev bitince kapanır
+func TestHandleTaskEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		bodies   []string
+		want     []string
+		// Akış bittikten sonra defterdeki durum
+		wantStatus models.TaskStatus
+	}{
+		{
+			name:       "görev tamamlanır",
+			statuses:   []int{200, 200, 200},
+			bodies:     []string{`{"status":"running"}`, `{"status":"running","progress":{"percent":50}}`, `{"status":"completed","result":{"ok":true}}`},
+			want:       []string{"status:running", "progress:running", "status:completed"},
+			wantStatus: models.StatusCompleted,
+		},
+		{
+			name:       "agent görevi tanımıyor",
+			statuses:   []int{200, 404},
+			bodies:     []string{`{"status":"running"}`, `{"error":"not found"}`},
+			want:       []string{"status:running", "status:failed"},
+			wantStatus: models.StatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				i := min(calls, len(tt.statuses)-1)
+				calls++
+				mu.Unlock()
+				w.WriteHeader(tt.statuses[i])
+				w.Write([]byte(tt.bodies[i]))
+			}))
+			defer server.Close()
+
+			agent := models.AgentDefinition{Name: "echo", Endpoint: server.URL, StatusEndpointPath: "/status/"}
+			o := newTestOrchestrator(t, agent)
+			o.StatusCache = NewStatusCache(0)
+			o.Events = NewTaskEventHub(o.pollTaskStatus, o.markTaskLost, testPollInterval)
+
+			taskID := NewTaskID()
+			if err := o.TaskRegistry.RegisterTask(taskID, models.TaskStartResponse{TaskID: "agent-1"}, agent, server.URL, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v1/tasks/{id}/events", o.HandleTaskEvents)
+			orchestrator := httptest.NewServer(mux)
+			defer orchestrator.Close()
+
+			client := &http.Client{Timeout: 5 * time.Second}
+			resp, err := client.Get(orchestrator.URL + "/api/v1/tasks/" + taskID + "/events")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+				t.Errorf("Content-Type %s", ct)
+			}
+
+			// Akış görev bitince sunucu tarafından kapanır, böylece gövde sonuna kadar okunabilir
+			var got []string
+			var eventType string
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				line := scanner.Text()
+				if value, ok := strings.CutPrefix(line, "event: "); ok {
+					eventType = value
+				}
+				if value, ok := strings.CutPrefix(line, "data: "); ok {
+					var status models.TaskStatusResponse
+					if err := json.Unmarshal([]byte(value), &status); err != nil {
+						t.Fatal(err)
+					}
+					if status.TaskID != taskID {
+						t.Errorf("olaydaki task_id %s, beklenen %s", status.TaskID, taskID)
+					}
+					got = append(got, eventType+":"+string(status.Status))
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("olaylar %v, beklenen %v", got, tt.want)
+			}
+			info, _ := o.TaskRegistry.GetTaskInfo(taskID)
+			if info.LastStatus == nil || info.LastStatus.Status != tt.wantStatus {
+				t.Errorf("defterdeki durum %+v, beklenen %s", info.LastStatus, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func statusNames(statuses []models.TaskStatus) []string {
+	names := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		names = append(names, string(status))
+	}
+	return names
+}
+
+// task_status?wait= durum değişince ya da süre dolunca cevap döner
+func TestHandleTaskStatusWait(t *testing.T) {
+	tests := []struct {
+		name   string
+		bodies []string
+		wait   time.Duration
+		want   models.TaskStatus
+		// Cevabın bekleme süresi dolduktan sonra gelmesi bekleniyor mu
+		wantTimeout bool
+	}{
+		{"durum değişince döner", []string{`{"status":"running"}`, `{"status":"running"}`, `{"status":"completed"}`}, time.Second, models.StatusCompleted, false},
+		{"süre dolunca son durum döner", []string{`{"status":"running"}`}, 5 * testPollInterval, models.StatusRunning, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				i := min(calls, len(tt.bodies)-1)
+				calls++
+				mu.Unlock()
+				w.Write([]byte(tt.bodies[i]))
+			}))
+			defer server.Close()
+
+			agent := models.AgentDefinition{Name: "echo", Endpoint: server.URL, StatusEndpointPath: "/status/"}
+			o := newTestOrchestrator(t, agent)
+			o.StatusCache = NewStatusCache(0)
+			o.Events = NewTaskEventHub(o.pollTaskStatus, o.markTaskLost, testPollInterval)
+
+			taskID := NewTaskID()
+			if err := o.TaskRegistry.RegisterTask(taskID, models.TaskStartResponse{TaskID: "agent-1"}, agent, server.URL, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			started := time.Now()
+			recorder := httptest.NewRecorder()
+			o.HandleTaskStatus(recorder, httptest.NewRequest("GET", TaskStatusPath+taskID+"?wait="+tt.wait.String(), nil))
+			elapsed := time.Since(started)
+
+			var status models.TaskStatusResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != http.StatusOK || status.Status != tt.want {
+				t.Errorf("%d %s, beklenen 200 %s", recorder.Code, status.Status, tt.want)
+			}
+			if timedOut := elapsed >= tt.wait; timedOut != tt.wantTimeout {
+				t.Errorf("cevap %s sonra geldi, bekleme süresi %s", elapsed, tt.wait)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"slices"
+	"time"
+)
+
+// Görev temizliği metrikleri /debug/vars altında "tasks" anahtarıyla yayınlanır
+var taskMetrics = expvar.NewMap("tasks")
+
+// Kayıt sırasında yapılan kapasite kontrollerinin en kısa aralığı
+const capacityCheckInterval = time.Second
+
+// TaskRetention, görevlerin defterde ne kadar tutulacağını belirler. Sıfır değerler sınır koymaz.
+type TaskRetention struct {
+	// Biten görevlerin son güncellemeden sonra tutulma süresi
+	TerminalTTL time.Duration
+	// Bitmemiş görevlerin son güncellemeden sonra tutulma süresi, agent'ı kaybolmuş görevleri temizler
+	ActiveTTL time.Duration
+	// Defterdeki görev sayısının üst sınırı; aşılınca en uzun süredir sorgulanmayan biten görevler silinir
+	MaxEntries int
+}
+
+// StartSweeper, süresi dolan görevleri arka planda periyodik olarak siler.
+func (r *TaskRegistry) StartSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.Sweep(time.Now()); err != nil {
+					log.Printf("Hata: Görev temizliği yapılamadı: %v", err)
+				}
+			}
+		}
+	}()
+	log.Printf("Görev temizleyici başlatıldı (aralık: %s)", interval)
+}
+
+// Sweep, TTL'i dolan görevleri siler ve defter MaxEntries'i aşıyorsa kapasiteye göre eviction yapar.
+func (r *TaskRegistry) Sweep(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos, err := r.store.List()
+	if err != nil {
+		return err
+	}
+	taskMetrics.Add("sweeps", 1)
+
+	var expiredTerminal, expiredActive int
+	live := infos[:0]
+	for _, info := range infos {
+		terminal := isTerminalTask(info)
+		ttl := r.retention.ActiveTTL
+		if terminal {
+			ttl = r.retention.TerminalTTL
+		}
+
+		// Zaman bilgisi olmayan eski kayıtlar TTL ile silinmez, yalnızca kapasite aşımında silinebilir
+		activity := lastActivity(info)
+		if ttl <= 0 || activity.IsZero() || now.Sub(activity) <= ttl {
+			live = append(live, info)
+			continue
+		}
+
+		if err := r.deleteTask(info.TaskID); err != nil {
+			return err
+		}
+		if terminal {
+			expiredTerminal++
+		} else {
+			expiredActive++
+		}
+	}
+	taskMetrics.Add("evicted_terminal_ttl", int64(expiredTerminal))
+	taskMetrics.Add("evicted_active_ttl", int64(expiredActive))
+
+	evicted, err := r.evictOverCapacity(live)
+	if err != nil {
+		return err
+	}
+
+	if expiredTerminal+expiredActive+evicted > 0 {
+		log.Printf("Görev temizliği: %d biten, %d bitmemiş görevin süresi doldu, %d görev kapasite nedeniyle silindi",
+			expiredTerminal, expiredActive, evicted)
+	}
+	return nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+// enforceCapacity, yeni görev kaydından sonra defter MaxEntries'i aşıyorsa eviction yapar. Defterde yalnızca
+// bitmemiş görevler varken her kayıtta bütün defterin sıralanmaması için en fazla capacityCheckInterval'da bir
+// çalışır; arada kalan aşım bir sonraki kontrolde ya da sweeper tarafından temizlenir.
+func (r *TaskRegistry) enforceCapacity() {
+	if r.retention.MaxEntries <= 0 || r.store.Len() <= r.retention.MaxEntries {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCapacityCheck) < capacityCheckInterval {
+		return
+	}
+	r.lastCapacityCheck = time.Now()
+
+	infos, err := r.store.List()
+	if err == nil {
+		_, err = r.evictOverCapacity(infos)
+	}
+	if err != nil {
+		log.Printf("Hata: Görev defteri kapasite temizliği yapılamadı: %v", err)
+	}
+}
+
+// evictOverCapacity, defter MaxEntries'in altına inene kadar en uzun süredir erişilmeyen biten görevleri siler.
+// Bitmemiş görevler kapasite nedeniyle silinmez. r.mu tutulurken çağrılmalıdır.
+func (r *TaskRegistry) evictOverCapacity(infos []TaskInfo) (int, error) {
+	excess := len(infos) - r.retention.MaxEntries
+	if r.retention.MaxEntries <= 0 || excess <= 0 {
+		return 0, nil
+	}
+
+	candidates := make([]TaskInfo, 0, len(infos))
+	for _, info := range infos {
+		if isTerminalTask(info) {
+			candidates = append(candidates, info)
+		}
+	}
+
+	r.accessMu.Lock()
+	accessed := func(info TaskInfo) time.Time {
+		if at, ok := r.lastAccess[info.TaskID]; ok {
+			return at
+		}
+		return lastActivity(info)
+	}
+	slices.SortFunc(candidates, func(a, b TaskInfo) int {
+		return accessed(a).Compare(accessed(b))
+	})
+	r.accessMu.Unlock()
+
+	evicted := 0
+	for _, info := range candidates[:min(excess, len(candidates))] {
+		if err := r.deleteTask(info.TaskID); err != nil {
+			return evicted, err
+		}
+		evicted++
+	}
+	taskMetrics.Add("evicted_capacity", int64(evicted))
+	return evicted, nil
+}
+
+// deleteTask, görevi depodan ve erişim kayıtlarından siler. r.mu tutulurken çağrılmalıdır.
+func (r *TaskRegistry) deleteTask(taskID string) error {
+	if err := r.store.Delete(taskID); err != nil {
+		return err
+	}
+	r.accessMu.Lock()
+	delete(r.lastAccess, taskID)
+	r.accessMu.Unlock()
+	return nil
+}
+
+// touch, görevin son sorgulanma zamanını kaydeder. Yalnızca bellekte tutulur, yeniden başlatmada son güncelleme zamanı kullanılır.
+func (r *TaskRegistry) touch(taskID string) {
+	r.accessMu.Lock()
+	r.lastAccess[taskID] = time.Now()
+	r.accessMu.Unlock()
+}
+
+func isTerminalTask(info TaskInfo) bool {
+	return info.LastStatus != nil && info.LastStatus.Status.Terminal()
+}
+
+func lastActivity(info TaskInfo) time.Time {
+	if info.LastUpdate != nil {
+		return *info.LastUpdate
+	}
+	return info.CreatedAt
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Görev listesinde sayfa başına dönen kayıt sayısının varsayılanı ve üst sınırı
+const (
+	defaultTaskListLimit = 50
+	maxTaskListLimit     = 500
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TaskFilter, görev listesini daraltan koşullardır. Boş bırakılan alanlar filtre uygulamaz.
+type TaskFilter struct {
+	AgentName string
+	Statuses  []models.TaskStatus
+	Caller    string
+	// Nil değilse yalnızca kimliğin kullanabileceği agent'ların görevleri döner
+	Principal     *Principal
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// "created_at" ya da "updated_at"; Descending true ise en yeni kayıt başta olur
+	SortBy     string
+	Descending bool
+	Limit      int
+	Cursor     string
+}
+
+// TaskListResponse, /api/v1/tasks cevabıdır. NextCursor boşsa başka sayfa yoktur.
+type TaskListResponse struct {
+	Tasks      []models.TaskSummary `json:"tasks"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+// taskCursor, bir önceki sayfanın son kaydının sıralama değeridir. Cursor'ın başka bir sıralamayla
+// kullanılmaması için sıralama bilgisi de içinde taşınır.
+type taskCursor struct {
+	Sort   string    `json:"s"`
+	Time   time.Time `json:"t"`
+	TaskID string    `json:"id"`
+}
+
+// Query, filtreye uyan görevleri sıralı ve sayfalı olarak döndürür. Eşit zamanlı kayıtlar görev
+// kimliğine göre sıralanır, böylece sayfalar arasında kayıt atlanmaz ya da tekrarlanmaz.
+func (r *TaskRegistry) Query(filter TaskFilter) (TaskListResponse, error) {
+	var after *taskCursor
+	if filter.Cursor != "" {
+		cursor, err := decodeTaskCursor(filter.Cursor)
+		if err != nil || cursor.Sort != sortKey(filter) {
+			return TaskListResponse{}, ErrInvalidCursor
+		}
+		after = &cursor
+	}
+
+	infos, err := r.store.List()
+	if err != nil {
+		return TaskListResponse{}, err
+	}
+
+	matched := make([]TaskInfo, 0, len(infos))
+	for _, info := range infos {
+		if filter.matches(info) {
+			matched = append(matched, info)
+		}
+	}
+
+	compare := func(a, b TaskInfo) int {
+		if c := sortTime(a, filter.SortBy).Compare(sortTime(b, filter.SortBy)); c != 0 {
+			if filter.Descending {
+				return -c
+			}
+			return c
+		}
+		return strings.Compare(a.TaskID, b.TaskID)
+	}
+	slices.SortFunc(matched, compare)
+
+	start := 0
+	if after != nil {
+		position := TaskInfo{TaskID: after.TaskID, CreatedAt: after.Time, LastUpdate: &after.Time}
+		start, _ = slices.BinarySearchFunc(matched, position, compare)
+		if start < len(matched) && matched[start].TaskID == after.TaskID {
+			start++
+		}
+	}
+
+	end := min(start+filter.Limit, len(matched))
+	page := TaskListResponse{Tasks: make([]models.TaskSummary, 0, end-start)}
+	for _, info := range matched[start:end] {
+		page.Tasks = append(page.Tasks, taskSummary(info))
+	}
+	if end < len(matched) {
+		last := matched[end-1]
+		page.NextCursor = encodeTaskCursor(taskCursor{
+			Sort:   sortKey(filter),
+			Time:   sortTime(last, filter.SortBy),
+			TaskID: last.TaskID,
+		})
+	}
+	return page, nil
+}
+
+// HandleListTasks, /api/v1/tasks üzerinde görevleri listeler.
+// Filtreler: agent, status (virgülle birden fazla), caller, created_after, created_before (RFC 3339).
+// Sıralama: sort=created_at|updated_at, başına "-" eklenirse azalan (varsayılan -created_at).
+// Sayfalama: limit ve bir önceki cevaptaki next_cursor değeriyle cursor.
+func (o *Orchestrator) HandleListTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, param, err := parseTaskFilter(r)
+	if err != nil {
+		http.Error(w, "Invalid "+param+" parameter", http.StatusBadRequest)
+		return
+	}
+	filter.Principal = principalFrom(r.Context())
+
+	page, err := o.TaskRegistry.Query(filter)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			http.Error(w, "Invalid cursor parameter", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Task store error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// ---------------------- HELPERS ----------------------
+
+// parseTaskFilter, sorgu parametrelerini okur. Hata durumunda hatalı parametrenin adı da döner.
+func parseTaskFilter(r *http.Request) (TaskFilter, string, error) {
+	query := r.URL.Query()
+	filter := TaskFilter{
+		AgentName:  query.Get("agent"),
+		Caller:     query.Get("caller"),
+		Cursor:     query.Get("cursor"),
+		SortBy:     "created_at",
+		Descending: true,
+		Limit:      defaultTaskListLimit,
+	}
+
+	if raw := query.Get("status"); raw != "" {
+		for _, value := range strings.Split(raw, ",") {
+			status := models.TaskStatus(strings.TrimSpace(value))
+			if !status.Valid() {
+				return filter, "status", errors.New("geçersiz durum: " + value)
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	var err error
+	if raw := query.Get("created_after"); raw != "" {
+		if filter.CreatedAfter, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, "created_after", err
+		}
+	}
+	if raw := query.Get("created_before"); raw != "" {
+		if filter.CreatedBefore, err = time.Parse(time.RFC3339, raw); err != nil {
+			return filter, "created_before", err
+		}
+	}
+
+	if raw := query.Get("sort"); raw != "" {
+		field, descending := strings.CutPrefix(raw, "-")
+		if field != "created_at" && field != "updated_at" {
+			return filter, "sort", errors.New("geçersiz sıralama: " + raw)
+		}
+		filter.SortBy, filter.Descending = field, descending
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, "limit", errors.New("geçersiz limit: " + raw)
+		}
+		filter.Limit = min(limit, maxTaskListLimit)
+	}
+
+	return filter, "", nil
+}
+
+func (f TaskFilter) matches(info TaskInfo) bool {
+	if f.AgentName != "" && info.AgentName != f.AgentName {
+		return false
+	}
+	if f.Caller != "" && info.Caller != f.Caller {
+		return false
+	}
+	if !f.Principal.AllowsAgent(info.AgentName) {
+		return false
+	}
+	if len(f.Statuses) > 0 && (info.LastStatus == nil || !slices.Contains(f.Statuses, info.LastStatus.Status)) {
+		return false
+	}
+	if !f.CreatedAfter.IsZero() && !info.CreatedAt.After(f.CreatedAfter) {
+		return false
+	}
+	if !f.CreatedBefore.IsZero() && !info.CreatedAt.Before(f.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// taskSummary, defter kaydını listede istemciye dönülen alanlara indirger.
+func taskSummary(info TaskInfo) models.TaskSummary {
+	summary := models.TaskSummary{
+		TaskID:     info.TaskID,
+		AgentName:  info.AgentName,
+		Caller:     info.Caller,
+		CreatedAt:  info.CreatedAt,
+		LastUpdate: info.LastUpdate,
+	}
+	if info.LastStatus != nil {
+		summary.Status = info.LastStatus.Status
+	}
+	return summary
+}
+
+// sortTime, görevin sıralamada kullanılan zamanıdır. Hiç güncellenmemiş görevlerde oluşturulma zamanı kullanılır.
+func sortTime(info TaskInfo, sortBy string) time.Time {
+	if sortBy == "updated_at" && info.LastUpdate != nil {
+		return *info.LastUpdate
+	}
+	return info.CreatedAt
+}
+
+func sortKey(f TaskFilter) string {
+	if f.Descending {
+		return "-" + f.SortBy
+	}
+	return f.SortBy
+}
+
+func encodeTaskCursor(cursor taskCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeTaskCursor(raw string) (taskCursor, error) {
+	var cursor taskCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
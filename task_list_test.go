@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Testlerdeki görevlerin oluşturulma zamanlarının başlangıcı
+var taskListBase = time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+// newListRegistry, task-0 ... task-(n-1) görevlerini dakika arayla oluşturulmuş olarak içeren bir defter döndürür.
+// task-3 ve task-4 aynı zamanda oluşturulmuştur, böylece eşit zamanlı kayıtların sırası da denenir.
+func newListRegistry(t *testing.T, n int) *TaskRegistry {
+	t.Helper()
+	store := NewMemoryTaskStore()
+	for i := range n {
+		createdAt := taskListBase.Add(time.Duration(i) * time.Minute)
+		if i == 4 {
+			createdAt = taskListBase.Add(3 * time.Minute)
+		}
+		info := TaskInfo{
+			TaskID:     fmt.Sprintf("task-%d", i),
+			AgentName:  "echo",
+			CreatedAt:  createdAt,
+			LastStatus: &models.TaskStatusResponse{Status: models.StatusCompleted},
+		}
+		if err := store.Save(info); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewTaskRegistry(store, TaskRetention{})
+}
+
+func pageIDs(page TaskListResponse) []string {
+	ids := make([]string, 0, len(page.Tasks))
+	for _, task := range page.Tasks {
+		ids = append(ids, task.TaskID)
+	}
+	return ids
+}
+
+func TestTaskCursorRoundTrip(t *testing.T) {
+	tests := []taskCursor{
+		{Sort: "-created_at", Time: taskListBase, TaskID: "task-1"},
+		{Sort: "updated_at", Time: taskListBase.Add(1500 * time.Millisecond), TaskID: "f47ac10b-58cc-4372-a567-0e02b2c3d479"},
+		{Sort: "created_at", Time: time.Date(2026, 10, 16, 12, 0, 0, 0, time.FixedZone("TRT", 3*60*60))},
+	}
+
+	for _, want := range tests {
+		t.Run(want.Sort, func(t *testing.T) {
+			raw := encodeTaskCursor(want)
+			got, err := decodeTaskCursor(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Sort != want.Sort || got.TaskID != want.TaskID || !got.Time.Equal(want.Time) {
+				t.Errorf("%+v, beklenen %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestQueryPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		descending bool
+		limit      int
+		want       [][]string
+	}{
+		{"artan, eşit zamanlılar kimliğe göre", false, 2, [][]string{{"task-0", "task-1"}, {"task-2", "task-3"}, {"task-4", "task-5"}}},
+		// Eşit zamanlı kayıtlar azalan sıralamada da kimliğe göre artan dizilir
+		{"azalan", true, 4, [][]string{{"task-5", "task-3", "task-4", "task-2"}, {"task-1", "task-0"}}},
+		{"tek sayfa", false, 10, [][]string{{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newListRegistry(t, 6)
+			filter := TaskFilter{SortBy: "created_at", Descending: tt.descending, Limit: tt.limit}
+
+			for i, want := range tt.want {
+				page, err := r.Query(filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := pageIDs(page); !slices.Equal(got, want) {
+					t.Fatalf("sayfa %d: %v, beklenen %v", i, got, want)
+				}
+				last := i == len(tt.want)-1
+				if last != (page.NextCursor == "") {
+					t.Fatalf("sayfa %d: next_cursor %q", i, page.NextCursor)
+				}
+				filter.Cursor = page.NextCursor
+			}
+		})
+	}
+}
+
+func TestQueryCursorRejected(t *testing.T) {
+	valid := encodeTaskCursor(taskCursor{Sort: "-created_at", Time: taskListBase, TaskID: "task-0"})
+
+	tests := []struct {
+		name   string
+		cursor string
+		sortBy string
+	}{
+		{"base64 olmayan cursor", "not-base64!", "created_at"},
+		{"JSON olmayan cursor", base64.RawURLEncoding.EncodeToString([]byte("garbage")), "created_at"},
+		{"yanlış tipte alan", base64.RawURLEncoding.EncodeToString([]byte(`{"s": "-created_at", "t": 5}`)), "created_at"},
+		{"son karakteri değiştirilmiş cursor", valid[:len(valid)-1] + "!", "created_at"},
+		{"başka sıralamanın cursor'ı", valid, "updated_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newListRegistry(t, 3)
+			_, err := r.Query(TaskFilter{SortBy: tt.sortBy, Descending: true, Limit: 2, Cursor: tt.cursor})
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("ErrInvalidCursor bekleniyordu, gelen: %v", err)
+			}
+		})
+	}
+}
+
+// Cursor'ın gösterdiği görev sonradan süresi dolup silinse de sonraki sayfa kayıt atlamadan devam eder
+func TestQueryCursorAfterTaskExpired(t *testing.T) {
+	tests := []struct {
+		name    string
+		deleted []string
+		want    []string
+	}{
+		{"cursor'ın görevi silindi", []string{"task-1"}, []string{"task-2", "task-3"}},
+		{"cursor'ın görevi ve sonraki görev silindi", []string{"task-1", "task-2"}, []string{"task-3", "task-4"}},
+		{"kalan bütün görevler silindi", []string{"task-2", "task-3", "task-4", "task-5"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newListRegistry(t, 6)
+			filter := TaskFilter{SortBy: "created_at", Limit: 2}
+
+			first, err := r.Query(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, taskID := range tt.deleted {
+				if err := r.deleteTask(taskID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			filter.Cursor = first.NextCursor
+			page, err := r.Query(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pageIDs(page); !slices.Equal(got, tt.want) {
+				t.Errorf("%v, beklenen %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TaskStore, TaskRegistry'nin görev eşlemelerini sakladığı depolama arayüzüdür.
+type TaskStore interface {
+	Save(info TaskInfo) error
+	Load(taskID string) (TaskInfo, bool, error)
+	Delete(taskID string) error
+	List() ([]TaskInfo, error)
+	// Len, depodaki görev sayısıdır
+	Len() int
+	Close() error
+}
+
+// NewTaskStore, konfigürasyondaki tipe göre uygun TaskStore'u oluşturur.
+func NewTaskStore(cfg *OrchestratorConfig) (TaskStore, error) {
+	switch cfg.TaskStoreType {
+	case "memory":
+		return NewMemoryTaskStore(), nil
+	case "file":
+		return NewFileTaskStore(cfg.TaskStorePath)
+	default:
+		return nil, fmt.Errorf("bilinmeyen task store tipi: %s", cfg.TaskStoreType)
+	}
+}
+
+// ---------------------- MEMORY ----------------------
+
+// MemoryTaskStore, görevleri yalnızca bellekte tutar. Orchestrator yeniden başladığında kayıtlar kaybolur.
+type MemoryTaskStore struct {
+	mu    sync.RWMutex
+	tasks map[string]TaskInfo
+}
+
+func NewMemoryTaskStore() *MemoryTaskStore {
+	return &MemoryTaskStore{
+		tasks: make(map[string]TaskInfo),
+	}
+}
+
+func (s *MemoryTaskStore) Save(info TaskInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[info.TaskID] = info
+	return nil
+}
+
+func (s *MemoryTaskStore) Load(taskID string) (TaskInfo, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.tasks[taskID]
+	return info, ok, nil
+}
+
+func (s *MemoryTaskStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, taskID)
+	return nil
+}
+
+func (s *MemoryTaskStore) List() ([]TaskInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]TaskInfo, 0, len(s.tasks))
+	for _, info := range s.tasks {
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *MemoryTaskStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tasks)
+}
+
+func (s *MemoryTaskStore) Close() error {
+	return nil
+}
+
+// ---------------------- FILE ----------------------
+
+// Eskimiş kayıt sayısı bu değeri ve canlı kayıt sayısını geçtiğinde log dosyası sıkıştırılır.
+const fileStoreCompactThreshold = 1000
+
+// fileStoreRecord, log dosyasındaki tek bir satırdır.
+type fileStoreRecord struct {
+	Op     string    `json:"op"`
+	TaskID string    `json:"task_id"`
+	Task   *TaskInfo `json:"task,omitempty"`
+}
+
+// FileTaskStore, harici bir sunucuya ihtiyaç duymayan, dosya tabanlı gömülü bir depodur.
+// Her değişiklik dosyanın sonuna JSON satırı olarak eklenir, okuma bellekteki indeks üzerinden yapılır.
+// Üzerine yazılmış ya da silinmiş kayıtlar birikince dosya yalnızca canlı kayıtlarla yeniden yazılır.
+type FileTaskStore struct {
+	mu    sync.RWMutex
+	path  string
+	file  *os.File
+	tasks map[string]TaskInfo
+	// Log dosyasında artık geçerli olmayan satır sayısı
+	stale int
+}
+
+func NewFileTaskStore(path string) (*FileTaskStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileTaskStore{
+		path:  path,
+		tasks: make(map[string]TaskInfo),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+
+	// Açılışta eskimiş kayıtlar temizlenir, böylece dosya her yeniden başlatmada küçülür
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Task store yüklendi: %s (%d görev)", path, len(s.tasks))
+	return s, nil
+}
+
+func (s *FileTaskStore) Save(info TaskInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(fileStoreRecord{Op: "put", TaskID: info.TaskID, Task: &info}); err != nil {
+		return err
+	}
+	if _, exists := s.tasks[info.TaskID]; exists {
+		s.stale++
+	}
+	s.tasks[info.TaskID] = info
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileTaskStore) Load(taskID string) (TaskInfo, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	info, ok := s.tasks[taskID]
+	return info, ok, nil
+}
+
+func (s *FileTaskStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.tasks[taskID]; !exists {
+		return nil
+	}
+	if err := s.append(fileStoreRecord{Op: "delete", TaskID: taskID}); err != nil {
+		return err
+	}
+	delete(s.tasks, taskID)
+	// Hem eski "put" satırı hem de bu "delete" satırı artık gereksizdir
+	s.stale += 2
+	s.maybeCompact()
+	return nil
+}
+
+func (s *FileTaskStore) List() ([]TaskInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]TaskInfo, 0, len(s.tasks))
+	for _, info := range s.tasks {
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *FileTaskStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.tasks)
+}
+
+func (s *FileTaskStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// ---------------------- HELPERS ----------------------
+
+// replay, log dosyasını baştan okuyarak bellekteki indeksi kurar.
+// Yarım yazılmış ya da bozuk satırlar (ör. çökme sonrası) atlanır.
+func (s *FileTaskStore) replay() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec fileStoreRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Printf("Uyarı: Task store'da bozuk satır atlandı: %v", err)
+			s.stale++
+			continue
+		}
+
+		_, exists := s.tasks[rec.TaskID]
+		switch {
+		case rec.Op == "put" && rec.Task != nil:
+			if exists {
+				s.stale++
+			}
+			s.tasks[rec.TaskID] = *rec.Task
+		case rec.Op == "delete":
+			if exists {
+				s.stale++
+			}
+			s.stale++
+			delete(s.tasks, rec.TaskID)
+		default:
+			s.stale++
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *FileTaskStore) append(rec fileStoreRecord) error {
+	if s.file == nil {
+		return fmt.Errorf("task store kapalı")
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+// maybeCompact, eskimiş kayıtlar birikmişse dosyayı sıkıştırır. Kayıt zaten yazılmış olduğundan sıkıştırma
+// hatası işlemi başarısız saymaz; eski dosyayla devam edilir ve bir sonraki değişiklikte yeniden denenir.
+func (s *FileTaskStore) maybeCompact() {
+	if s.stale < fileStoreCompactThreshold || s.stale < len(s.tasks) {
+		return
+	}
+	if err := s.compact(); err != nil {
+		log.Printf("Uyarı: Task store sıkıştırılamadı, eski dosyayla devam ediliyor: %v", err)
+	}
+}
+
+// compact, yalnızca canlı kayıtları içeren yeni bir dosya yazar ve eskisinin yerine atomik olarak taşır.
+// Yeni dosya append modunda açılır ve taşındıktan sonra aynı handle ile yazmaya devam edilir; böylece taşıma
+// başarısız olursa eski dosyayla, başarılı olursa yeniden açmaya gerek kalmadan yenisiyle devam edilir.
+func (s *FileTaskStore) compact() error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(tmp)
+	for id, info := range s.tasks {
+		task := info
+		line, err := json.Marshal(fileStoreRecord{Op: "put", TaskID: id, Task: &task})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file = tmp
+
+	if s.stale > 0 {
+		log.Printf("Task store sıkıştırıldı: %d eskimiş kayıt temizlendi, %d görev kaldı", s.stale, len(s.tasks))
+	}
+	s.stale = 0
+	return nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// storeLine, log dosyasına yazılacak tek bir kaydın JSON satırını döndürür.
+func storeLine(t *testing.T, rec fileStoreRecord) string {
+	t.Helper()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(line)
+}
+
+func putLine(t *testing.T, taskID, agentName string) string {
+	return storeLine(t, fileStoreRecord{Op: "put", TaskID: taskID, Task: &TaskInfo{TaskID: taskID, AgentName: agentName}})
+}
+
+func deleteLine(t *testing.T, taskID string) string {
+	return storeLine(t, fileStoreRecord{Op: "delete", TaskID: taskID})
+}
+
+// storedTaskIDs, depodaki görevlerin kimliklerini sıralı döndürür.
+func storedTaskIDs(t *testing.T, s TaskStore) []string {
+	t.Helper()
+	infos, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ids = append(ids, info.TaskID)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// readStoreFile, log dosyasındaki satırları çözer; çözülemeyen satır testi başarısız sayar.
+func readStoreFile(t *testing.T, path string) []fileStoreRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var records []fileStoreRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec fileStoreRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("dosyada bozuk satır kaldı: %q", scanner.Text())
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestFileTaskStoreReplay(t *testing.T) {
+	tests := []struct {
+		name string
+		// Dosyanın içeriği; nil ise dosya hiç yoktur
+		lines []string
+		want  []string
+		// task-1'in son hâlinin agent adı
+		wantAgent string
+	}{
+		{
+			name: "dosya yok",
+			want: []string{},
+		},
+		{
+			name:  "sağlam kayıtlar",
+			lines: []string{putLine(t, "task-1", "echo"), putLine(t, "task-2", "echo")},
+			want:  []string{"task-1", "task-2"}, wantAgent: "echo",
+		},
+		{
+			name:  "son satır yarım yazılmış",
+			lines: []string{putLine(t, "task-1", "echo"), putLine(t, "task-2", "echo"), `{"op":"put","task_id":"task-3","task":{"task_id":"ta`},
+			want:  []string{"task-1", "task-2"}, wantAgent: "echo",
+		},
+		{
+			name:  "yarım satırdaki güncelleme uygulanmaz",
+			lines: []string{putLine(t, "task-1", "echo"), `{"op":"put","task_id":"task-1","task":{"task_id":"task-1","agent_na`},
+			want:  []string{"task-1"}, wantAgent: "echo",
+		},
+		{
+			name:  "üzerine yazma ve silme",
+			lines: []string{putLine(t, "task-1", "echo"), putLine(t, "task-2", "echo"), putLine(t, "task-1", "other"), deleteLine(t, "task-2")},
+			want:  []string{"task-1"}, wantAgent: "other",
+		},
+		{
+			name:  "ortadaki bozuk satır ve bilinmeyen işlem atlanır",
+			lines: []string{putLine(t, "task-1", "echo"), "garbage", `{"op":"rename","task_id":"task-1"}`, putLine(t, "task-2", "echo")},
+			want:  []string{"task-1", "task-2"}, wantAgent: "echo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "data", "tasks.jsonl")
+			if tt.lines != nil {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				// Yarım satırların sonunda satır sonu olmaz
+				if err := os.WriteFile(path, []byte(strings.Join(tt.lines, "\n")), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s, err := NewFileTaskStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if got := storedTaskIDs(t, s); !slices.Equal(got, tt.want) {
+				t.Fatalf("görevler %v, beklenen %v", got, tt.want)
+			}
+			if tt.wantAgent != "" {
+				info, ok, _ := s.Load("task-1")
+				if !ok || info.AgentName != tt.wantAgent {
+					t.Errorf("task-1 agent'ı %q, beklenen %q", info.AgentName, tt.wantAgent)
+				}
+			}
+
+			// Açılışta dosya yalnızca canlı görevlerin "put" satırlarıyla yeniden yazılır
+			records := readStoreFile(t, path)
+			var compacted []string
+			for _, rec := range records {
+				if rec.Op != "put" || rec.Task == nil {
+					t.Errorf("sıkıştırılmış dosyada beklenmeyen kayıt: %+v", rec)
+				}
+				compacted = append(compacted, rec.TaskID)
+			}
+			slices.Sort(compacted)
+			if !slices.Equal(compacted, tt.want) {
+				t.Errorf("dosyadaki görevler %v, beklenen %v", compacted, tt.want)
+			}
+
+			// Sıkıştırmadan sonraki yazmalar yeni dosyaya eklenir ve yeniden açılışta görünür
+			if err := s.Save(TaskInfo{TaskID: "task-new", AgentName: "echo"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete("task-1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			reopened, err := NewFileTaskStore(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+
+			want := slices.DeleteFunc(slices.Clone(tt.want), func(id string) bool { return id == "task-1" })
+			want = append(want, "task-new")
+			slices.Sort(want)
+			if got := storedTaskIDs(t, reopened); !slices.Equal(got, want) {
+				t.Errorf("yeniden açılışta görevler %v, beklenen %v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileTaskStoreClosed(t *testing.T) {
+	s, err := NewFileTaskStore(filepath.Join(t.TempDir(), "tasks.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(TaskInfo{TaskID: "task-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// İkinci Close hata vermez
+	if err := s.Close(); err != nil {
+		t.Errorf("ikinci Close hata verdi: %v", err)
+	}
+
+	if err := s.Save(TaskInfo{TaskID: "task-2"}); err == nil {
+		t.Error("kapalı depoya yazma hata vermeliydi")
+	}
+	if err := s.Delete("task-1"); err == nil {
+		t.Error("kapalı depodan silme hata vermeliydi")
+	}
+	// Başarısız yazmalar bellekteki indeksi değiştirmez
+	if got := storedTaskIDs(t, s); !slices.Equal(got, []string{"task-1"}) {
+		t.Errorf("görevler %v, beklenen [task-1]", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -16,6 +17,9 @@ import (
 type TaskState struct {
 	Response   models.TaskStatusResponse
 	CancelFunc context.CancelFunc
+	// Orchestrator callback adresi verdiyse görev bitince durum buraya da bildirilir
+	CallbackURL   string
+	CallbackToken string
 }
 
 type PdfArgs struct {
@@ -37,6 +41,9 @@ func main() {
 	mux.HandleFunc("/execute", agent.handleExecute)
 	mux.HandleFunc("/task_status/", agent.handleTaskStatus)
 	mux.HandleFunc("/task_stop/", agent.handleStopTask)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
 
 	log.Println("[PDF Agent] Asenkron PDF agent servisi http://localhost:8083 adresinde başlatılıyor...")
 	if err := http.ListenAndServe(":8083", mux); err != nil {
@@ -65,7 +72,9 @@ func (a *PdfAgent) handleExecute(w http.ResponseWriter, r *http.Request) {
 			Status: models.StatusRunning,
 			Result: initialResult,
 		},
-		CancelFunc: cancel,
+		CancelFunc:    cancel,
+		CallbackURL:   r.Header.Get("X-Callback-URL"),
+		CallbackToken: r.Header.Get("X-Callback-Token"),
 	}
 	a.tasksMu.Unlock()
 
@@ -88,6 +97,7 @@ func (a *PdfAgent) handleExecute(w http.ResponseWriter, r *http.Request) {
 			}
 			a.tasksMu.Unlock()
 			log.Printf("[PDF Agent] Görev %s tamamlandı.", tID)
+			a.sendCallback(tID)
 
 		case <-ctx.Done():
 			a.tasksMu.Lock()
@@ -98,6 +108,7 @@ func (a *PdfAgent) handleExecute(w http.ResponseWriter, r *http.Request) {
 			}
 			a.tasksMu.Unlock()
 			log.Printf("[PDF Agent] Görev %s durduruldu.", tID)
+			a.sendCallback(tID)
 		}
 	}(taskID, ctx)
 
@@ -155,3 +166,31 @@ func (a *PdfAgent) handleStopTask(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"status":"stop signal sent"}`))
 }
+
+// sendCallback, görevin son durumunu Orchestrator'ın verdiği callback adresine gönderir.
+func (a *PdfAgent) sendCallback(taskID string) {
+	a.tasksMu.RLock()
+	state, ok := a.tasks[taskID]
+	a.tasksMu.RUnlock()
+
+	if !ok || state.CallbackURL == "" {
+		return
+	}
+
+	body, _ := json.Marshal(state.Response)
+	req, err := http.NewRequest("POST", state.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[PDF Agent] Callback isteği oluşturulamadı: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+state.CallbackToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("[PDF Agent] Callback gönderilemedi (%s): %v", taskID, err)
+		return
+	}
+	resp.Body.Close()
+	log.Printf("[PDF Agent] Görev %s için callback gönderildi: %s", taskID, resp.Status)
+}
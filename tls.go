@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// tlsSnapshot, belirli bir anda okunmuş sertifika ve CA havuzudur. generation her yeniden yüklemede artar.
+type tlsSnapshot struct {
+	cert       *tls.Certificate
+	pool       *x509.CertPool
+	generation int
+}
+
+// tlsMaterial, sertifika, anahtar ve CA dosyalarını okur ve dosyalar değiştiğinde yeniden yükler.
+// Değişiklik dosyaların değişiklik zamanı ve boyutuyla anlaşılır; bu, sertifikaları symlink değiştirerek
+// yenileyen araçlarla da çalışır. Yeni dosyalar okunamazsa (ör. yarım yazılmışsa) eskileriyle devam edilir.
+type tlsMaterial struct {
+	certFile, keyFile, caFile string
+
+	mu    sync.Mutex
+	stamp string
+	// Son okunamayan dosyaların özeti
+	failedStamp string
+	snapshot    tlsSnapshot
+}
+
+func newTLSMaterial(certFile, keyFile, caFile string) (*tlsMaterial, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("sertifika ve anahtar dosyası birlikte verilmeli")
+	}
+
+	m := &tlsMaterial{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if _, err := m.current(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// current, dosyalar değiştiyse yeniden okur ve güncel sertifikaları döndürür.
+func (m *tlsMaterial) current() (tlsSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stamp, err := m.fileStamp()
+	if err == nil && (stamp == m.stamp || stamp == m.failedStamp) {
+		return m.snapshot, nil
+	}
+
+	var snapshot tlsSnapshot
+	if err == nil {
+		snapshot, err = m.load()
+	}
+	if err != nil {
+		if m.stamp == "" {
+			return tlsSnapshot{}, err
+		}
+		// Aynı hatalı dosyalar için her bağlantıda tekrar denenmez ve log basılmaz
+		if stamp != "" {
+			m.failedStamp = stamp
+			log.Printf("Uyarı: Değişen TLS dosyaları okunamadı, eski sertifikalarla devam ediliyor: %v", err)
+		}
+		return m.snapshot, nil
+	}
+
+	if m.stamp != "" {
+		log.Printf("TLS dosyaları yeniden yüklendi: %s", strings.Join(m.files(), ", "))
+	}
+	snapshot.generation = m.snapshot.generation + 1
+	m.snapshot, m.stamp, m.failedStamp = snapshot, stamp, ""
+	return snapshot, nil
+}
+
+// NewServerTLSConfig, Orchestrator API'sinin TLS listener'ı için ayar üretir. TLS_CLIENT_CA_FILE verilmişse
+// istemcilerin bu CA'nın imzaladığı bir sertifika sunması zorunludur. Sertifikalar ve CA her bağlantıda
+// kontrol edilir, değişmişlerse yeniden başlatmadan yüklenir.
+func NewServerTLSConfig(cfg *OrchestratorConfig) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("TLS_LISTEN_ADDR için TLS_CERT_FILE ve TLS_KEY_FILE gerekli")
+	}
+	minVersion, err := parseTLSVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, fmt.Errorf("TLS_MIN_VERSION geçersiz: %v", err)
+	}
+	material, err := newTLSMaterial(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			snapshot, err := material.current()
+			if err != nil {
+				return nil, err
+			}
+
+			config := &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*snapshot.cert},
+				NextProtos:   []string{"h2", "http/1.1"},
+			}
+			if snapshot.pool != nil {
+				config.ClientCAs = snapshot.pool
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return config, nil
+		},
+	}, nil
+}
+
+// ---------------------- HELPERS ----------------------
+
+func (m *tlsMaterial) load() (tlsSnapshot, error) {
+	var snapshot tlsSnapshot
+	if m.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(m.certFile, m.keyFile)
+		if err != nil {
+			return snapshot, fmt.Errorf("sertifika okunamadı: %v", err)
+		}
+		snapshot.cert = &cert
+	}
+	if m.caFile != "" {
+		data, err := os.ReadFile(m.caFile)
+		if err != nil {
+			return snapshot, fmt.Errorf("CA dosyası okunamadı: %v", err)
+		}
+		snapshot.pool = x509.NewCertPool()
+		if !snapshot.pool.AppendCertsFromPEM(data) {
+			return snapshot, fmt.Errorf("CA dosyasında sertifika bulunamadı: %s", m.caFile)
+		}
+	}
+	return snapshot, nil
+}
+
+func (m *tlsMaterial) files() []string {
+	var files []string
+	for _, file := range []string{m.certFile, m.keyFile, m.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fileStamp, dosyaların değişiklik zamanı ve boyutundan bir özet üretir.
+func (m *tlsMaterial) fileStamp() (string, error) {
+	var stamp strings.Builder
+	for _, file := range m.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp.String(), nil
+}
+
+// parseTLSVersion, "1.2" ya da "1.3" değerini tls sabitine çevirir; boşsa TLS 1.2 kullanılır.
+func parseTLSVersion(value string) (uint16, error) {
+	switch value {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("desteklenmeyen TLS sürümü: %q (1.2 ya da 1.3 olmalı)", value)
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSMaterialReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca.writeCert(t, "sunucu-1", false, certFile, keyFile)
+
+	m, err := newTLSMaterial(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := m.current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// Snapshot alınmadan önce dosyalara yapılan değişiklik
+		change         func(t *testing.T)
+		wantGeneration int
+		wantCN         string
+	}{
+		{"dosyalar değişmeden aynı sertifika", func(t *testing.T) {}, 1, "sunucu-1"},
+		{"yeni sertifika yüklenir", func(t *testing.T) {
+			ca.writeCert(t, "sunucu-2", false, certFile, keyFile)
+		}, 2, "sunucu-2"},
+		{"yarım yazılmış sertifikada eskisiyle devam edilir", func(t *testing.T) {
+			writeTestFile(t, certFile, []byte("-----BEGIN CERTIFICATE-----\n"))
+		}, 2, "sunucu-2"},
+		{"düzelen dosya yeniden yüklenir", func(t *testing.T) {
+			ca.writeCert(t, "sunucu-3", false, certFile, keyFile)
+		}, 3, "sunucu-3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change(t)
+			snapshot, err := m.current()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snapshot.generation != tt.wantGeneration {
+				t.Errorf("generation %d, beklenen %d", snapshot.generation, tt.wantGeneration)
+			}
+			if cn := leafCommonName(t, snapshot.cert); cn != tt.wantCN {
+				t.Errorf("sertifika %s, beklenen %s", cn, tt.wantCN)
+			}
+		})
+	}
+	if leafCommonName(t, first.cert) != "sunucu-1" {
+		t.Error("önceki snapshot değişti")
+	}
+}
+
+func TestNewTLSMaterialErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca.writeCert(t, "sunucu", false, certFile, keyFile)
+	caFile := filepath.Join(dir, "ca.crt")
+	writeTestFile(t, caFile, ca.certPEM)
+	emptyCAFile := filepath.Join(dir, "empty.crt")
+	writeTestFile(t, emptyCAFile, []byte("sertifika yok"))
+
+	tests := []struct {
+		name                      string
+		certFile, keyFile, caFile string
+		wantErr                   bool
+	}{
+		{"sertifika ve anahtar", certFile, keyFile, "", false},
+		{"yalnızca CA", "", "", caFile, false},
+		{"anahtarsız sertifika", certFile, "", "", true},
+		{"sertifikasız anahtar", "", keyFile, "", true},
+		{"olmayan dosya", filepath.Join(dir, "missing.crt"), keyFile, "", true},
+		{"sertifikayla uyuşmayan anahtar", certFile, caFile, "", true},
+		{"sertifika içermeyen CA dosyası", "", "", emptyCAFile, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTLSMaterial(tt.certFile, tt.keyFile, tt.caFile); (err != nil) != tt.wantErr {
+				t.Errorf("hata %v, hata bekleniyor: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TLS_CLIENT_CA_FILE verilince istemci sertifikası zorunludur; sunucu sertifikası yeniden başlatmadan yenilenir
+func TestNewServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	serverCA, clientCA, otherCA := newTestCA(t, "Sunucu CA"), newTestCA(t, "İstemci CA"), newTestCA(t, "Başka CA")
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	serverCA.writeCert(t, "sunucu-1", false, certFile, keyFile)
+	clientCAFile := filepath.Join(dir, "client-ca.crt")
+	writeTestFile(t, clientCAFile, clientCA.certPEM)
+
+	tests := []struct {
+		name         string
+		clientCAFile string
+		// İstemcinin sunduğu sertifikayı imzalayan CA; nil ise sertifika sunulmaz
+		clientCert *testCA
+		wantErr    bool
+	}{
+		{"mTLS kapalı", "", nil, false},
+		{"mTLS kapalıyken sunulan sertifika", "", clientCA, false},
+		{"sertifikasız istemci reddedilir", clientCAFile, nil, true},
+		{"başka CA'nın sertifikası reddedilir", clientCAFile, otherCA, true},
+		{"istemci CA'sının sertifikası", clientCAFile, clientCA, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := NewServerTLSConfig(&OrchestratorConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: tt.clientCAFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := newTestTLSServer(t, config, nil)
+
+			var certificates []tls.Certificate
+			if tt.clientCert != nil {
+				certificates = append(certificates, tt.clientCert.issue(t, "istemci", true))
+			}
+			resp, err := newTestTLSClient(serverCA, certificates).Get(server.URL)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hata %v, hata bekleniyor: %t", err, tt.wantErr)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+		})
+	}
+
+	t.Run("sunucu sertifikası yenilenir", func(t *testing.T) {
+		config, err := NewServerTLSConfig(&OrchestratorConfig{TLSCertFile: certFile, TLSKeyFile: keyFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := newTestTLSServer(t, config, nil)
+		client := newTestTLSClient(serverCA, nil)
+
+		for _, cn := range []string{"sunucu-1", "sunucu-2"} {
+			serverCA.writeCert(t, cn, false, certFile, keyFile)
+			resp, err := client.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if got := resp.TLS.PeerCertificates[0].Subject.CommonName; got != cn {
+				t.Errorf("sunucu sertifikası %s, beklenen %s", got, cn)
+			}
+		}
+	})
+}
+
+func TestNewServerTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "Test CA")
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	ca.writeCert(t, "sunucu", false, certFile, keyFile)
+
+	tests := []struct {
+		name string
+		cfg  OrchestratorConfig
+	}{
+		{"sertifika yok", OrchestratorConfig{TLSKeyFile: keyFile}},
+		{"anahtar yok", OrchestratorConfig{TLSCertFile: certFile}},
+		{"geçersiz sürüm", OrchestratorConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSMinVersion: "1.1"}},
+		{"olmayan istemci CA'sı", OrchestratorConfig{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: filepath.Join(dir, "missing.crt")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewServerTLSConfig(&tt.cfg); err == nil {
+				t.Error("hata bekleniyordu")
+			}
+		})
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    uint16
+		wantErr bool
+	}{
+		{"", tls.VersionTLS12, false},
+		{"1.2", tls.VersionTLS12, false},
+		{"1.3", tls.VersionTLS13, false},
+		{"1.1", 0, true},
+		{"tls1.3", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTLSVersion(tt.value)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("%d %v, beklenen %d", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// ---------------------- HELPERS ----------------------
+
+// testCA, testlerde sertifika imzalayan bir CA'dır.
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issuePEM, 127.0.0.1 ve localhost için geçerli bir sunucu ya da istemci sertifikası imzalar.
+func (ca *testCA) issuePEM(t *testing.T, cn string, client bool) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage := x509.ExtKeyUsageServerAuth
+	if client {
+		usage = x509.ExtKeyUsageClientAuth
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) issue(t *testing.T, cn string, client bool) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issuePEM(t, cn, client)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// writeCert, yeni bir sertifika ve anahtar imzalayıp dosyalara yazar.
+func (ca *testCA) writeCert(t *testing.T, cn string, client bool, certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM := ca.issuePEM(t, cn, client)
+	writeTestFile(t, certFile, certPEM)
+	writeTestFile(t, keyFile, keyPEM)
+}
+
+// writeTestFile, dosyayı yazar ve değişiklik zamanını ileri alır; böylece dosya sistemi zamanı kaba
+// tutsa ve boyut aynı kalsa da değişiklik fark edilir.
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	modTime := time.Now()
+	if info, err := os.Stat(path); err == nil && !modTime.After(info.ModTime()) {
+		modTime = info.ModTime().Add(time.Second)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func leafCommonName(t *testing.T, cert *tls.Certificate) string {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// newTestTLSServer, config ile TLS sunan bir test sunucusu başlatır; handler nil ise her isteğe 200 döner.
+func newTestTLSServer(t *testing.T, config *tls.Config, handler http.Handler) *httptest.Server {
+	t.Helper()
+	if handler == nil {
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = config
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestTLSClient, her istekte yeni bağlantı kuran ve sunucuyu ca ile doğrulayan bir client döndürür.
+func newTestTLSClient(ca *testCA, certificates []tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca.certPEM)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: pool, Certificates: certificates},
+		DisableKeepAlives: true,
+	}}
+}
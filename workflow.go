@@ -0,0 +1,627 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+// Bir workflow'daki adım sayısının üst sınırı
+const maxWorkflowSteps = 100
+
+var workflowStepIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	ErrWorkflowFinished = errors.New("workflow already finished")
+)
+
+// workflowRun, çalışan ya da bitmiş bir workflow'un durumudur. status.Steps, steps ile aynı sıradadır.
+type workflowRun struct {
+	mu     sync.Mutex
+	status models.WorkflowStatusResponse
+	steps  []models.WorkflowStep
+	caller string
+	cancel context.CancelFunc
+}
+
+// stepOutcome, tek bir adımın sonucudur.
+type stepOutcome struct {
+	index  int
+	state  models.WorkflowState
+	result json.RawMessage
+	err    string
+}
+
+// WorkflowManager, workflow'ları çalıştırır ve durumlarını bellekte tutar. Biten workflow'lar
+// biten görevlerle aynı süre (TASK_TTL_TERMINAL) saklanır.
+type WorkflowManager struct {
+	o         *Orchestrator
+	retention time.Duration
+
+	mu        sync.Mutex
+	workflows map[string]*workflowRun
+}
+
+func NewWorkflowManager(o *Orchestrator, retention time.Duration) *WorkflowManager {
+	return &WorkflowManager{
+		o:         o,
+		retention: retention,
+		workflows: make(map[string]*workflowRun),
+	}
+}
+
+// Start, workflow'u doğrular ve arka planda çalıştırmaya başlar.
+func (m *WorkflowManager) Start(req models.WorkflowRequest, caller string) (models.WorkflowStatusResponse, error) {
+	if err := m.validate(req); err != nil {
+		return models.WorkflowStatusResponse{}, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &workflowRun{
+		status: models.WorkflowStatusResponse{
+			WorkflowID: NewTaskID(),
+			Name:       req.Name,
+			Status:     models.WorkflowRunning,
+			CreatedAt:  time.Now(),
+			Steps:      make([]models.WorkflowStepStatus, len(req.Steps)),
+		},
+		steps:  req.Steps,
+		caller: caller,
+		cancel: cancel,
+	}
+	for i, step := range req.Steps {
+		run.status.Steps[i] = models.WorkflowStepStatus{
+			ID:        step.ID,
+			AgentName: step.AgentName,
+			Status:    models.WorkflowPending,
+		}
+	}
+
+	m.mu.Lock()
+	m.pruneLocked()
+	m.workflows[run.status.WorkflowID] = run
+	m.mu.Unlock()
+
+	log.Printf("Workflow başlatıldı: %s (%d adım)", run.status.WorkflowID, len(req.Steps))
+	go m.execute(ctx, run)
+	return run.snapshot(), nil
+}
+
+func (m *WorkflowManager) Get(workflowID string) (models.WorkflowStatusResponse, bool) {
+	m.mu.Lock()
+	run, ok := m.workflows[workflowID]
+	m.mu.Unlock()
+
+	if !ok {
+		return models.WorkflowStatusResponse{}, false
+	}
+	return run.snapshot(), true
+}
+
+// List, bellekteki workflow'lardan kimliğin bütün adımlarının agent'larını kullanabildiklerini en yeniden eskiye döndürür.
+func (m *WorkflowManager) List(principal *Principal) []models.WorkflowStatusResponse {
+	m.mu.Lock()
+	runs := make([]*workflowRun, 0, len(m.workflows))
+	for _, run := range m.workflows {
+		runs = append(runs, run)
+	}
+	m.mu.Unlock()
+
+	list := make([]models.WorkflowStatusResponse, 0, len(runs))
+	for _, run := range runs {
+		if status := run.snapshot(); workflowAllowed(principal, status) {
+			list = append(list, status)
+		}
+	}
+	slices.SortFunc(list, func(a, b models.WorkflowStatusResponse) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return list
+}
+
+// Cancel, workflow'u iptal eder. Bekleyen adımlar başlatılmaz, çalışan asenkron adımlara durdurma isteği gönderilir.
+func (m *WorkflowManager) Cancel(workflowID string) (models.WorkflowStatusResponse, error) {
+	m.mu.Lock()
+	run, ok := m.workflows[workflowID]
+	m.mu.Unlock()
+
+	if !ok {
+		return models.WorkflowStatusResponse{}, ErrWorkflowNotFound
+	}
+
+	run.mu.Lock()
+	finished := run.status.Status.Terminal()
+	run.mu.Unlock()
+	if finished {
+		return run.snapshot(), ErrWorkflowFinished
+	}
+
+	log.Printf("Workflow iptal ediliyor: %s", workflowID)
+	run.cancel()
+	return run.snapshot(), nil
+}
+
+// HandleWorkflows, /api/v1/workflows üzerinde POST ile workflow başlatır, GET ile workflow'ları listeler.
+func (m *WorkflowManager) HandleWorkflows(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.List(principalFrom(r.Context())))
+
+	case "POST":
+		var req models.WorkflowRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		principal := principalFrom(r.Context())
+		for _, step := range req.Steps {
+			if !principal.AllowsAgent(step.AgentName) {
+				http.Error(w, fmt.Sprintf("Agent of step '%s' not allowed for this credential", step.ID), http.StatusForbidden)
+				return
+			}
+		}
+
+		status, err := m.Start(req, r.Header.Get(CallerIDHeader))
+		if err != nil {
+			log.Printf("Hata: Geçersiz workflow: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(status)
+
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleWorkflow, /api/v1/workflows/{id} üzerinden workflow'un ve adımlarının durumunu döndürür.
+func (m *WorkflowManager) HandleWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status, ok := m.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	}
+	if !workflowAllowed(principalFrom(r.Context()), status) {
+		http.Error(w, "Workflow agents not allowed for this credential", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// HandleWorkflowCancel, /api/v1/workflows/{id}/cancel üzerinden workflow'u iptal eder.
+func (m *WorkflowManager) HandleWorkflowCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if status, ok := m.Get(r.PathValue("id")); ok && !workflowAllowed(principalFrom(r.Context()), status) {
+		http.Error(w, "Workflow agents not allowed for this credential", http.StatusForbidden)
+		return
+	}
+
+	status, err := m.Cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrWorkflowNotFound):
+		http.Error(w, "Workflow not found", http.StatusNotFound)
+		return
+	case errors.Is(err, ErrWorkflowFinished):
+		http.Error(w, "Workflow already finished", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// ---------------------- HELPERS ----------------------
+
+// workflowAllowed, kimliğin workflow'un bütün adımlarının agent'larını kullanıp kullanamayacağını söyler.
+func workflowAllowed(principal *Principal, status models.WorkflowStatusResponse) bool {
+	for _, step := range status.Steps {
+		if !principal.AllowsAgent(step.AgentName) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate; adım kimliklerini, agent'ları, bağımlılıkları, döngüleri ve şablonların yalnızca
+// adımın (dolaylı) bağımlılıklarına başvurduğunu kontrol eder.
+func (m *WorkflowManager) validate(req models.WorkflowRequest) error {
+	if len(req.Steps) == 0 {
+		return errors.New("workflow en az bir adım içermeli")
+	}
+	if len(req.Steps) > maxWorkflowSteps {
+		return fmt.Errorf("workflow en fazla %d adım içerebilir", maxWorkflowSteps)
+	}
+
+	index := make(map[string]int, len(req.Steps))
+	for i, step := range req.Steps {
+		if !workflowStepIDPattern.MatchString(step.ID) {
+			return fmt.Errorf("geçersiz adım kimliği: '%s'", step.ID)
+		}
+		if _, exists := index[step.ID]; exists {
+			return fmt.Errorf("adım kimliği tekrar ediyor: '%s'", step.ID)
+		}
+		if _, ok := m.o.Registry.Get(step.AgentName); !ok {
+			return fmt.Errorf("'%s' adımının agent'ı bulunamadı: '%s'", step.ID, step.AgentName)
+		}
+		if step.Priority != "" && !step.Priority.Valid() {
+			return fmt.Errorf("'%s' adımının önceliği geçersiz: '%s'", step.ID, step.Priority)
+		}
+		index[step.ID] = i
+	}
+	for _, step := range req.Steps {
+		for _, dep := range step.DependsOn {
+			if _, ok := index[dep]; !ok || dep == step.ID {
+				return fmt.Errorf("'%s' adımının bağımlılığı geçersiz: '%s'", step.ID, dep)
+			}
+		}
+	}
+
+	order, err := topologicalOrder(req.Steps, index)
+	if err != nil {
+		return err
+	}
+
+	// Adımların dolaylı bağımlılıkları topolojik sırayla hesaplanır
+	ancestors := make([]map[string]bool, len(req.Steps))
+	for _, i := range order {
+		ancestors[i] = make(map[string]bool)
+		for _, dep := range req.Steps[i].DependsOn {
+			ancestors[i][dep] = true
+			for ancestor := range ancestors[index[dep]] {
+				ancestors[i][ancestor] = true
+			}
+		}
+	}
+
+	for i, step := range req.Steps {
+		refs, err := templateReferences(step.Arguments)
+		if err != nil {
+			return fmt.Errorf("'%s' adımının argümanları geçersiz: %v", step.ID, err)
+		}
+		for _, ref := range refs {
+			if !ancestors[i][ref] {
+				return fmt.Errorf("'%s' adımı, bağımlı olmadığı '%s' adımının sonucunu kullanıyor", step.ID, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// topologicalOrder, adımları bağımlılık sırasına dizer; döngü varsa hata döner.
+func topologicalOrder(steps []models.WorkflowStep, index map[string]int) ([]int, error) {
+	remaining := make([]int, len(steps))
+	dependents := make([][]int, len(steps))
+	for i, step := range steps {
+		remaining[i] = len(step.DependsOn)
+		for _, dep := range step.DependsOn {
+			dependents[index[dep]] = append(dependents[index[dep]], i)
+		}
+	}
+
+	var order, ready []int
+	for i := range steps {
+		if remaining[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, dependent := range dependents[i] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(steps) {
+		return nil, errors.New("workflow adımları arasında döngüsel bağımlılık var")
+	}
+	return order, nil
+}
+
+// execute, bağımlılıkları biten adımları paralel başlatır. Başarısız adımın bağımlıları atlanır,
+// bağımsız dallar çalışmaya devam eder.
+func (m *WorkflowManager) execute(ctx context.Context, run *workflowRun) {
+	defer run.cancel()
+
+	index := make(map[string]int, len(run.steps))
+	for i, step := range run.steps {
+		index[step.ID] = i
+	}
+	remaining := make([]int, len(run.steps))
+	dependents := make([][]int, len(run.steps))
+	for i, step := range run.steps {
+		remaining[i] = len(step.DependsOn)
+		for _, dep := range step.DependsOn {
+			dependents[index[dep]] = append(dependents[index[dep]], i)
+		}
+	}
+
+	results := make(map[string]json.RawMessage)
+	outcomes := make(chan stepOutcome)
+	running := 0
+
+	launch := func(i int) {
+		// Şablonlar adım başlamadan, bağımlılıkların sonuçlarıyla doldurulur
+		args, err := resolveTemplates(run.steps[i].Arguments, results)
+		if err != nil {
+			run.finishStep(stepOutcome{index: i, state: models.WorkflowFailed, err: err.Error()})
+			return
+		}
+		run.startStep(i)
+		running++
+		go func() {
+			outcomes <- m.runStep(ctx, run, i, args)
+		}()
+	}
+
+	for i := range run.steps {
+		if remaining[i] == 0 {
+			launch(i)
+		}
+	}
+
+	// Başlatılamayan kök adımlar da başarısızlık sayıldığından bağımlıları atlanır
+	for i := range run.steps {
+		if remaining[i] == 0 && run.stepState(i) == models.WorkflowFailed {
+			run.skipDependents(i, dependents)
+		}
+	}
+
+	for running > 0 {
+		outcome := <-outcomes
+		running--
+		run.finishStep(outcome)
+
+		if ctx.Err() != nil {
+			// İptalde bekleyen adımlar finish içinde iptal edildi olarak işaretlenir
+			continue
+		}
+		if outcome.state != models.WorkflowCompleted {
+			run.skipDependents(outcome.index, dependents)
+			continue
+		}
+		results[run.steps[outcome.index].ID] = outcome.result
+
+		for _, dependent := range dependents[outcome.index] {
+			remaining[dependent]--
+			if remaining[dependent] > 0 || run.stepState(dependent) != models.WorkflowPending {
+				continue
+			}
+			launch(dependent)
+			if run.stepState(dependent) == models.WorkflowFailed {
+				run.skipDependents(dependent, dependents)
+			}
+		}
+	}
+
+	run.finish(ctx.Err() != nil)
+	log.Printf("Workflow bitti: %s (%s)", run.status.WorkflowID, run.snapshot().Status)
+}
+
+// runStep, adımın görevini başlatır ve asenkron ise bitmesini bekler.
+func (m *WorkflowManager) runStep(ctx context.Context, run *workflowRun, i int, args json.RawMessage) stepOutcome {
+	o := m.o
+	step := run.steps[i]
+	outcome := stepOutcome{index: i, state: models.WorkflowFailed}
+
+	agent, ok := o.Registry.Get(step.AgentName)
+	if !ok {
+		outcome.err = "Agent not found"
+		return outcome
+	}
+
+	violations, err := o.Registry.ValidateArguments(agent.Name, args)
+	if err != nil {
+		outcome.err = "Invalid arguments JSON"
+		return outcome
+	}
+	if len(violations) > 0 {
+		outcome.err = fmt.Sprintf("Arguments do not match agent schema: %s %s", violations[0].Pointer, violations[0].Message)
+		return outcome
+	}
+
+	start, err := o.startTask(ctx, agent, args, "", run.caller, step.Priority)
+	defer start.Close()
+
+	switch {
+	case ctx.Err() != nil:
+		// İptal, agent görevi kabul ettikten ya da görev kuyruğa alındıktan sonra gelmiş olabilir
+		if err == nil && start.Accepted != nil {
+			run.setStepTask(i, start.TaskID)
+			o.stopCancelledStep(start.TaskID)
+		}
+		outcome.state = models.WorkflowCancelled
+		return outcome
+	case err != nil:
+		outcome.err = err.Error()
+		return outcome
+	case start.Accepted == nil:
+		// Senkron adımın cevabı da görev defterine yazılır, böylece task_status ile sorgulanabilir
+		status, _, err := syncEnvelopeStatus(start.TaskID, start.Response)
+		if err != nil {
+			outcome.err = err.Error()
+			return outcome
+		}
+		o.recordSyncTask(agent, start.Replica, run.caller, status)
+		run.setStepTask(i, start.TaskID)
+		return outcomeFromStatus(outcome, status)
+	}
+
+	run.setStepTask(i, start.TaskID)
+	status, err := o.waitForTask(ctx, start.TaskID)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Workflow iptal edildi, çalışan görev de durdurulur
+			o.stopCancelledStep(start.TaskID)
+			outcome.state = models.WorkflowCancelled
+			return outcome
+		}
+		outcome.err = err.Error()
+		return outcome
+	}
+	return outcomeFromStatus(outcome, status)
+}
+
+// stopCancelledStep, iptal edilen workflow'un adımına ait görevi agent'ta ya da kuyrukta durdurur, böylece
+// görev çalışmaya ve agent'taki yerini tutmaya devam etmez.
+func (o *Orchestrator) stopCancelledStep(taskID string) {
+	info, ok := o.TaskRegistry.GetTaskInfo(taskID)
+	if !ok {
+		return
+	}
+	if err := o.stopTask(context.Background(), info); err != nil && !errors.Is(err, ErrTaskFinished) {
+		log.Printf("Uyarı: İptal edilen workflow adımı durdurulamadı (TaskID %s): %v", taskID, err)
+	}
+}
+
+func outcomeFromStatus(outcome stepOutcome, status models.TaskStatusResponse) stepOutcome {
+	if status.Status == models.StatusCompleted {
+		outcome.state = models.WorkflowCompleted
+		outcome.result = status.Result
+		return outcome
+	}
+	outcome.err = status.Error
+	if outcome.err == "" {
+		outcome.err = "Task failed"
+	}
+	return outcome
+}
+
+// pruneLocked, saklama süresi dolan biten workflow'ları siler. m.mu tutulurken çağrılmalıdır.
+func (m *WorkflowManager) pruneLocked() {
+	if m.retention <= 0 {
+		return
+	}
+	for id, run := range m.workflows {
+		run.mu.Lock()
+		finishedAt := run.status.FinishedAt
+		run.mu.Unlock()
+		if finishedAt != nil && time.Since(*finishedAt) > m.retention {
+			delete(m.workflows, id)
+		}
+	}
+}
+
+func (run *workflowRun) snapshot() models.WorkflowStatusResponse {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	status := run.status
+	status.Steps = slices.Clone(run.status.Steps)
+	return status
+}
+
+func (run *workflowRun) stepState(i int) models.WorkflowState {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	return run.status.Steps[i].Status
+}
+
+func (run *workflowRun) startStep(i int) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	now := time.Now()
+	run.status.Steps[i].Status = models.WorkflowRunning
+	run.status.Steps[i].StartedAt = &now
+}
+
+func (run *workflowRun) setStepTask(i int, taskID string) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.status.Steps[i].TaskID = taskID
+}
+
+func (run *workflowRun) finishStep(outcome stepOutcome) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	now := time.Now()
+	step := &run.status.Steps[outcome.index]
+	step.Status = outcome.state
+	step.Result = outcome.result
+	step.Error = outcome.err
+	step.FinishedAt = &now
+}
+
+// skipDependents, adımın henüz başlamamış bütün (dolaylı) bağımlılarını atlandı olarak işaretler.
+func (run *workflowRun) skipDependents(i int, dependents [][]int) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	queue := slices.Clone(dependents[i])
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		if run.status.Steps[next].Status != models.WorkflowPending {
+			continue
+		}
+		run.status.Steps[next].Status = models.WorkflowSkipped
+		run.status.Steps[next].Error = fmt.Sprintf("Skipped because step '%s' did not complete", run.status.Steps[i].ID)
+		queue = append(queue, dependents[next]...)
+	}
+}
+
+// finish, workflow'un genel durumunu adımların durumundan belirler.
+func (run *workflowRun) finish(cancelled bool) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+
+	now := time.Now()
+	run.status.FinishedAt = &now
+	run.status.Status = models.WorkflowCompleted
+
+	for i := range run.status.Steps {
+		step := &run.status.Steps[i]
+		if cancelled && step.Status == models.WorkflowPending {
+			step.Status = models.WorkflowCancelled
+		}
+		switch step.Status {
+		case models.WorkflowCompleted:
+		case models.WorkflowCancelled:
+			if run.status.Status == models.WorkflowCompleted {
+				run.status.Status = models.WorkflowCancelled
+			}
+		default:
+			run.status.Status = models.WorkflowFailed
+			if run.status.Error == "" {
+				run.status.Error = fmt.Sprintf("Step '%s' %s", step.ID, step.Status)
+			}
+		}
+	}
+	if cancelled {
+		run.status.Status = models.WorkflowCancelled
+		run.status.Error = "Workflow cancelled"
+	}
+}
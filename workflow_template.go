@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Adım argümanlarındaki şablonlar: {{steps.<adım>.result}} ya da {{steps.<adım>.result.alan[0].alt}}
+var stepTemplatePattern = regexp.MustCompile(`\{\{\s*steps\.([A-Za-z0-9_-]+)\.result((?:\.[A-Za-z0-9_-]+|\[\d+\])*)\s*\}\}`)
+
+// Yol içindeki tek bir ".alan" ya da "[indeks]" parçası
+var templatePathSegment = regexp.MustCompile(`\.([A-Za-z0-9_-]+)|\[(\d+)\]`)
+
+// templateReferences, argümanlardaki şablonların başvurduğu adım kimliklerini döndürür.
+func templateReferences(args json.RawMessage) ([]string, error) {
+	var refs []string
+	_, err := rewriteTemplateStrings(args, func(s string) (any, error) {
+		for _, match := range stepTemplatePattern.FindAllStringSubmatch(s, -1) {
+			refs = append(refs, match[1])
+		}
+		return s, nil
+	})
+	return refs, err
+}
+
+// resolveTemplates, argümanlardaki şablonları önceki adımların sonuçlarıyla doldurur. Metnin tamamı tek bir
+// şablonsa değer JSON tipiyle (sayı, nesne...) yerleştirilir, metnin içindeyse metne çevrilerek eklenir.
+func resolveTemplates(args json.RawMessage, results map[string]json.RawMessage) (json.RawMessage, error) {
+	return rewriteTemplateStrings(args, func(s string) (any, error) {
+		if match := stepTemplatePattern.FindStringSubmatch(s); match != nil && match[0] == strings.TrimSpace(s) {
+			return lookupStepResult(results, match[1], match[2])
+		}
+
+		var resolveErr error
+		replaced := stepTemplatePattern.ReplaceAllStringFunc(s, func(template string) string {
+			match := stepTemplatePattern.FindStringSubmatch(template)
+			value, err := lookupStepResult(results, match[1], match[2])
+			if err != nil {
+				resolveErr = err
+				return template
+			}
+			if text, ok := value.(string); ok {
+				return text
+			}
+			encoded, _ := json.Marshal(value)
+			return string(encoded)
+		})
+		return replaced, resolveErr
+	})
+}
+
+// ---------------------- HELPERS ----------------------
+
+// rewriteTemplateStrings, JSON içindeki bütün metin değerlerini fn'den geçirerek yeniden kodlar.
+func rewriteTemplateStrings(args json.RawMessage, fn func(string) (any, error)) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		return args, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(args))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	rewritten, err := walkTemplateValue(value, fn)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rewritten)
+}
+
+func walkTemplateValue(value any, fn func(string) (any, error)) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]any:
+		for key, item := range v {
+			rewritten, err := walkTemplateValue(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = rewritten
+		}
+		return v, nil
+	case []any:
+		for i, item := range v {
+			rewritten, err := walkTemplateValue(item, fn)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rewritten
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
+
+// lookupStepResult, adımın sonucunda ".alan" ve "[indeks]" parçalarından oluşan yolu izler.
+func lookupStepResult(results map[string]json.RawMessage, stepID, path string) (any, error) {
+	raw, ok := results[stepID]
+	if !ok {
+		return nil, fmt.Errorf("'%s' adımının sonucu yok", stepID)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("'%s' adımının sonucu JSON değil: %v", stepID, err)
+	}
+
+	for _, segment := range templatePathSegment.FindAllStringSubmatch(path, -1) {
+		if field := segment[1]; field != "" {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("steps.%s.result%s: '%s' alanı bir nesnede değil", stepID, path, field)
+			}
+			if value, ok = object[field]; !ok {
+				return nil, fmt.Errorf("steps.%s.result%s: '%s' alanı bulunamadı", stepID, path, field)
+			}
+			continue
+		}
+
+		index, _ := strconv.Atoi(segment[2])
+		array, ok := value.([]any)
+		if !ok || index >= len(array) {
+			return nil, fmt.Errorf("steps.%s.result%s: [%d] indeksi bulunamadı", stepID, path, index)
+		}
+		value = array[index]
+	}
+	return value, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestResolveTemplates(t *testing.T) {
+	results := map[string]json.RawMessage{
+		"fetch":  json.RawMessage(`{"user": {"name": "Ozan", "age": 30}, "items": [{"id": "a"}, {"id": "b"}], "ok": true}`),
+		"count":  json.RawMessage(`42`),
+		"broken": json.RawMessage(`{not json`),
+	}
+
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{"şablonsuz argümanlar değişmez", `{"text": "merhaba", "n": 1}`, `{"n":1,"text":"merhaba"}`, false},
+		{"tek şablon JSON tipini korur", `{"age": "{{steps.fetch.result.user.age}}"}`, `{"age":30}`, false},
+		{"tek şablon nesne yerleştirir", `{"user": "{{ steps.fetch.result.user }}"}`, `{"user":{"age":30,"name":"Ozan"}}`, false},
+		{"bütün sonuç", `{"n": "{{steps.count.result}}"}`, `{"n":42}`, false},
+		{"dizi indeksi", `{"id": "{{steps.fetch.result.items[1].id}}"}`, `{"id":"b"}`, false},
+		{"metin içindeki şablonlar metne çevrilir", `{"text": "{{steps.fetch.result.user.name}} {{steps.fetch.result.user.age}} yaşında, {{steps.fetch.result.ok}}"}`, `{"text":"Ozan 30 yaşında, true"}`, false},
+		{"metin içindeki nesne JSON olarak yazılır", `{"text": "kullanıcı: {{steps.fetch.result.items[0]}}"}`, `{"text":"kullanıcı: {\"id\":\"a\"}"}`, false},
+		{"iç içe dizi ve nesnelerde", `{"list": [{"v": "{{steps.count.result}}"}, "x"]}`, `{"list":[{"v":42},"x"]}`, false},
+		{"büyük sayılar kaybolmaz", `{"big": 12345678901234567890}`, `{"big":12345678901234567890}`, false},
+		{"boş argümanlar", ``, ``, false},
+		{"sonucu olmayan adım", `{"x": "{{steps.missing.result}}"}`, ``, true},
+		{"bulunmayan alan", `{"x": "{{steps.fetch.result.user.email}}"}`, ``, true},
+		{"nesne olmayan değerde alan", `{"x": "{{steps.count.result.value}}"}`, ``, true},
+		{"dizi sınırı dışında indeks", `{"x": "{{steps.fetch.result.items[2]}}"}`, ``, true},
+		{"metin içinde bulunmayan alan", `{"x": "a {{steps.fetch.result.nope}} b"}`, ``, true},
+		{"JSON olmayan sonuç", `{"x": "{{steps.broken.result}}"}`, ``, true},
+		{"bozuk argümanlar", `{"x":`, ``, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveTemplates(json.RawMessage(tt.args), results)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("hata bekleniyordu, gelen: %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("%s, beklenen %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want []string
+	}{
+		{"şablonsuz", `{"a": "b"}`, nil},
+		{"tek başvuru", `{"a": "{{steps.fetch.result}}"}`, []string{"fetch"}},
+		{"metin içinde birden fazla", `{"a": "{{steps.one.result.x}} ve {{steps.two.result[0]}}"}`, []string{"one", "two"}},
+		{"iç içe değerlerde", `{"a": [{"b": "{{steps.deep.result}}"}]}`, []string{"deep"}},
+		{"şablona benzemeyen metin", `{"a": "{{steps.x}}", "b": "steps.y.result"}`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := templateReferences(json.RawMessage(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("%v, beklenen %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/uslanozan/Go-Smith/models"
+)
+
+func step(id string, dependsOn ...string) models.WorkflowStep {
+	return models.WorkflowStep{ID: id, AgentName: "echo", DependsOn: dependsOn}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []models.WorkflowStep
+		wantErr bool
+	}{
+		{"bağımsız adımlar", []models.WorkflowStep{step("a"), step("b")}, false},
+		{"zincir", []models.WorkflowStep{step("c", "b"), step("b", "a"), step("a")}, false},
+		{"elmas", []models.WorkflowStep{step("a"), step("b", "a"), step("c", "a"), step("d", "b", "c")}, false},
+		{"iki adımlı döngü", []models.WorkflowStep{step("a", "b"), step("b", "a")}, true},
+		{"üç adımlı döngü", []models.WorkflowStep{step("a", "c"), step("b", "a"), step("c", "b")}, true},
+		{"döngüye bağlı adım", []models.WorkflowStep{step("root"), step("a", "root", "b"), step("b", "a"), step("leaf", "b")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := make(map[string]int, len(tt.steps))
+			for i, s := range tt.steps {
+				index[s.ID] = i
+			}
+
+			order, err := topologicalOrder(tt.steps, index)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("döngü hatası bekleniyordu, sıra: %v", order)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(order) != len(tt.steps) {
+				t.Fatalf("%d adım sıralandı, beklenen %d", len(order), len(tt.steps))
+			}
+
+			// Her adım bağımlılıklarından sonra gelmeli
+			position := make(map[string]int, len(order))
+			for p, i := range order {
+				position[tt.steps[i].ID] = p
+			}
+			for _, s := range tt.steps {
+				for _, dep := range s.DependsOn {
+					if position[dep] > position[s.ID] {
+						t.Errorf("'%s', bağımlılığı '%s' adımından önce geliyor: %v", s.ID, dep, order)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateWorkflow(t *testing.T) {
+	registry := NewAgentRegistry()
+	if err := registry.Add(models.AgentDefinition{Name: "echo", Endpoint: "http://echo:9000"}, true); err != nil {
+		t.Fatal(err)
+	}
+	m := NewWorkflowManager(&Orchestrator{Registry: registry}, 0)
+
+	withArgs := func(s models.WorkflowStep, args string) models.WorkflowStep {
+		s.Arguments = json.RawMessage(args)
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		steps   []models.WorkflowStep
+		wantErr string
+	}{
+		{"geçerli workflow", []models.WorkflowStep{step("a"), withArgs(step("b", "a"), `{"x": "{{steps.a.result}}"}`)}, ""},
+		{"dolaylı bağımlılığın sonucu", []models.WorkflowStep{step("a"), step("b", "a"), withArgs(step("c", "b"), `{"x": "{{steps.a.result.id}}"}`)}, ""},
+		{"adımsız", nil, "en az bir adım"},
+		{"döngü", []models.WorkflowStep{step("a", "b"), step("b", "a")}, "döngüsel"},
+		{"kendine bağımlılık", []models.WorkflowStep{step("a", "a")}, "bağımlılığı geçersiz"},
+		{"bilinmeyen bağımlılık", []models.WorkflowStep{step("a", "missing")}, "bağımlılığı geçersiz"},
+		{"tekrar eden kimlik", []models.WorkflowStep{step("a"), step("a")}, "tekrar ediyor"},
+		{"geçersiz kimlik", []models.WorkflowStep{step("a.b")}, "geçersiz adım kimliği"},
+		{"bilinmeyen agent", []models.WorkflowStep{{ID: "a", AgentName: "ghost"}}, "agent'ı bulunamadı"},
+		{"bağımlı olunmayan adımın sonucu", []models.WorkflowStep{step("a"), withArgs(step("b"), `{"x": "{{steps.a.result}}"}`)}, "bağımlı olmadığı"},
+		{"sonraki adımın sonucu", []models.WorkflowStep{withArgs(step("a"), `{"x": "{{steps.b.result}}"}`), step("b", "a")}, "bağımlı olmadığı"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.validate(models.WorkflowRequest{Steps: tt.steps})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q içeren hata bekleniyordu, gelen: %v", tt.wantErr, err)
+			}
+		})
+	}
+}